@@ -0,0 +1,158 @@
+package pipanel
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// alertWaker wakes the display when alerts arrive, according to the alert wake
+// policy, and restores the prior display state once the last alert has been
+// dismissed and the grace period has passed.
+type alertWaker struct {
+	mux sync.Mutex
+	// active is the number of alerts on-screen.
+	active int
+	// woken is true while the prior display state is held for restoring.
+	woken bool
+	// grace restores the prior display state once it fires.
+	grace *time.Timer
+
+	// The prior display state, valid while woken is true.
+	power      DisplayPower
+	powerGen   uint64
+	level      uint8
+	levelKnown bool
+	manualAt   time.Time
+}
+
+// alertWakePolicy returns the alert wake policy, or nil if there is none.
+func (f *Frontend) alertWakePolicy() *AlertWakeConfig {
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+
+	return f.cfg.AlertWake
+}
+
+// wakeForAlert wakes the display for an alert that is about to be shown,
+// according to the policy. The returned function must be called once the alert
+// has been dismissed; further calls are ignored.
+func (f *Frontend) wakeForAlert(ctx context.Context, e AlertEvent,
+	policy *AlertWakeConfig) func() {
+
+	w := &f.waker
+	log := f.log.WithField(componentLogKey, "AlertWake").WithContext(ctx)
+
+	w.mux.Lock()
+	if w.grace != nil {
+		w.grace.Stop()
+		w.grace = nil
+	}
+
+	w.active++
+
+	if !w.woken {
+		w.woken = true
+		w.power, w.powerGen = f.DisplayPowerState()
+		w.level, w.levelKnown = 0, false
+		if level, err := f.Brightness(ctx); err == nil {
+			w.level, w.levelKnown = level, true
+		}
+		w.manualAt = f.ManualBrightnessAt()
+	}
+	w.mux.Unlock()
+
+	if power, _ := f.DisplayPowerState(); policy.DisplayOn && power != DisplayPowerOn {
+		log.Println("Turning the display on for an alert.")
+
+		if err := f.setDisplayPower(ctx, DisplayPowerOn); err != nil {
+			log.WithError(err).Errorln("Problem when turning the display on.")
+		}
+	}
+
+	priority := e.Priority
+	if len(priority) < 1 {
+		priority = PriorityNormal
+	}
+
+	if target, ok := policy.Levels[priority]; ok {
+		if level, err := f.Brightness(ctx); err != nil || level < target {
+			log.WithField("brightness", target).
+				Println("Brightening the display for an alert.")
+
+			err = f.AdjustBrightness(ctx, BrightnessEvent{Level: target})
+			if err != nil {
+				log.WithError(err).Errorln("Problem when brightening the display.")
+			}
+		}
+	}
+
+	var once sync.Once
+	return func() { once.Do(func() { f.alertDismissed(policy) }) }
+}
+
+// alertDismissed counts an alert as dismissed, starting the grace period once
+// none remain on-screen.
+func (f *Frontend) alertDismissed(policy *AlertWakeConfig) {
+	w := &f.waker
+
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	w.active--
+	if w.active > 0 || !w.woken {
+		return
+	}
+
+	grace := policy.Grace
+	if grace == 0 {
+		grace = alertWakeGraceDefault
+	}
+
+	w.grace = time.AfterFunc(time.Duration(grace), f.restoreAfterAlerts)
+}
+
+// restoreAfterAlerts restores the display state from before the alerts
+// arrived. Changes made by the user in the meantime are kept.
+func (f *Frontend) restoreAfterAlerts() {
+	w := &f.waker
+
+	w.mux.Lock()
+	if w.active > 0 || !w.woken {
+		w.mux.Unlock()
+		return
+	}
+
+	w.woken, w.grace = false, nil
+	power, powerGen := w.power, w.powerGen
+	level, levelKnown, manualAt := w.level, w.levelKnown, w.manualAt
+	w.mux.Unlock()
+
+	ctx := context.Background()
+	log := f.log.WithField(componentLogKey, "AlertWake")
+
+	if levelKnown && f.ManualBrightnessAt().Equal(manualAt) {
+		if err := f.AdjustBrightness(ctx, BrightnessEvent{Level: level}); err != nil {
+			log.WithError(err).Errorln("Problem when restoring brightness.")
+		}
+	}
+
+	if current, gen := f.DisplayPowerState(); current != power && gen == powerGen {
+		log.WithField("state", power).Println("Restoring display power after alerts.")
+
+		if err := f.setDisplayPower(ctx, power); err != nil {
+			log.WithError(err).Errorln("Problem when restoring display power.")
+		}
+	}
+}
+
+// stopWaker cancels any pending restore of the display state.
+func (f *Frontend) stopWaker() {
+	f.waker.mux.Lock()
+	defer f.waker.mux.Unlock()
+
+	if f.waker.grace != nil {
+		f.waker.grace.Stop()
+		f.waker.grace = nil
+	}
+}
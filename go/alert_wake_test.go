@@ -0,0 +1,95 @@
+package pipanel
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// plainAlerter is an Alerter that does not report dismissals.
+type plainAlerter struct{}
+
+func (plainAlerter) Init(*logrus.Entry, json.RawMessage) error         { return nil }
+func (plainAlerter) Cleanup() error                                    { return nil }
+func (plainAlerter) ShowAlert(ctx context.Context, e AlertEvent) error { return nil }
+
+// powerDisplay is a DisplayManager that accepts every change.
+type powerDisplay struct{}
+
+func (powerDisplay) Init(*logrus.Entry, json.RawMessage) error { return nil }
+func (powerDisplay) Cleanup() error                            { return nil }
+
+func (powerDisplay) SetBrightness(ctx context.Context, e BrightnessEvent) error {
+	return nil
+}
+
+func (powerDisplay) SetDisplayPower(ctx context.Context, e DisplayPowerEvent) error {
+	return nil
+}
+
+func TestAlertWakeWithoutDismissal(t *testing.T) {
+	const grace = Duration(10 * time.Millisecond)
+	const maxHold = Duration(30 * time.Millisecond)
+
+	tests := []struct {
+		name      string
+		event     AlertEvent
+		wantPower DisplayPower
+	}{
+		{
+			name:      "restored after the alert times out",
+			event:     AlertEvent{Message: "hi", Timeout: 20 * time.Millisecond},
+			wantPower: DisplayPowerOff,
+		},
+		{
+			name:      "restored after the maximum hold for a perpetual alert",
+			event:     AlertEvent{Message: "hi", Perpetual: true},
+			wantPower: DisplayPowerOff,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Frontend{Alerter: plainAlerter{}, DisplayManager: powerDisplay{}}
+
+			err := f.Init(quietLog(), &FrontendConfig{
+				AlertWake: &AlertWakeConfig{
+					DisplayOn: true,
+					Grace:     grace,
+					MaxHold:   maxHold,
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := context.Background()
+			err = f.SetDisplayPower(ctx, DisplayPowerEvent{State: DisplayPowerOff})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = f.ShowAlert(ctx, tt.event); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if power, _ := f.DisplayPowerState(); power != DisplayPowerOn {
+				t.Fatalf("display %s for the alert, want on", power)
+			}
+
+			hold := tt.event.Timeout
+			if tt.event.Perpetual {
+				hold = time.Duration(maxHold)
+			}
+
+			time.Sleep(hold + time.Duration(grace) + 100*time.Millisecond)
+
+			if power, _ := f.DisplayPowerState(); power != tt.wantPower {
+				t.Errorf("display %s after the alert, want %s", power, tt.wantPower)
+			}
+		})
+	}
+}
@@ -0,0 +1,381 @@
+// Package client sends PiPanel events to a PiPanel server over HTTP.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+)
+
+// Client sends events to a PiPanel server.
+type Client struct {
+	// BaseURL is the URL of the server, such as "http://panel.local:8080".
+	BaseURL string
+	// Token is the bearer token presented to the server. If empty, no
+	// Authorization header is sent.
+	Token string
+	// HTTPClient is used to make requests. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+// New creates a Client for the server at the given base URL.
+func New(baseURL, token string) *Client {
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Token:   token,
+	}
+}
+
+// Result describes the outcome of sending an event.
+type Result struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// RequestID is the ID assigned to the request by the server, which can be
+	// used to find the request in the server logs.
+	RequestID string
+	// Message is the body of an unsuccessful response.
+	Message string
+	// Dismissal describes how an interactive alert was dismissed. It is nil
+	// unless the event was an AlertEvent with actions.
+	Dismissal *pipanel.AlertDismissal
+	// Cancelled is the delayed power action that was cancelled. It is nil
+	// unless the request was a successful CancelPowerAction.
+	Cancelled *pipanel.PendingPowerAction
+	// PowerActions are the power actions supported by the panel. It is nil
+	// unless the request was a successful PowerCapabilities.
+	PowerActions []pipanel.PowerAction
+	// Volume is the global volume of the panel. It is nil unless the request
+	// was a successful SendVolume or Volume.
+	Volume *uint8
+	// Sounds are the sounds in the library of the panel. It is nil unless the
+	// request was a successful Sounds.
+	Sounds []pipanel.SoundInfo
+	// Sound describes the sound that was added. It is nil unless the request
+	// was a successful AddSound.
+	Sound *pipanel.SoundInfo
+	// PlaybackEnd describes how playback of a sound ended. It is empty unless
+	// the event was a SoundEvent with Wait set.
+	PlaybackEnd pipanel.PlaybackEnd
+}
+
+// OK reports whether the server handled the event successfully.
+func (r *Result) OK() bool { return r.StatusCode == http.StatusOK }
+
+// SendAlert sends an alert event. The Timeout and sound Interval of the event
+// are converted to milliseconds, as expected by the server. If the event has
+// actions, the call blocks until the alert has been dismissed, or until ctx is
+// done.
+func (c *Client) SendAlert(ctx context.Context, e pipanel.AlertEvent) (*Result, error) {
+	// AlertEvent timeout and sound interval are measured in milliseconds on
+	// the wire.
+	e.Timeout /= time.Millisecond
+	e.Interval /= time.Millisecond
+
+	res, body, err := c.post(ctx, "/alert", e)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.OK() && len(e.Actions) > 0 {
+		var d pipanel.AlertDismissal
+		if err = json.Unmarshal(body, &d); err != nil {
+			return res, errors.Wrap(err, "malformed alert dismissal in response")
+		}
+		res.Dismissal = &d
+	}
+
+	return res, nil
+}
+
+// SendSound sends a sound event. The Interval of the event is converted to
+// milliseconds, as expected by the server. If the event has Wait set, the call
+// blocks until the sound has finished playing or been stopped, or until ctx is
+// done.
+func (c *Client) SendSound(ctx context.Context, e pipanel.SoundEvent) (*Result, error) {
+	// SoundEvent interval is measured in milliseconds on the wire.
+	e.Interval /= time.Millisecond
+
+	res, body, err := c.post(ctx, "/sound", e)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Wait && res.OK() {
+		var r struct {
+			End pipanel.PlaybackEnd `json:"end"`
+		}
+		if err = json.Unmarshal(body, &r); err != nil {
+			return res, errors.Wrap(err, "malformed sound result in response")
+		}
+		res.PlaybackEnd = r.End
+	}
+
+	return res, nil
+}
+
+// StopSounds stops every sound that the panel is playing or is waiting to
+// play.
+func (c *Client) StopSounds(ctx context.Context) (*Result, error) {
+	res, _, err := c.post(ctx, "/sound/stop", nil)
+	return res, err
+}
+
+// SendPower sends a power event. The Delay of the event is converted to
+// milliseconds, as expected by the server.
+func (c *Client) SendPower(ctx context.Context, e pipanel.PowerEvent) (*Result, error) {
+	// PowerEvent delay is measured in milliseconds on the wire.
+	e.Delay /= time.Millisecond
+
+	res, _, err := c.post(ctx, "/power", e)
+	return res, err
+}
+
+// CancelPowerAction cancels the delayed power action that is pending on the
+// panel. The server responds with HTTP 404 if none is pending.
+func (c *Client) CancelPowerAction(ctx context.Context) (*Result, error) {
+	res, body, err := c.do(ctx, http.MethodDelete, "/power/pending", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.OK() {
+		var p pipanel.PendingPowerAction
+		if err = json.Unmarshal(body, &p); err != nil {
+			return res, errors.Wrap(err, "malformed pending power action in response")
+		}
+		res.Cancelled = &p
+	}
+
+	return res, nil
+}
+
+// PowerCapabilities asks the panel which power actions it supports.
+func (c *Client) PowerCapabilities(ctx context.Context) (*Result, error) {
+	res, body, err := c.do(ctx, http.MethodGet, "/power/capabilities", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.OK() {
+		var caps struct {
+			Actions []pipanel.PowerAction `json:"actions"`
+		}
+		if err = json.Unmarshal(body, &caps); err != nil {
+			return res, errors.Wrap(err, "malformed power capabilities in response")
+		}
+		res.PowerActions = caps.Actions
+	}
+
+	return res, nil
+}
+
+// SendBrightness sends a brightness event. The Transition of the event is
+// converted to milliseconds, as expected by the server.
+func (c *Client) SendBrightness(ctx context.Context,
+	e pipanel.BrightnessEvent) (*Result, error) {
+
+	// BrightnessEvent transition is measured in milliseconds on the wire.
+	e.Transition /= time.Millisecond
+
+	res, _, err := c.post(ctx, "/brightness", e)
+	return res, err
+}
+
+// SendVolume sets the global volume of the panel, filling in the volume that
+// the panel reports afterwards.
+func (c *Client) SendVolume(ctx context.Context,
+	e pipanel.VolumeEvent) (*Result, error) {
+
+	body, err := json.Marshal(e)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not encode event as JSON")
+	}
+
+	res, body, err := c.do(ctx, http.MethodPut, "/volume", body)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, readVolume(res, body)
+}
+
+// Volume asks the panel for its global volume.
+func (c *Client) Volume(ctx context.Context) (*Result, error) {
+	res, body, err := c.do(ctx, http.MethodGet, "/volume", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, readVolume(res, body)
+}
+
+// readVolume fills in the volume reported in the body of a successful
+// response.
+func readVolume(res *Result, body []byte) error {
+	if !res.OK() {
+		return nil
+	}
+
+	var v struct {
+		Level uint8 `json:"level"`
+	}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return errors.Wrap(err, "malformed volume in response")
+	}
+	res.Volume = &v.Level
+
+	return nil
+}
+
+// soundRoute returns the route of the sound with the given name in the sound
+// library, escaping each folder of the name.
+func soundRoute(name string) string {
+	folders := strings.Split(name, "/")
+	for i := range folders {
+		folders[i] = url.PathEscape(folders[i])
+	}
+
+	return "/sounds/" + strings.Join(folders, "/")
+}
+
+// Sounds lists the sounds in the library of the panel.
+func (c *Client) Sounds(ctx context.Context) (*Result, error) {
+	res, body, err := c.do(ctx, http.MethodGet, "/sounds", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.OK() {
+		if err = json.Unmarshal(body, &res.Sounds); err != nil {
+			return res, errors.Wrap(err, "malformed sound library in response")
+		}
+	}
+
+	return res, nil
+}
+
+// AddSound uploads a sound file to the library of the panel under the given
+// name, replacing any sound of that name.
+func (c *Client) AddSound(ctx context.Context, name string,
+	data []byte) (*Result, error) {
+
+	res, body, err := c.doType(ctx, http.MethodPut, soundRoute(name),
+		"application/octet-stream", data)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.OK() {
+		var info pipanel.SoundInfo
+		if err = json.Unmarshal(body, &info); err != nil {
+			return res, errors.Wrap(err, "malformed sound in response")
+		}
+		res.Sound = &info
+	}
+
+	return res, nil
+}
+
+// DeleteSound removes the sound with the given name from the library of the
+// panel.
+func (c *Client) DeleteSound(ctx context.Context, name string) (*Result, error) {
+	res, _, err := c.do(ctx, http.MethodDelete, soundRoute(name), nil)
+	return res, err
+}
+
+// PreviewSound plays the sound with the given name from the library of the
+// panel.
+func (c *Client) PreviewSound(ctx context.Context, name string) (*Result, error) {
+	res, _, err := c.do(ctx, http.MethodPost, soundRoute(name)+"/play", nil)
+	return res, err
+}
+
+// SendDisplayPower sends a display power event.
+func (c *Client) SendDisplayPower(ctx context.Context,
+	e pipanel.DisplayPowerEvent) (*Result, error) {
+
+	res, _, err := c.post(ctx, "/display", e)
+	return res, err
+}
+
+// SendColorTemperature sends a color temperature event.
+func (c *Client) SendColorTemperature(ctx context.Context,
+	e pipanel.ColorTemperatureEvent) (*Result, error) {
+
+	res, _, err := c.post(ctx, "/color_temperature", e)
+	return res, err
+}
+
+// post encodes the event as JSON and sends it to the given route, returning
+// the result and the response body.
+func (c *Client) post(ctx context.Context, route string,
+	event interface{}) (*Result, []byte, error) {
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not encode event as JSON")
+	}
+
+	return c.do(ctx, http.MethodPost, route, body)
+}
+
+// do sends a request with the given JSON body to the route, returning the
+// result and the response body.
+func (c *Client) do(ctx context.Context, method, route string,
+	body []byte) (*Result, []byte, error) {
+
+	return c.doType(ctx, method, route, "application/json", body)
+}
+
+// doType sends a request with the given body and content type to the route,
+// returning the result and the response body.
+func (c *Client) doType(ctx context.Context, method, route, contentType string,
+	body []byte) (*Result, []byte, error) {
+
+	req, err := http.NewRequest(method, c.BaseURL+route, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not create request")
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", contentType)
+	if len(c.Token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "request to server failed")
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not read response body")
+	}
+
+	res := Result{
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get(pipanel.RequestIDHeader),
+	}
+
+	if !res.OK() {
+		res.Message = strings.TrimSpace(string(respBody))
+	}
+
+	return &res, respBody, nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+	"github.com/BenJetson/pipanel/go/configfile"
+)
+
+// frontendComponent pairs a component of a frontend, or a controller, with the
+// key of its configuration within the frontend or controllers configuration.
+type frontendComponent struct {
+	key       string
+	component pipanel.InitCleaner
+	rawCfg    json.RawMessage
+}
+
+func frontendComponents(f *pipanel.Frontend,
+	cfg *pipanel.FrontendConfig) []frontendComponent {
+
+	var components []frontendComponent
+
+	add := func(key string, c pipanel.InitCleaner, rawCfg json.RawMessage) {
+		if c != nil {
+			components = append(components, frontendComponent{key, c, rawCfg})
+		}
+	}
+
+	add("alerter", f.Alerter, cfg.AlerterConfig)
+	add("audio_player", f.AudioPlayer, cfg.AudioPlayerConfig)
+	add("power_manager", f.PowerManager, cfg.PowerManagerConfig)
+	add("display_manager", f.DisplayManager, cfg.DisplayManagerConfig)
+
+	return components
+}
+
+func runConfigCommand(args []string) int {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "usage: launcher config validate [flags]")
+		fmt.Fprintln(os.Stderr, "       launcher config schema")
+	}
+
+	if len(args) < 1 {
+		usage()
+		return 2
+	}
+
+	switch args[0] {
+	case "validate":
+		return runConfigValidate(args[1:])
+	case "schema":
+		return runConfigSchema(args[1:])
+	}
+
+	usage()
+	return 2
+}
+
+func runConfigValidate(args []string) int {
+	fs := flag.NewFlagSet("config validate", flag.ExitOnError)
+	cfgPath := fs.String(cfgPathFlag, cfgPathDefault, cfgPathDesc)
+	_ = fs.Parse(args)
+
+	errs := validateConfigFile(*cfgPath)
+
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	if len(errs) > 0 {
+		fmt.Fprintf(os.Stderr, "Configuration is invalid: %d problem(s) found.\n",
+			len(errs))
+		return 1
+	}
+
+	fmt.Println("Configuration is valid.")
+	return 0
+}
+
+// validateConfigFile decodes and validates the configuration file at the given
+// path, including the configuration of every component of the chosen
+// frontend and of every controller. No component is initialized.
+func validateConfigFile(path string) []error {
+	f, err := configfile.ReadFile(path)
+	if err != nil {
+		return []error{err}
+	}
+
+	lines := configfile.IndexLines(f.Format, f.Data)
+
+	var errs []error
+	report := func(fe *configfile.FieldError) {
+		if fe.Line == 0 {
+			fe.Line = lines.Line(fe.Path)
+		}
+		errs = append(errs, fe)
+	}
+
+	// Check the structure of the file before decoding it, so that every
+	// problem is reported with its location rather than only the first.
+	for _, fe := range configfile.CheckFields("", f.Tree, &pipanel.Config{}) {
+		report(fe)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	cfg, err := configfile.Decode(f.Tree)
+	if err != nil {
+		return []error{err}
+	}
+
+	if err = checkConfig(cfg); err != nil {
+		if fe, ok := err.(*configfile.FieldError); ok {
+			report(fe)
+			return errs
+		}
+		return []error{err}
+	}
+
+	frontend := frontendRegister[cfg.Frontend.Name]()
+
+	components := frontendComponents(frontend, &cfg.Frontend)
+	for i := range components {
+		components[i].key = configfile.JoinPath("frontend", components[i].key)
+	}
+
+	for _, name := range sortedKeys(cfg.Controllers) {
+		components = append(components, frontendComponent{
+			key:       configfile.JoinPath("controllers", name),
+			component: controllerRegister[name](frontend),
+			rawCfg:    cfg.Controllers[name],
+		})
+	}
+
+	for _, c := range components {
+		d, ok := c.component.(pipanel.ConfigDescriber)
+		if !ok {
+			continue
+		}
+
+		path := c.key
+
+		var sub interface{}
+		if len(c.rawCfg) > 0 {
+			dec := json.NewDecoder(bytes.NewReader(c.rawCfg))
+			dec.UseNumber()
+			_ = dec.Decode(&sub)
+		}
+
+		fieldErrs := configfile.CheckFields(path, sub, d.ConfigTemplate())
+		for _, fe := range fieldErrs {
+			report(fe)
+		}
+
+		if len(fieldErrs) > 0 {
+			continue
+		}
+
+		if err = d.ValidateConfig(c.rawCfg); err != nil {
+			report(&configfile.FieldError{Path: path, Err: err})
+		}
+	}
+
+	return errs
+}
+
+func runConfigSchema(args []string) int {
+	fs := flag.NewFlagSet("config schema", flag.ExitOnError)
+	_ = fs.Parse(args)
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(configSchema()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// configSchema generates a JSON Schema for the configuration file. The
+// frontend configuration is described once per registered frontend, including
+// the configuration of each of its components, and each controller is
+// described under its name.
+func configSchema() configfile.Schema {
+	names := make([]string, 0, len(frontendRegister))
+	for name := range frontendRegister {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	variants := make([]configfile.Schema, 0, len(names))
+
+	for _, name := range names {
+		frontend := frontendRegister[name]()
+
+		s := configfile.SchemaOf(&pipanel.FrontendConfig{})
+		s["required"] = []string{"name"}
+
+		props := s.Properties()
+		props["name"] = configfile.Schema{"const": name}
+
+		for _, c := range frontendComponents(frontend, &pipanel.FrontendConfig{}) {
+			if d, ok := c.component.(pipanel.ConfigDescriber); ok {
+				props[c.key] = configfile.SchemaOf(d.ConfigTemplate())
+			}
+		}
+
+		variants = append(variants, s)
+	}
+
+	controllers := make(map[string]configfile.Schema, len(controllerRegister))
+	for name, create := range controllerRegister {
+		controllers[name] = configfile.Schema{}
+		if d, ok := create(nil).(pipanel.ConfigDescriber); ok {
+			controllers[name] = configfile.SchemaOf(d.ConfigTemplate())
+		}
+	}
+
+	s := configfile.SchemaOf(&pipanel.Config{})
+	s["$schema"] = "http://json-schema.org/draft-07/schema#"
+	s["title"] = "PiPanel configuration"
+	s.Properties()["frontend"] = configfile.Schema{"oneOf": variants}
+	s.Properties()["controllers"] = configfile.Schema{
+		"type":                 "object",
+		"properties":           controllers,
+		"additionalProperties": false,
+	}
+
+	return s
+}
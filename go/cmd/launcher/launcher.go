@@ -0,0 +1,544 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+	"github.com/BenJetson/pipanel/go/configfile"
+	"github.com/BenJetson/pipanel/go/controllers/ambient"
+	"github.com/BenJetson/pipanel/go/controllers/idle"
+	"github.com/BenJetson/pipanel/go/controllers/solar"
+	"github.com/BenJetson/pipanel/go/controllers/ups"
+	"github.com/BenJetson/pipanel/go/frontends"
+	"github.com/BenJetson/pipanel/go/logfmt"
+	"github.com/BenJetson/pipanel/go/server"
+	"github.com/BenJetson/pipanel/go/systemd"
+)
+
+// Command line flag constants.
+const (
+	serverPortFlag    = "port"
+	serverPortDefault = -1
+	serverPortDesc    = "the port for the server to listen on; " +
+		"if this flag is set, will override port from config file"
+
+	cfgPathFlag    = "config"
+	cfgPathDefault = "~/pipanel_config.json"
+	cfgPathDesc    = "path to the configuration file; " +
+		"the format (JSON, YAML or TOML) is chosen by the file extension"
+)
+
+// frontendRegister is map from frontend name to a function that creates a new
+// instance of that particular frontend type.
+var frontendRegister = map[string]func() *pipanel.Frontend{
+	"console":                      frontends.NewConsoleFrontend,
+	"pipanel-gtk":                  frontends.NewPiPanelGTK,
+	"pipanel-gtk-backlight":        frontends.NewPiPanelGTKBacklight,
+	"pipanel-gtk-backlight-logind": frontends.NewPiPanelGTKBacklightLogind,
+	"pipanel-gtk-gamma":            frontends.NewPiPanelGTKGamma,
+	"pipanel-gtk-gamma-logind":     frontends.NewPiPanelGTKGammaLogind,
+	"pipanel-gtk-logind":           frontends.NewPiPanelGTKLogind,
+	"pipanel-gtk-powerd":           frontends.NewPiPanelGTKPowerd,
+}
+
+// controllerRegister is map from controller name to a function that creates a
+// new instance of that particular controller type.
+var controllerRegister = map[string]pipanel.ControllerFactory{
+	"ambient": func(f *pipanel.Frontend) pipanel.Controller { return ambient.New(f) },
+	"idle":    func(f *pipanel.Frontend) pipanel.Controller { return idle.New(f) },
+	"solar":   func(f *pipanel.Frontend) pipanel.Controller { return solar.New(f) },
+	"ups":     func(f *pipanel.Frontend) pipanel.Controller { return ups.New(f) },
+}
+
+// cfgWatchInterval is how often the configuration file is checked for changes.
+const cfgWatchInterval = 2 * time.Second
+
+// shutdownTimeoutDefault is the shutdown timeout used if none is configured.
+const shutdownTimeoutDefault = 10 * time.Second
+
+// subcommands maps the name of each subcommand to the function that runs it.
+// The function is given the remaining arguments and returns the exit status.
+var subcommands = map[string]func(args []string) int{
+	"config": runConfigCommand,
+	"send":   runSendCommand,
+}
+
+func checkConfig(cfg *pipanel.Config) error {
+	if cfg.Server.Port < 0 {
+		return &configfile.FieldError{
+			Path: "server.port",
+			Err:  errors.New("port number cannot be negative"),
+		}
+	} else if cfg.Server.Port < 1024 {
+		return &configfile.FieldError{
+			Path: "server.port",
+			Err:  errors.New("port numbers 0-1023 are reserved by the system"),
+		}
+	}
+
+	if _, ok := frontendRegister[cfg.Frontend.Name]; !ok {
+		return &configfile.FieldError{
+			Path: "frontend.name",
+			Err:  errors.Errorf("no such frontend '%s' registered", cfg.Frontend.Name),
+		}
+	}
+
+	if w := cfg.Frontend.AlertWake; w != nil {
+		if err := w.Validate(); err != nil {
+			return &configfile.FieldError{Path: "frontend.alert_wake", Err: err}
+		}
+	}
+
+	switch cfg.Frontend.PendingPower {
+	case "", pipanel.PendingPowerReplace, pipanel.PendingPowerReject:
+	default:
+		return &configfile.FieldError{
+			Path: "frontend.pending_power",
+			Err: errors.Errorf("no such pending power policy '%s'",
+				cfg.Frontend.PendingPower),
+		}
+	}
+
+	for _, name := range sortedKeys(cfg.Controllers) {
+		if _, ok := controllerRegister[name]; !ok {
+			return &configfile.FieldError{
+				Path: configfile.JoinPath("controllers", name),
+				Err:  errors.Errorf("no such controller '%s' registered", name),
+			}
+		}
+	}
+
+	if cfg.Shutdown.Timeout < 0 {
+		return &configfile.FieldError{
+			Path: "shutdown.timeout",
+			Err:  errors.New("shutdown timeout cannot be negative"),
+		}
+	}
+
+	switch cfg.Shutdown.Policy {
+	case "", pipanel.ShutdownFinish, pipanel.ShutdownInterrupt:
+	default:
+		return &configfile.FieldError{
+			Path: "shutdown.policy",
+			Err:  errors.Errorf("no such shutdown policy '%s'", cfg.Shutdown.Policy),
+		}
+	}
+
+	return nil
+}
+
+// sortedKeys returns the keys of the map in order.
+func sortedKeys(m map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// launchOptions holds the values of the command line flags.
+type launchOptions struct {
+	port    int
+	cfgPath string
+}
+
+func parseFlags() launchOptions {
+	var opts launchOptions
+
+	// Set up command line flags.
+	flag.IntVar(&opts.port, serverPortFlag, serverPortDefault, serverPortDesc)
+	flag.StringVar(&opts.cfgPath, cfgPathFlag, cfgPathDefault, cfgPathDesc)
+
+	// Read command line flags.
+	flag.Parse()
+
+	return opts
+}
+
+func loadConfig(log *logrus.Entry, opts launchOptions) (*pipanel.Config, error) {
+	// Load config from disk, applying environment overrides.
+	log.Println("Loading configuration from disk.")
+	cfg, err := configfile.Load(opts.cfgPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read configuration file")
+	}
+
+	// If a port is specified at the shell prompt, overwrite the config.
+	if opts.port != -1 {
+		log.Println("Port flag set: overriding configuration file preference.")
+		cfg.Server.Port = opts.port
+	}
+
+	// Validate the configuration.
+	if err = checkConfig(cfg); err != nil {
+		return nil, errors.Wrap(err, "invalid configuration")
+	}
+
+	log.Println("Configuration accepted.")
+
+	return cfg, nil
+}
+
+// watchConfig polls the configuration file for modifications, sending on the
+// changed channel each time the file's modification time or size changes.
+// Polling stops once the done channel is closed.
+func watchConfig(path string, changed chan<- struct{}, done <-chan struct{}) {
+	var lastMod time.Time
+	var lastSize int64
+
+	if info, err := os.Stat(path); err == nil {
+		lastMod, lastSize = info.ModTime(), info.Size()
+	}
+
+	ticker := time.NewTicker(cfgWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			// The file may be mid-replacement by an editor; try again later.
+			continue
+		}
+
+		if info.ModTime().Equal(lastMod) && info.Size() == lastSize {
+			continue
+		}
+
+		lastMod, lastSize = info.ModTime(), info.Size()
+
+		// Do not block if a reload is already pending.
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// reloadConfig reads the configuration from disk again and applies it to the
+// running frontend. Should the new configuration be invalid, the current one
+// remains in effect.
+func reloadConfig(log *logrus.Entry, opts launchOptions, cfg *pipanel.Config,
+	frontend *pipanel.Frontend, controllers *pipanel.Controllers) {
+
+	notify(log, systemd.Reloading, systemd.Status("Reloading configuration..."))
+	defer notify(log, systemd.Ready, systemd.Status("Ready to receive events."))
+
+	newCfg, err := loadConfig(log, opts)
+	if err != nil {
+		logfmt.WithError(log, err).
+			Errorln("Configuration reload failed; keeping previous configuration.")
+		return
+	}
+
+	// The listener is kept open across reloads, so server changes cannot
+	// take effect until the next restart. Neither can a change of frontend.
+	if newCfg.Server != cfg.Server {
+		log.Warnln("Server configuration changed; restart to apply.")
+	}
+	if newCfg.Frontend.Name != cfg.Frontend.Name {
+		log.Warnln("Frontend name changed; restart to apply.")
+	}
+
+	// The shutdown configuration is only read upon shutdown, so it may
+	// always be applied.
+	cfg.Shutdown = newCfg.Shutdown
+
+	var failed bool
+
+	if err = frontend.Reconfigure(&newCfg.Frontend); err != nil {
+		logfmt.WithError(log, err).
+			Errorln("Some components kept their previous configuration.")
+		failed = true
+	}
+
+	if err = controllers.Apply(newCfg.Controllers); err != nil {
+		logfmt.WithError(log, err).
+			Errorln("Some controllers could not be updated.")
+		failed = true
+	}
+
+	if failed {
+		return
+	}
+
+	log.Println("Configuration reloaded.")
+}
+
+// notify sends the notifications to systemd, if PiPanel was started by it.
+func notify(log *logrus.Entry, notifications ...string) {
+	if _, err := systemd.Notify(notifications...); err != nil {
+		logfmt.WithError(log, err).Warnln("Problem when notifying systemd.")
+	}
+}
+
+// healthChecker is implemented by each part of PiPanel that is checked before
+// resetting the systemd watchdog.
+type healthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// runWatchdog resets the systemd watchdog at half its interval for as long as
+// every checker is healthy, until stop is closed. Does nothing unless systemd
+// enabled the watchdog.
+func runWatchdog(log *logrus.Entry, stop <-chan struct{},
+	checkers ...healthChecker) {
+
+	interval, enabled, err := systemd.WatchdogInterval()
+	if err != nil {
+		logfmt.WithError(log, err).Errorln("Problem when reading watchdog interval.")
+		return
+	} else if !enabled {
+		return
+	}
+
+	log.WithField("interval", interval).Println("Watchdog enabled.")
+
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+
+	var healthy = true
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval/2)
+
+		var errs pipanel.ErrorList
+		for _, c := range checkers {
+			if err := c.HealthCheck(ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		cancel()
+
+		// Withholding the watchdog notification lets systemd restart PiPanel
+		// should it stay unhealthy.
+		if err := errs.Err(); err != nil {
+			logfmt.WithError(log, err).Errorln("Health check failed.")
+			notify(log, systemd.Status("Unhealthy: "+err.Error()))
+			healthy = false
+			continue
+		}
+
+		if !healthy {
+			log.Println("Health check passed again.")
+			notify(log, systemd.Watchdog, systemd.Status("Ready to receive events."))
+			healthy = true
+			continue
+		}
+
+		notify(log, systemd.Watchdog)
+	}
+}
+
+const msgSrcLogKey = "msg-src"
+
+func main() {
+	// Run a subcommand instead of the panel, if one is given.
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(run(os.Args[2:]))
+		}
+	}
+
+	// Create log instances.
+	logger := logrus.New()
+	logger.SetFormatter(&logfmt.RequestIDFormatter{
+		TextFormatter: &logrus.TextFormatter{
+			SortingFunc: func(keys []string) {
+				sort.Slice(keys, func(i, j int) bool {
+					// Ensure that the component key is always at the top.
+					if keys[i] == msgSrcLogKey {
+						return true
+					} else if keys[j] == msgSrcLogKey {
+						return false
+					}
+
+					// All keys after component are sorted alphabetically.
+					return keys[i] < keys[j]
+				})
+			},
+		},
+	})
+
+	logServer := logger.WithFields(logrus.Fields{msgSrcLogKey: "server"})
+	logFrontend := logger.WithFields(logrus.Fields{msgSrcLogKey: "frontend"})
+	logController := logger.WithFields(logrus.Fields{msgSrcLogKey: "controller"})
+	logMain := logger.WithFields(logrus.Fields{msgSrcLogKey: "main"})
+
+	// Load configuration.
+	opts := parseFlags()
+
+	cfgPath, err := configfile.ExpandHome(opts.cfgPath)
+	if err != nil {
+		logfmt.WithError(logMain, err).
+			Fatalln("Problem when locating configuration file.")
+	}
+	opts.cfgPath = cfgPath
+
+	cfg, err := loadConfig(logMain, opts)
+	if err != nil {
+		logfmt.WithError(logMain, err).
+			Fatalln("Problem when loading configuration.")
+	}
+
+	// Create signaling channels for concurrent operations.
+	interrupt := make(chan os.Signal, 1)
+	hangup := make(chan os.Signal, 1)
+	cfgChanged := make(chan struct{}, 1)
+	reloadRequested := make(chan struct{}, 1)
+	stopWatching := make(chan struct{})
+	stopWatchdog := make(chan struct{})
+	shutdown := make(chan struct{}, 1)
+
+	// Notify interrupt channel when a SIGINT or SIGTERM is detected, and the
+	// hangup channel when a SIGHUP is detected.
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	// Create new frontend instance and initialize it.
+	logMain.Println("Initializing frontend...")
+	frontend := frontendRegister[cfg.Frontend.Name]()
+
+	err = frontend.Init(logFrontend, &cfg.Frontend)
+	if err != nil {
+		logfmt.WithError(logMain, err).
+			Fatalln("Problem when initializing frontend.")
+	}
+
+	// Handle the reloadConfig power action here, without involving the
+	// service manager. Do not block if a reload is already pending.
+	frontend.SetReloader(func() {
+		select {
+		case reloadRequested <- struct{}{}:
+		default:
+		}
+	})
+
+	// Start the controllers, which drive the frontend by themselves.
+	logMain.Println("Starting controllers...")
+	controllers := pipanel.NewControllers(logController, frontend,
+		controllerRegister)
+
+	if err = controllers.Apply(cfg.Controllers); err != nil {
+		logfmt.WithError(logMain, err).
+			Fatalln("Problem when starting controllers.")
+	}
+
+	// Start the server.
+	logMain.Println("Starting the server...")
+	server := server.New(logServer, cfg.Server, frontend)
+	server.Mount(pipanel.ControllersRoute, controllers)
+
+	// Listen before notifying systemd, so that no request is refused once
+	// PiPanel is reported as ready.
+	if err = server.Listen(); err != nil {
+		logfmt.WithError(logMain, err).
+			Fatalln("Problem when starting the server.")
+	}
+
+	go server.Serve(shutdown)
+
+	// Watch the configuration file for changes.
+	go watchConfig(opts.cfgPath, cfgChanged, stopWatching)
+
+	// Create cleanup function for use upon interrupt/shutdown.
+	cleanup := func(reason string) {
+		logMain.Printf("Terminating: %s\n", reason)
+		notify(logMain, systemd.Stopping, systemd.Status("Shutting down..."))
+		close(stopWatchdog)
+		close(stopWatching)
+
+		timeout := time.Duration(cfg.Shutdown.Timeout)
+		if timeout == 0 {
+			timeout = shutdownTimeoutDefault
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		// Stop accepting requests and drain those in flight in the
+		// background, since some may be waiting on alerts that will only be
+		// dismissed once the frontend is cleaned up.
+		logMain.Println("Shutting down the server...")
+		serverDone := make(chan error, 1)
+		go func() { serverDone <- server.Shutdown(ctx) }()
+
+		// Stop the controllers first, so they no longer act on the frontend.
+		logMain.Println("Stopping controllers...")
+		if err := controllers.Cleanup(); err != nil {
+			logfmt.WithError(logMain, err).
+				Errorln("Stopping controllers failed.")
+		}
+
+		if cfg.Shutdown.Policy != pipanel.ShutdownInterrupt {
+			logMain.Println("Waiting for frontend work in progress to finish...")
+			if err := frontend.Drain(ctx); err != nil {
+				logfmt.WithError(logMain, err).
+					Warnln("Cutting off frontend work in progress.")
+			}
+		}
+
+		logMain.Println("Clearing frontend resources...")
+		if err := frontend.Cleanup(); err != nil {
+			logfmt.WithError(logMain, err).
+				Errorln("Clearing frontend resources failed.")
+		}
+
+		if err := <-serverDone; err != nil {
+			logfmt.WithError(logMain, err).
+				Errorln("Shutting down server failed.")
+		}
+	}
+
+	logMain.Println("Ready to receive events.")
+	notify(logMain, systemd.Ready, systemd.Status("Ready to receive events."))
+
+	// Keep the systemd watchdog fed while PiPanel is healthy.
+	go runWatchdog(logMain, stopWatchdog, server, frontend, controllers)
+
+	// Reload configuration on request until it is time to shut down.
+	for {
+		select {
+		case <-hangup:
+			logMain.Println("SIGHUP detected; reloading configuration...")
+			reloadConfig(logMain, opts, cfg, frontend, controllers)
+		case <-cfgChanged:
+			logMain.Println("Configuration file changed; reloading...")
+			reloadConfig(logMain, opts, cfg, frontend, controllers)
+		case <-reloadRequested:
+			logMain.Println("Reload requested by power action; reloading...")
+			reloadConfig(logMain, opts, cfg, frontend, controllers)
+		case sig := <-interrupt:
+			cleanup(fmt.Sprintf("%s detected", sig))
+			return
+		case <-shutdown:
+			cleanup("server shutdown detected")
+			return
+		}
+	}
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+	"github.com/BenJetson/pipanel/go/client"
+	"github.com/BenJetson/pipanel/go/configfile"
+)
+
+// sendTimeout bounds how long a non-interactive send may take. Interactive
+// alerts wait for the user instead.
+const sendTimeout = 30 * time.Second
+
+// stringList is a flag.Value that collects every occurrence of a flag.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// repeatFlag is a flag.Value for a pipanel.SoundRepeat, given as a count or
+// "until-acknowledged".
+type repeatFlag pipanel.SoundRepeat
+
+func (r *repeatFlag) String() string {
+	if pipanel.SoundRepeat(*r) == pipanel.RepeatUntilAcknowledged {
+		return "until-acknowledged"
+	}
+	return strconv.Itoa(int(*r))
+}
+
+func (r *repeatFlag) Set(v string) error {
+	if v == "until-acknowledged" {
+		*r = repeatFlag(pipanel.RepeatUntilAcknowledged)
+		return nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return fmt.Errorf("must be a count or until-acknowledged")
+	}
+
+	*r = repeatFlag(n)
+	return nil
+}
+
+// clientFlags are the flags shared by all send subcommands.
+type clientFlags struct {
+	url     string
+	token   string
+	cfgPath string
+}
+
+func (cf *clientFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&cf.url, "url", "", "base URL of the PiPanel server; "+
+		"defaults to localhost on the port from the configuration file")
+	fs.StringVar(&cf.token, "token", "", "bearer token for the server; "+
+		"defaults to the token from the configuration file")
+	fs.StringVar(&cf.cfgPath, cfgPathFlag, cfgPathDefault,
+		"path to the configuration file used for defaults")
+}
+
+// newClient creates a client from the flags, filling in the server URL and
+// token from the configuration file where they were not given.
+func (cf *clientFlags) newClient() (*client.Client, error) {
+	url, token := cf.url, cf.token
+
+	if len(url) < 1 || len(token) < 1 {
+		cfg, err := configfile.Load(cf.cfgPath)
+
+		if err == nil {
+			if len(url) < 1 {
+				url = fmt.Sprintf("http://localhost:%d", cfg.Server.Port)
+			}
+			if len(token) < 1 {
+				token = cfg.Server.AuthToken
+			}
+		} else if len(url) < 1 {
+			return nil, fmt.Errorf("no -url given and could not read "+
+				"configuration file: %v", err)
+		}
+	}
+
+	return client.New(url, token), nil
+}
+
+func runSendCommand(args []string) int {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "usage: launcher send "+
+			"alert|sound|sounds|volume|power|brightness|display|colortemp [flags]")
+	}
+
+	if len(args) < 1 {
+		usage()
+		return 2
+	}
+
+	fs := flag.NewFlagSet("send "+args[0], flag.ExitOnError)
+
+	var cf clientFlags
+	cf.register(fs)
+
+	// Actions and waiting for sounds are declared here, since they decide
+	// whether to wait.
+	var actions stringList
+	var wait bool
+
+	var send func(ctx context.Context, c *client.Client) (*client.Result, error)
+
+	switch args[0] {
+	case "alert":
+		var e pipanel.AlertEvent
+
+		fs.StringVar(&e.Message, "message", "", "text content of the alert")
+		fs.DurationVar(&e.Timeout, "timeout", 0,
+			"time until the alert dismisses itself, such as 10s")
+		fs.BoolVar(&e.Perpetual, "perpetual", false,
+			"keep the alert on-screen until acknowledged")
+		fs.StringVar(&e.Icon, "icon", "", "name of the gtk icon to display")
+		fs.StringVar(&e.Sound, "sound", "", "name of the sound to play")
+		fs.StringVar(&e.Tone, "tone", "", "name of a synthesized tone, "+
+			"such as beep, chime or siren, or an RTTTL ringtone to play")
+		fs.Var((*repeatFlag)(&e.Repeat), "repeat", "times to play the sound, "+
+			"or until-acknowledged to repeat it until the alert is dismissed")
+		fs.DurationVar(&e.Interval, "interval", 0,
+			"silence between repeats of the sound, such as 2s")
+		fs.Var(&actions, "action", "label of an action button; "+
+			"may be repeated, and makes the command wait for a choice")
+		fs.StringVar((*string)(&e.Priority), "priority", "",
+			"importance of the alert: low, normal, high or critical")
+
+		send = func(ctx context.Context, c *client.Client) (*client.Result, error) {
+			if len(e.Message) < 1 {
+				return nil, fmt.Errorf("-message is required")
+			}
+			e.Actions = actions
+			return c.SendAlert(ctx, e)
+		}
+	case "sound":
+		var e pipanel.SoundEvent
+		var volume int
+		var mode string
+		var stop bool
+
+		fs.StringVar(&e.Sound, "sound", "", "name of the sound to play")
+		fs.StringVar(&e.Tone, "tone", "", "name of a synthesized tone, "+
+			"such as beep, chime or siren, or an RTTTL ringtone to play")
+		fs.IntVar(&volume, "volume", -1, "volume of the sound on the range "+
+			"[0,100], relative to the global volume")
+		fs.StringVar(&mode, "mode", "", "how to play the sound alongside "+
+			"others: mix, queue or replace")
+		fs.IntVar((*int)(&e.Repeat), "repeat", 0, "times to play the sound")
+		fs.DurationVar(&e.Interval, "interval", 0,
+			"silence between repeats of the sound, such as 2s")
+		fs.BoolVar(&wait, "wait", false,
+			"wait until the sound has finished playing")
+		fs.BoolVar(&stop, "stop", false,
+			"stop all sounds that are playing instead")
+
+		send = func(ctx context.Context, c *client.Client) (*client.Result, error) {
+			if stop {
+				return c.StopSounds(ctx)
+			}
+			if e.Empty() {
+				return nil, fmt.Errorf("-sound, -tone or -stop is required")
+			} else if e.Repeat < 0 {
+				return nil, fmt.Errorf("-repeat cannot be negative")
+			}
+			e.Mode = pipanel.PlaybackMode(mode)
+			e.Wait = wait
+			if volume > 100 {
+				return nil, fmt.Errorf("-volume must be on the range [0,100]")
+			} else if volume >= 0 {
+				v := uint8(volume)
+				e.Volume = &v
+			}
+			return c.SendSound(ctx, e)
+		}
+	case "sounds":
+		var upload, name, del, play string
+
+		fs.StringVar(&upload, "upload", "", "path of a sound file to add "+
+			"to the library; requires -name")
+		fs.StringVar(&name, "name", "", "name to add the sound under, "+
+			"such as doorbell/chime")
+		fs.StringVar(&del, "delete", "", "name of a sound to remove from "+
+			"the library")
+		fs.StringVar(&play, "play", "", "name of a sound in the library "+
+			"to preview")
+
+		send = func(ctx context.Context, c *client.Client) (*client.Result, error) {
+			switch {
+			case len(upload) > 0:
+				if len(name) < 1 {
+					return nil, fmt.Errorf("-name is required with -upload")
+				}
+				data, err := ioutil.ReadFile(upload)
+				if err != nil {
+					return nil, err
+				}
+				return c.AddSound(ctx, name, data)
+			case len(del) > 0:
+				return c.DeleteSound(ctx, del)
+			case len(play) > 0:
+				return c.PreviewSound(ctx, play)
+			}
+			return c.Sounds(ctx)
+		}
+	case "volume":
+		var level int
+
+		fs.IntVar(&level, "level", -1, "global volume on the range [0,100]; "+
+			"if omitted, the volume is only reported")
+
+		send = func(ctx context.Context, c *client.Client) (*client.Result, error) {
+			if level < 0 {
+				return c.Volume(ctx)
+			}
+			if level > 100 {
+				return nil, fmt.Errorf("-level must be on the range [0,100]")
+			}
+			return c.SendVolume(ctx, pipanel.VolumeEvent{Level: uint8(level)})
+		}
+	case "power":
+		var action string
+		var cancel, capabilities bool
+		var e pipanel.PowerEvent
+
+		fs.StringVar(&action, "action", "", "power action to perform, "+
+			"such as shutdown, reboot, suspend, hibernate, displayOff, displayOn, "+
+			"restartService, reloadConfig or cancel")
+		fs.DurationVar(&e.Delay, "delay", 0, "time to count down before "+
+			"performing the action, such as 1m; the panel offers to cancel it")
+		fs.StringVar(&e.Reason, "reason", "", "why the action is requested")
+		fs.BoolVar(&cancel, "cancel", false,
+			"cancel the pending delayed power action instead")
+		fs.BoolVar(&capabilities, "capabilities", false,
+			"list the power actions supported by the panel instead")
+
+		send = func(ctx context.Context, c *client.Client) (*client.Result, error) {
+			if capabilities {
+				return c.PowerCapabilities(ctx)
+			}
+			if cancel {
+				return c.CancelPowerAction(ctx)
+			}
+			if len(action) < 1 {
+				return nil, fmt.Errorf("-action, -cancel or -capabilities is required")
+			}
+			e.Action = pipanel.PowerAction(action)
+			return c.SendPower(ctx, e)
+		}
+	case "brightness":
+		var level int
+		var transition time.Duration
+		var easing string
+
+		fs.IntVar(&level, "level", -1, "brightness level on the range [0,255]")
+		fs.DurationVar(&transition, "transition", 0,
+			"time over which to fade to the level, such as 2s")
+		fs.StringVar(&easing, "easing", "", "curve of the fade: linear, "+
+			"ease-in, ease-out or ease-in-out")
+
+		send = func(ctx context.Context, c *client.Client) (*client.Result, error) {
+			if level < 0 || level > 255 {
+				return nil, fmt.Errorf("-level must be on the range [0,255]")
+			}
+			return c.SendBrightness(ctx, pipanel.BrightnessEvent{
+				Level:      uint8(level),
+				Transition: transition,
+				Easing:     pipanel.Easing(easing),
+			})
+		}
+	case "display":
+		var state string
+
+		fs.StringVar(&state, "state", "", "display power state: on, standby or off")
+
+		send = func(ctx context.Context, c *client.Client) (*client.Result, error) {
+			if len(state) < 1 {
+				return nil, fmt.Errorf("-state is required")
+			}
+			return c.SendDisplayPower(ctx, pipanel.DisplayPowerEvent{
+				State: pipanel.DisplayPower(state),
+			})
+		}
+	case "colortemp":
+		var kelvin int
+
+		fs.IntVar(&kelvin, "kelvin", -1, "color temperature in kelvin, "+
+			"such as 3400; zero restores neutral colors")
+
+		send = func(ctx context.Context, c *client.Client) (*client.Result, error) {
+			if kelvin < 0 || kelvin > math.MaxUint16 {
+				return nil, fmt.Errorf("-kelvin must be on the range [0,%d]",
+					math.MaxUint16)
+			}
+			return c.SendColorTemperature(ctx, pipanel.ColorTemperatureEvent{
+				Kelvin: uint16(kelvin),
+			})
+		}
+	default:
+		usage()
+		return 2
+	}
+
+	_ = fs.Parse(args[1:])
+
+	c, err := cf.newClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	// Interactive alerts wait for the user, and sounds may be waited for, so
+	// only bound other requests.
+	ctx := context.Background()
+	if len(actions) < 1 && !wait {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, sendTimeout)
+		defer cancel()
+	}
+
+	res, err := send(ctx, c)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	printResult(res)
+
+	if !res.OK() {
+		return 1
+	}
+
+	return 0
+}
+
+func printResult(res *client.Result) {
+	if res.OK() {
+		fmt.Printf("OK (request ID %s)\n", res.RequestID)
+	} else {
+		fmt.Printf("Failed with HTTP %d (request ID %s): %s\n",
+			res.StatusCode, res.RequestID, res.Message)
+	}
+
+	if d := res.Dismissal; d != nil {
+		if d.Reason == pipanel.DismissAction {
+			fmt.Printf("Chosen action: %s\n", d.Action)
+		} else {
+			fmt.Printf("Dismissed: %s\n", d.Reason)
+		}
+	}
+
+	for _, info := range res.Sounds {
+		printSound(info)
+	}
+
+	if res.Sound != nil {
+		printSound(*res.Sound)
+	}
+
+	if len(res.PlaybackEnd) > 0 {
+		fmt.Printf("Playback %s\n", res.PlaybackEnd)
+	}
+
+	if res.Volume != nil {
+		fmt.Printf("Volume: %d\n", *res.Volume)
+	}
+
+	if p := res.Cancelled; p != nil {
+		fmt.Printf("Cancelled %s scheduled for %s\n", p.Action,
+			p.At.Format(time.RFC3339))
+	}
+
+	if res.PowerActions != nil {
+		actions := make([]string, len(res.PowerActions))
+		for i, a := range res.PowerActions {
+			actions[i] = string(a)
+		}
+		fmt.Printf("Supported power actions: %s\n", strings.Join(actions, ", "))
+	}
+}
+
+func printSound(info pipanel.SoundInfo) {
+	fmt.Printf("%s\t%s\t%s\t%d Hz\n", info.Name, info.Format,
+		time.Duration(info.Duration), info.SampleRate)
+}
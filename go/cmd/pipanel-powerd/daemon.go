@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+	"github.com/BenJetson/pipanel/go/frontends/power_managers/powerd"
+	"github.com/BenJetson/pipanel/go/logfmt"
+)
+
+// requestTimeout bounds the time spent reading a request from a peer and
+// writing the response.
+const requestTimeout = 5 * time.Second
+
+// actionTimeout bounds the time spent performing a power action.
+const actionTimeout = time.Minute
+
+// command returns the command line that performs the power action, given the
+// systemd unit that runs PiPanel. Returns false if the action is unknown.
+func command(a pipanel.PowerAction, unit string) ([]string, bool) {
+	switch a {
+	case pipanel.PowerActionShutdown:
+		return []string{"systemctl", "poweroff"}, true
+	case pipanel.PowerActionReboot:
+		return []string{"systemctl", "reboot"}, true
+	case pipanel.PowerActionSuspend:
+		return []string{"systemctl", "suspend"}, true
+	case pipanel.PowerActionHibernate:
+		return []string{"systemctl", "hibernate"}, true
+	case pipanel.PowerActionRestartService:
+		// Restarting stops the peer, so do not wait for the job.
+		return []string{"systemctl", "restart", "--no-block", unit}, true
+	case pipanel.PowerActionReloadConfig:
+		return []string{"systemctl", "reload", unit}, true
+	}
+
+	return nil, false
+}
+
+// daemon answers power action requests from authorized peers.
+type daemon struct {
+	log     *logrus.Entry
+	uids    map[uint32]bool
+	gids    map[uint32]bool
+	actions []pipanel.PowerAction
+	// commands maps each allowed action to its command line.
+	commands map[pipanel.PowerAction][]string
+	limiter  *limiter
+	// mux ensures that only one power action is performed at a time.
+	mux sync.Mutex
+}
+
+// newDaemon creates a daemon from the command line options, resolving the
+// names of users and groups.
+func newDaemon(log *logrus.Entry, opts options) (*daemon, error) {
+	d := daemon{
+		log:      log,
+		uids:     make(map[uint32]bool),
+		gids:     make(map[uint32]bool),
+		commands: make(map[pipanel.PowerAction][]string),
+	}
+
+	if len(opts.users) < 1 && len(opts.groups) < 1 {
+		return nil, errors.Errorf("at least one -%s or -%s is required",
+			userFlag, groupFlag)
+	}
+
+	for _, name := range opts.users {
+		uid, err := lookupID(name, func(n string) (string, error) {
+			u, err := user.Lookup(n)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "unknown user '%s'", name)
+		}
+		d.uids[uid] = true
+	}
+
+	for _, name := range opts.groups {
+		gid, err := lookupID(name, func(n string) (string, error) {
+			g, err := user.LookupGroup(n)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "unknown group '%s'", name)
+		}
+		d.gids[gid] = true
+	}
+
+	for _, a := range strings.Split(opts.actions, ",") {
+		action := pipanel.PowerAction(strings.TrimSpace(a))
+		if len(action) < 1 {
+			continue
+		}
+
+		argv, ok := command(action, opts.unit)
+		if !ok {
+			return nil, errors.Errorf("unknown power action '%s'", action)
+		}
+
+		if _, dup := d.commands[action]; !dup {
+			d.actions = append(d.actions, action)
+		}
+		d.commands[action] = argv
+	}
+
+	if opts.limit < 1 || opts.window <= 0 {
+		return nil, errors.Errorf("-%s and -%s must be positive", limitFlag, windowFlag)
+	}
+	d.limiter = newLimiter(opts.limit, opts.window)
+
+	return &d, nil
+}
+
+// lookupID resolves a user or group given by name or numeric ID.
+func lookupID(name string, lookup func(string) (string, error)) (uint32, error) {
+	if id, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(id), nil
+	}
+
+	id, err := lookup(name)
+	if err != nil {
+		return 0, err
+	}
+
+	parsed, err := strconv.ParseUint(id, 10, 32)
+	return uint32(parsed), errors.Wrap(err, "malformed ID")
+}
+
+// peerCred returns the credentials of the process at the other end of the
+// connection, as reported by the kernel.
+func peerCred(conn *net.UnixConn) (*syscall.Ucred, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not access socket")
+	}
+
+	var cred *syscall.Ucred
+	var credErr error
+
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd),
+			syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err == nil {
+		err = credErr
+	}
+
+	return cred, errors.Wrap(err, "could not read peer credentials")
+}
+
+// authorized reports whether the peer is root, an allowed user, or a member of
+// an allowed group.
+func (d *daemon) authorized(cred *syscall.Ucred) bool {
+	if cred.Uid == 0 || d.uids[cred.Uid] || d.gids[cred.Gid] {
+		return true
+	}
+
+	if len(d.gids) < 1 {
+		return false
+	}
+
+	u, err := user.LookupId(strconv.FormatUint(uint64(cred.Uid), 10))
+	if err != nil {
+		return false
+	}
+
+	groups, err := u.GroupIds()
+	if err != nil {
+		logfmt.WithError(d.log, err).WithField("uid", cred.Uid).
+			Warnln("Could not look up the groups of peer.")
+		return false
+	}
+
+	for _, g := range groups {
+		if gid, err := strconv.ParseUint(g, 10, 32); err == nil && d.gids[uint32(gid)] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// serve answers a single request on the connection, then closes it.
+func (d *daemon) serve(conn *net.UnixConn) {
+	defer conn.Close()
+
+	log := d.log
+	respond := func(res powerd.Response) {
+		err := conn.SetDeadline(time.Now().Add(requestTimeout))
+		if err == nil {
+			err = json.NewEncoder(conn).Encode(res)
+		}
+		if err != nil {
+			logfmt.WithError(log, err).Warnln("Problem when writing response.")
+		}
+	}
+
+	cred, err := peerCred(conn)
+	if err != nil {
+		logfmt.WithError(log, err).Errorln("Problem when authenticating peer.")
+		return
+	}
+
+	log = log.WithFields(logrus.Fields{
+		"pid": cred.Pid,
+		"uid": cred.Uid,
+		"gid": cred.Gid,
+	})
+
+	// The request is read before refusing an unauthorized peer, so that the
+	// refusal is not lost to a connection reset.
+	var req powerd.Request
+
+	err = conn.SetDeadline(time.Now().Add(requestTimeout))
+	if err == nil {
+		dec := json.NewDecoder(conn)
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&req)
+	}
+
+	if err != nil {
+		logfmt.WithError(log, err).Warnln("Problem when reading request.")
+		respond(powerd.Response{Error: "malformed request"})
+		return
+	}
+
+	if !d.authorized(cred) {
+		log.Warnln("Refusing request from unauthorized peer.")
+		respond(powerd.Response{Error: "not authorized"})
+		return
+	}
+
+	if req.Capabilities {
+		respond(powerd.Response{Actions: d.actions})
+		return
+	}
+
+	log = log.WithFields(logrus.Fields{
+		"action": req.Action,
+		"reason": req.Reason,
+	})
+
+	argv, ok := d.commands[req.Action]
+	if !ok {
+		log.Warnln("Refusing power action that is not allowed.")
+		respond(powerd.Response{
+			Error:       fmt.Sprintf("power action '%s' is not allowed", req.Action),
+			Unsupported: true,
+		})
+		return
+	}
+
+	if wait, ok := d.limiter.allow(time.Now()); !ok {
+		log.WithField("wait", wait).Warnln("Refusing power action over rate limit.")
+		respond(powerd.Response{
+			Error: fmt.Sprintf("rate limit exceeded; try again in %s",
+				wait.Round(time.Second)),
+		})
+		return
+	}
+
+	log.Println("Performing power action.")
+
+	if err = d.perform(argv); err != nil {
+		logfmt.WithError(log, err).Errorln("Problem when performing power action.")
+		respond(powerd.Response{Error: err.Error()})
+		return
+	}
+
+	respond(powerd.Response{})
+}
+
+// perform runs the command line of a power action.
+func (d *daemon) perform(argv []string) error {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), actionTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, argv[0], argv[1:]...).CombinedOutput()
+	return errors.Wrapf(err, "%s failed: %s", argv[0], strings.TrimSpace(string(out)))
+}
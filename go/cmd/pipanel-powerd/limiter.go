@@ -0,0 +1,39 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// limiter allows at most limit events within any window of time.
+type limiter struct {
+	mux    sync.Mutex
+	limit  int
+	window time.Duration
+	// times are the times of the events within the last window, oldest first.
+	times []time.Time
+}
+
+func newLimiter(limit int, window time.Duration) *limiter {
+	return &limiter{limit: limit, window: window}
+}
+
+// allow records an event at now if the limit permits it. Otherwise, it returns
+// how long until the limit would permit it.
+func (l *limiter) allow(now time.Time) (time.Duration, bool) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	// Forget the events that have fallen out of the window.
+	cutoff := now.Add(-l.window)
+	for len(l.times) > 0 && !l.times[0].After(cutoff) {
+		l.times = l.times[1:]
+	}
+
+	if len(l.times) >= l.limit {
+		return l.times[0].Sub(cutoff), false
+	}
+
+	l.times = append(l.times, now)
+	return 0, true
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	type event struct {
+		at       time.Duration
+		wantOK   bool
+		wantWait time.Duration
+	}
+
+	tests := []struct {
+		name   string
+		limit  int
+		window time.Duration
+		events []event
+	}{
+		{
+			name:   "under the limit",
+			limit:  3,
+			window: time.Minute,
+			events: []event{
+				{at: 0, wantOK: true},
+				{at: time.Second, wantOK: true},
+				{at: 2 * time.Second, wantOK: true},
+			},
+		},
+		{
+			name:   "over the limit",
+			limit:  2,
+			window: time.Minute,
+			events: []event{
+				{at: 0, wantOK: true},
+				{at: 10 * time.Second, wantOK: true},
+				{at: 20 * time.Second, wantWait: 40 * time.Second},
+				{at: 50 * time.Second, wantWait: 10 * time.Second},
+			},
+		},
+		{
+			name:   "events fall out of the window",
+			limit:  2,
+			window: time.Minute,
+			events: []event{
+				{at: 0, wantOK: true},
+				{at: 10 * time.Second, wantOK: true},
+				{at: time.Minute, wantOK: true},
+				{at: 65 * time.Second, wantWait: 5 * time.Second},
+				{at: 70 * time.Second, wantOK: true},
+			},
+		},
+		{
+			name:   "refused events are not recorded",
+			limit:  1,
+			window: time.Minute,
+			events: []event{
+				{at: 0, wantOK: true},
+				{at: 30 * time.Second, wantWait: 30 * time.Second},
+				{at: 59 * time.Second, wantWait: time.Second},
+				{at: time.Minute, wantOK: true},
+			},
+		},
+	}
+
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLimiter(tt.limit, tt.window)
+
+			for _, e := range tt.events {
+				wait, ok := l.allow(start.Add(e.at))
+				if ok != e.wantOK || wait != e.wantWait {
+					t.Errorf("allow at %s = (%s, %t), want (%s, %t)",
+						e.at, wait, ok, e.wantWait, e.wantOK)
+				}
+			}
+		})
+	}
+}
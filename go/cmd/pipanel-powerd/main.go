@@ -0,0 +1,167 @@
+// Command pipanel-powerd performs system power actions on behalf of PiPanel.
+// It runs as root and listens on a Unix socket, so that the panel itself can
+// run without root privileges. Peers are authenticated by the credentials that
+// the kernel reports for the socket, and the power actions they request are
+// rate-limited.
+package main
+
+import (
+	"flag"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/BenJetson/pipanel/go/frontends/power_managers/powerd"
+	"github.com/BenJetson/pipanel/go/logfmt"
+)
+
+// Command line flag constants.
+const (
+	socketFlag = "socket"
+	socketDesc = "path of the Unix socket to listen on"
+
+	userFlag = "user"
+	userDesc = "name or ID of a user allowed to request power actions; " +
+		"may be repeated"
+
+	groupFlag = "group"
+	groupDesc = "name or ID of a group whose members are allowed to request " +
+		"power actions; may be repeated"
+
+	actionsFlag    = "actions"
+	actionsDefault = "shutdown,reboot,suspend,hibernate,restartService,reloadConfig"
+	actionsDesc    = "comma-separated power actions that may be requested"
+
+	unitFlag    = "unit"
+	unitDefault = "pipanel.service"
+	unitDesc    = "systemd unit that runs PiPanel, for the restartService " +
+		"and reloadConfig actions"
+
+	limitFlag    = "limit"
+	limitDefault = 3
+	limitDesc    = "most power actions performed within each window"
+
+	windowFlag    = "window"
+	windowDefault = 10 * time.Minute
+	windowDesc    = "period over which power actions are rate-limited"
+)
+
+// stringList is a flag.Value that collects every occurrence of a flag.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+type options struct {
+	socket  string
+	users   stringList
+	groups  stringList
+	actions string
+	unit    string
+	limit   int
+	window  time.Duration
+}
+
+func parseFlags() options {
+	var opts options
+
+	// Set up command line flags.
+	flag.StringVar(&opts.socket, socketFlag, powerd.SocketDefault, socketDesc)
+	flag.Var(&opts.users, userFlag, userDesc)
+	flag.Var(&opts.groups, groupFlag, groupDesc)
+	flag.StringVar(&opts.actions, actionsFlag, actionsDefault, actionsDesc)
+	flag.StringVar(&opts.unit, unitFlag, unitDefault, unitDesc)
+	flag.IntVar(&opts.limit, limitFlag, limitDefault, limitDesc)
+	flag.DurationVar(&opts.window, windowFlag, windowDefault, windowDesc)
+
+	// Read command line flags.
+	flag.Parse()
+
+	return opts
+}
+
+// listen listens on the Unix socket at path, replacing a stale socket left
+// behind by an earlier run. Any local user may connect; peers are
+// authenticated by their credentials instead.
+func listen(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, errors.Wrap(err, "could not create socket directory")
+	}
+
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err = os.Remove(path); err != nil {
+			return nil, errors.Wrap(err, "could not remove stale socket")
+		}
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not listen on socket")
+	}
+
+	if err = os.Chmod(path, 0666); err != nil {
+		l.Close()
+		return nil, errors.Wrap(err, "could not set socket permissions")
+	}
+
+	return l, nil
+}
+
+func main() {
+	logger := logrus.New()
+	log := logrus.NewEntry(logger)
+
+	opts := parseFlags()
+
+	d, err := newDaemon(log, opts)
+	if err != nil {
+		logfmt.WithError(log, err).Fatalln("Problem with command line flags.")
+	}
+
+	l, err := listen(opts.socket)
+	if err != nil {
+		logfmt.WithError(log, err).Fatalln("Problem when creating socket.")
+	}
+
+	// Stop accepting connections when a SIGINT or SIGTERM is detected.
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-interrupt
+		log.Println("Interrupt detected; shutting down...")
+		l.Close()
+	}()
+
+	log.WithFields(logrus.Fields{
+		"socket":  opts.socket,
+		"actions": opts.actions,
+	}).Println("Listening for power actions.")
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				logfmt.WithError(log, err).Warnln("Problem when accepting connection.")
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			break
+		}
+
+		go d.serve(conn.(*net.UnixConn))
+	}
+
+	log.Println("Goodbye!")
+}
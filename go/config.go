@@ -1,10 +1,61 @@
 package pipanel
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
 
-// ServerConfig contains configuration for
+	"github.com/pkg/errors"
+)
+
+// Duration is a time.Duration that is written in configuration files either as
+// a string understood by time.ParseDuration, such as "10s", or as a number of
+// nanoseconds.
+type Duration time.Duration
+
+// UnmarshalJSON decodes a Duration from a string or a number.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		parsed, err := time.ParseDuration(s)
+		if err != nil {
+			return errors.Wrap(err, "invalid duration")
+		}
+
+		*d = Duration(parsed)
+		return nil
+	}
+
+	var n int64
+	if err := json.Unmarshal(b, &n); err != nil {
+		return errors.New("duration must be a string or an integer")
+	}
+
+	*d = Duration(n)
+	return nil
+}
+
+// MarshalJSON encodes a Duration as a string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// JSONSchema describes the JSON representation of a Duration.
+func (Duration) JSONSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": []string{"string", "integer"},
+		"description": "duration such as \"10s\" or \"1m30s\", " +
+			"or an integer number of nanoseconds",
+	}
+}
+
+// ServerConfig contains configuration for the PiPanel server.
 type ServerConfig struct {
-	Port int
+	// Port is the TCP port that the server will listen on.
+	Port int `json:"port"`
+	// AuthToken is the bearer token that clients must present in the
+	// Authorization header of every request. If empty, requests are not
+	// authenticated.
+	AuthToken string `json:"auth_token,omitempty"`
 }
 
 // FrontendConfig contains configuration for each of the frontend compoments.
@@ -23,6 +74,89 @@ type FrontendConfig struct {
 	// DisplayManagerConfig is the raw JSON object that will be passed to the
 	// chosen DisplayManager implementation upon instantiation.
 	DisplayManagerConfig json.RawMessage `json:"display_manager,omitempty"`
+	// AlertWake is the policy for waking the display when an alert arrives.
+	// If not set, alerts do not change the display.
+	AlertWake *AlertWakeConfig `json:"alert_wake,omitempty"`
+	// PendingPower decides what happens when a delayed power action is
+	// requested while another is pending. Defaults to PendingPowerReplace if
+	// not set.
+	PendingPower PendingPowerPolicy `json:"pending_power,omitempty"`
+}
+
+// alertWakeGraceDefault is the grace period used if none is configured.
+const alertWakeGraceDefault = Duration(10 * time.Second)
+
+// alertWakeMaxHoldDefault is the maximum hold used if none is configured.
+const alertWakeMaxHoldDefault = Duration(10 * time.Minute)
+
+// AlertWakeConfig contains the policy for waking the display when an alert
+// arrives, so that the alert can be seen.
+type AlertWakeConfig struct {
+	// DisplayOn turns the display on when an alert arrives, should it be off
+	// or in standby.
+	DisplayOn bool `json:"display_on"`
+	// Levels maps alert priorities to the brightness level that the display
+	// is raised to, if it is dimmer, when an alert of that priority arrives.
+	// Alerts without a priority count as "normal". Priorities not listed
+	// leave the brightness as it is.
+	Levels map[AlertPriority]uint8 `json:"levels,omitempty"`
+	// Grace is how long after the last alert is dismissed the prior display
+	// state is restored. Defaults to ten seconds if not set.
+	Grace Duration `json:"grace,omitempty"`
+	// MaxHold is how long the display is kept awake for a perpetual alert
+	// shown by an Alerter that does not report dismissals, since there is no
+	// telling when it is dismissed. Defaults to ten minutes if not set.
+	MaxHold Duration `json:"max_hold,omitempty"`
+}
+
+// Validate checks that the policy values are sensible.
+func (c *AlertWakeConfig) Validate() error {
+	if c.Grace < 0 {
+		return errors.New("grace cannot be negative")
+	} else if c.MaxHold < 0 {
+		return errors.New("max_hold cannot be negative")
+	}
+
+	for p := range c.Levels {
+		if !p.Valid() || len(p) < 1 {
+			return errors.Errorf("unknown alert priority '%s'", p)
+		}
+	}
+
+	return nil
+}
+
+// PendingPowerPolicy decides what happens when a delayed power action is
+// requested while another is pending, since only one may be pending at a time.
+type PendingPowerPolicy string
+
+const (
+	// PendingPowerReplace cancels the pending action in favor of the new one.
+	PendingPowerReplace PendingPowerPolicy = "replace"
+	// PendingPowerReject refuses the new action, keeping the pending one.
+	PendingPowerReject PendingPowerPolicy = "reject"
+)
+
+// ShutdownPolicy decides what happens to work in progress, such as alert
+// windows and text-to-speech playback, when the panel shuts down.
+type ShutdownPolicy string
+
+const (
+	// ShutdownFinish waits for work in progress to finish, up to the
+	// shutdown timeout, before tearing down the frontend.
+	ShutdownFinish ShutdownPolicy = "finish"
+	// ShutdownInterrupt cuts off work in progress immediately.
+	ShutdownInterrupt ShutdownPolicy = "interrupt"
+)
+
+// ShutdownConfig contains configuration for shutting down the panel.
+type ShutdownConfig struct {
+	// Timeout bounds the time spent draining in-flight requests and waiting
+	// for work in progress to finish. Defaults to ten seconds if not set.
+	Timeout Duration `json:"timeout,omitempty"`
+	// Policy decides whether work in progress is allowed to finish. Defaults
+	// to ShutdownFinish if not set.
+	Policy ShutdownPolicy `json:"policy,omitempty"`
 }
 
 // Config is the format for the program's configuration file.
@@ -33,4 +167,9 @@ type Config struct {
 	// Frontend contains the configuration that will be passed to the PiPanel
 	// frontend upon instantiation.
 	Frontend FrontendConfig `json:"frontend"`
+	// Shutdown contains the configuration for shutting down the panel.
+	Shutdown ShutdownConfig `json:"shutdown,omitempty"`
+	// Controllers maps the name of each controller that should run to the raw
+	// JSON object that will be passed to it upon instantiation.
+	Controllers map[string]json.RawMessage `json:"controllers,omitempty"`
 }
@@ -0,0 +1,240 @@
+// Package configfile loads the PiPanel configuration from disk.
+//
+// The configuration file may be written in JSON, YAML or TOML; the format is
+// chosen by the file extension. Regardless of format, the file is converted to
+// JSON before being decoded into a pipanel.Config, so component configurations
+// may be written in any of the supported formats.
+//
+// After the file has been read, any field may be overridden by an environment
+// variable, and string values may refer to the contents of other files. See
+// ApplyEnvOverrides and ResolveFileRefs for details.
+package configfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+)
+
+// Format is a configuration file format.
+type Format string
+
+const (
+	// FormatJSON is the JSON configuration file format.
+	FormatJSON Format = "json"
+	// FormatYAML is the YAML configuration file format.
+	FormatYAML Format = "yaml"
+	// FormatTOML is the TOML configuration file format.
+	FormatTOML Format = "toml"
+)
+
+// FormatOf determines the format of the configuration file at the given path
+// from its extension.
+func FormatOf(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".toml":
+		return FormatTOML, nil
+	}
+
+	return "", errors.Errorf("unsupported configuration file extension '%s'",
+		filepath.Ext(path))
+}
+
+// ExpandHome replaces a leading "~" in the given path with the home directory
+// of the current user.
+func ExpandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.Wrap(err, "could not determine home directory")
+	}
+
+	return filepath.Join(home, path[1:]), nil
+}
+
+// File is a configuration file that has been read from disk and parsed, with
+// environment overrides applied and file references resolved.
+type File struct {
+	// Path is the location of the file, with any leading "~" expanded.
+	Path string
+	// Format is the format of the file.
+	Format Format
+	// Data is the raw content of the file.
+	Data []byte
+	// Tree is the generic representation of the configuration.
+	Tree map[string]interface{}
+}
+
+// ReadFile reads the configuration file at the given path, applies overrides
+// from the environment and resolves file references.
+func ReadFile(path string) (*File, error) {
+	path, err := ExpandHome(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f := File{Path: path}
+
+	if f.Format, err = FormatOf(path); err != nil {
+		return nil, err
+	}
+
+	if f.Data, err = ioutil.ReadFile(path); err != nil {
+		return nil, errors.Wrapf(err,
+			"failed to load configuration file at path '%s'", path)
+	}
+
+	if f.Tree, err = Parse(f.Format, f.Data); err != nil {
+		return nil, err
+	}
+
+	if err = ApplyEnvOverrides(f.Tree, os.Environ()); err != nil {
+		return nil, errors.Wrap(err, "bad environment override")
+	}
+
+	if err = ResolveFileRefs(f.Tree); err != nil {
+		return nil, errors.Wrap(err, "bad file reference")
+	}
+
+	return &f, nil
+}
+
+// Load reads the configuration file at the given path, applies overrides from
+// the environment, resolves file references and decodes the result.
+func Load(path string) (*pipanel.Config, error) {
+	f, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Decode(f.Tree)
+}
+
+// Parse decodes configuration data in the given format into a generic tree of
+// maps, slices and scalar values.
+func Parse(format Format, data []byte) (map[string]interface{}, error) {
+	var tree map[string]interface{}
+	var err error
+
+	switch format {
+	case FormatJSON:
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.UseNumber()
+
+		if err = d.Decode(&tree); err != nil {
+			if se, ok := err.(*json.SyntaxError); ok {
+				err = &FieldError{Line: lineAt(data, se.Offset), Err: se}
+			}
+			err = errors.Wrap(err, "bad JSON formatting")
+		}
+	case FormatYAML:
+		err = errors.Wrap(yaml.Unmarshal(data, &tree), "bad YAML formatting")
+	case FormatTOML:
+		err = errors.Wrap(toml.Unmarshal(data, &tree), "bad TOML formatting")
+	default:
+		err = errors.Errorf("unknown configuration format '%s'", format)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if tree == nil {
+		tree = make(map[string]interface{})
+	}
+
+	normalized, err := normalize(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	return normalized.(map[string]interface{}), nil
+}
+
+// normalize converts maps with non-string keys, as produced by some YAML
+// documents, into maps with string keys so that the tree may be encoded as
+// JSON.
+func normalize(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			n, err := normalize(child)
+			if err != nil {
+				return nil, err
+			}
+			t[k] = n
+		}
+		return t, nil
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, child := range t {
+			key, ok := k.(string)
+			if !ok {
+				return nil, errors.Errorf("map key '%v' is not a string", k)
+			}
+			n, err := normalize(child)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = n
+		}
+		return m, nil
+	case []interface{}:
+		for i, child := range t {
+			n, err := normalize(child)
+			if err != nil {
+				return nil, err
+			}
+			t[i] = n
+		}
+		return t, nil
+	case []map[string]interface{}:
+		s := make([]interface{}, len(t))
+		for i, child := range t {
+			n, err := normalize(child)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = n
+		}
+		return s, nil
+	}
+
+	return v, nil
+}
+
+// Decode converts the generic configuration tree to JSON and decodes it into
+// a pipanel.Config. Unknown fields are rejected.
+func Decode(tree map[string]interface{}) (*pipanel.Config, error) {
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to convert configuration to JSON")
+	}
+
+	var cfg pipanel.Config
+
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+
+	if err = d.Decode(&cfg); err != nil {
+		return nil, errors.Wrap(err, "configuration does not match schema")
+	}
+
+	return &cfg, nil
+}
@@ -0,0 +1,338 @@
+package configfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// FieldError describes a problem with a single field of the configuration.
+type FieldError struct {
+	// Path is the dotted path to the field, such as "frontend.alerter".
+	// Empty for problems with the configuration as a whole.
+	Path string
+	// Line is the line of the configuration file on which the field appears,
+	// or zero if unknown.
+	Line int
+	// Err describes the problem.
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	loc := e.Path
+	if len(loc) < 1 {
+		loc = "(root)"
+	}
+
+	if e.Line > 0 {
+		return fmt.Sprintf("%s (line %d): %v", loc, e.Line, e.Err)
+	}
+
+	return fmt.Sprintf("%s: %v", loc, e.Err)
+}
+
+var (
+	rawMessageType  = reflect.TypeOf(json.RawMessage(nil))
+	durationType    = reflect.TypeOf(time.Duration(0))
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// JoinPath appends a key to a dotted field path.
+func JoinPath(path, key string) string {
+	if len(path) < 1 {
+		return key
+	}
+
+	return path + "." + key
+}
+
+// jsonField describes a field of a struct as seen by encoding/json.
+type jsonField struct {
+	name string
+	typ  reflect.Type
+}
+
+// jsonFields lists the fields of the given struct type under the names that
+// encoding/json would use, promoting the fields of embedded structs.
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+
+		if f.Anonymous && len(name) < 1 {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				fields = append(fields, jsonFields(ft)...)
+				continue
+			}
+		}
+
+		if f.PkgPath != "" {
+			// Unexported fields are ignored by encoding/json.
+			continue
+		}
+
+		if len(name) < 1 {
+			name = f.Name
+		}
+
+		fields = append(fields, jsonField{name: name, typ: f.Type})
+	}
+
+	return fields
+}
+
+// lookupField finds the field for the given key the same way encoding/json
+// does: an exact match is preferred, but case is otherwise ignored.
+func lookupField(fields []jsonField, key string) (jsonField, bool) {
+	for _, f := range fields {
+		if f.name == key {
+			return f, true
+		}
+	}
+
+	for _, f := range fields {
+		if strings.EqualFold(f.name, key) {
+			return f, true
+		}
+	}
+
+	return jsonField{}, false
+}
+
+// isOpaque reports whether values of the given type cannot be described by
+// reflection, either because they are raw JSON or because they decode
+// themselves.
+func isOpaque(t reflect.Type) bool {
+	return t == rawMessageType || t.Kind() == reflect.Interface ||
+		reflect.PtrTo(t).Implements(unmarshalerType)
+}
+
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case json.Number, float64, float32, int, int64, int32, uint64, uint32:
+		return true
+	}
+
+	return false
+}
+
+// describeValue names the kind of a value within a generic tree.
+func describeValue(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	}
+
+	if isNumber(v) {
+		return "a number"
+	}
+
+	return fmt.Sprintf("%T", v)
+}
+
+// CheckFields compares a generic configuration tree, as returned by Parse,
+// against the structure of template, which should be a pointer to a zero
+// value of a configuration structure. An error is returned for every unknown
+// field and for every value of the wrong type; fields of type json.RawMessage
+// are not checked. The given path is the location of the tree within the
+// configuration file.
+func CheckFields(path string, tree interface{}, template interface{}) []*FieldError {
+	return checkValue(path, tree, reflect.TypeOf(template))
+}
+
+// nolint: gocyclo // one case per kind reads better than splitting these up
+func checkValue(path string, v interface{}, t reflect.Type) []*FieldError {
+	if v == nil {
+		// A null value leaves the field at its zero value.
+		return nil
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if isOpaque(t) {
+		return nil
+	}
+
+	fail := func(want string) []*FieldError {
+		return []*FieldError{{
+			Path: path,
+			Err:  errors.Errorf("expected %s, found %s", want, describeValue(v)),
+		}}
+	}
+
+	var errs []*FieldError
+
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return fail("an object")
+		}
+
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		fields := jsonFields(t)
+		for _, k := range keys {
+			f, ok := lookupField(fields, k)
+			if !ok {
+				errs = append(errs, &FieldError{
+					Path: JoinPath(path, k),
+					Err:  errors.New("unknown field"),
+				})
+				continue
+			}
+
+			errs = append(errs, checkValue(JoinPath(path, k), m[k], f.typ)...)
+		}
+	case reflect.Map:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return fail("an object")
+		}
+
+		for k, child := range m {
+			errs = append(errs, checkValue(JoinPath(path, k), child, t.Elem())...)
+		}
+	case reflect.Slice, reflect.Array:
+		s, ok := v.([]interface{})
+		if !ok {
+			return fail("an array")
+		}
+
+		for i, child := range s {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			errs = append(errs, checkValue(p, child, t.Elem())...)
+		}
+	case reflect.String:
+		if _, ok := v.(string); !ok {
+			return fail("a string")
+		}
+	case reflect.Bool:
+		if _, ok := v.(bool); !ok {
+			return fail("a boolean")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Float32, reflect.Float64:
+		if !isNumber(v) {
+			return fail("a number")
+		}
+	}
+
+	return errs
+}
+
+// Schema is a JSON Schema document.
+type Schema map[string]interface{}
+
+// SchemaDescriber may be implemented by configuration types that decode
+// themselves, in order to describe their JSON representation.
+//
+// JSONSchema returns a plain map rather than a Schema so that packages which
+// this package imports, such as pipanel, can implement it.
+type SchemaDescriber interface {
+	JSONSchema() map[string]interface{}
+}
+
+var schemaDescriberType = reflect.TypeOf((*SchemaDescriber)(nil)).Elem()
+
+// SchemaOf generates a JSON Schema describing the JSON representation of
+// template, which should be a pointer to a zero value of a configuration
+// structure. Fields of type json.RawMessage accept any value.
+func SchemaOf(template interface{}) Schema {
+	return schemaOf(reflect.TypeOf(template))
+}
+
+// nolint: gocyclo // one case per kind reads better than splitting these up
+func schemaOf(t reflect.Type) Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Implements(schemaDescriberType) {
+		return Schema(reflect.Zero(t).Interface().(SchemaDescriber).JSONSchema())
+	}
+
+	if isOpaque(t) {
+		return Schema{}
+	}
+
+	if t == durationType {
+		return Schema{
+			"type":        "integer",
+			"description": "duration in nanoseconds",
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		props := make(map[string]Schema)
+		for _, f := range jsonFields(t) {
+			props[f.name] = schemaOf(f.typ)
+		}
+
+		return Schema{
+			"type":                 "object",
+			"properties":           props,
+			"additionalProperties": false,
+		}
+	case reflect.Map:
+		return Schema{
+			"type":                 "object",
+			"additionalProperties": schemaOf(t.Elem()),
+		}
+	case reflect.Slice, reflect.Array:
+		return Schema{
+			"type":  "array",
+			"items": schemaOf(t.Elem()),
+		}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	}
+
+	return Schema{}
+}
+
+// Properties returns the property schemas of an object schema generated by
+// SchemaOf, so that they may be refined.
+func (s Schema) Properties() map[string]Schema {
+	props, _ := s["properties"].(map[string]Schema)
+	return props
+}
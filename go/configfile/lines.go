@@ -0,0 +1,123 @@
+package configfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LineIndex maps dotted field paths within a configuration file to the line
+// numbers on which those fields appear.
+type LineIndex map[string]int
+
+// IndexLines builds a LineIndex for configuration data in the given format.
+// Line numbers are available for JSON and YAML only; for other formats, or
+// data that cannot be parsed, the index is empty.
+func IndexLines(format Format, data []byte) LineIndex {
+	idx := make(LineIndex)
+
+	switch format {
+	case FormatJSON:
+		indexJSON(data, idx)
+	case FormatYAML:
+		var doc yaml.Node
+		if yaml.Unmarshal(data, &doc) == nil && len(doc.Content) > 0 {
+			indexYAML(doc.Content[0], "", idx)
+		}
+	}
+
+	return idx
+}
+
+// Line returns the line of the field at path, or of its nearest ancestor that
+// appears in the index. Returns zero if neither can be found.
+func (idx LineIndex) Line(path string) int {
+	for {
+		if line, ok := idx[path]; ok {
+			return line
+		}
+
+		cut := strings.LastIndexAny(path, ".[")
+		if cut < 0 {
+			return 0
+		}
+
+		path = path[:cut]
+	}
+}
+
+// lineAt returns the line number of the given byte offset within data.
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+func indexJSON(data []byte, idx LineIndex) {
+	d := json.NewDecoder(bytes.NewReader(data))
+
+	var walk func(path string) error
+	walk = func(path string) error {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		switch tok {
+		case json.Delim('{'):
+			for d.More() {
+				if tok, err = d.Token(); err != nil {
+					return err
+				}
+
+				p := JoinPath(path, fmt.Sprint(tok))
+				idx[p] = lineAt(data, d.InputOffset())
+
+				if err = walk(p); err != nil {
+					return err
+				}
+			}
+
+			_, err = d.Token()
+		case json.Delim('['):
+			for i := 0; d.More(); i++ {
+				p := fmt.Sprintf("%s[%d]", path, i)
+				idx[p] = lineAt(data, d.InputOffset())
+
+				if err = walk(p); err != nil {
+					return err
+				}
+			}
+
+			_, err = d.Token()
+		}
+
+		return err
+	}
+
+	// Errors only mean that the index is incomplete; parsing reports them.
+	_ = walk("")
+}
+
+func indexYAML(n *yaml.Node, path string, idx LineIndex) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			p := JoinPath(path, key.Value)
+			idx[p] = key.Line
+			indexYAML(value, p, idx)
+		}
+	case yaml.SequenceNode:
+		for i, child := range n.Content {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			idx[p] = child.Line
+			indexYAML(child, p, idx)
+		}
+	}
+}
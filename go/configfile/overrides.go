@@ -0,0 +1,286 @@
+package configfile
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+)
+
+// EnvPrefix is the prefix of environment variables that override fields of
+// the configuration file.
+const EnvPrefix = "PIPANEL_"
+
+// envPathSeparator separates the keys of nested objects in the name of an
+// override environment variable. A double underscore is used because single
+// underscores appear in the keys themselves.
+const envPathSeparator = "__"
+
+// ApplyEnvOverrides overrides fields of the configuration tree using the given
+// environment, which is in the format returned by os.Environ.
+//
+// The name of an override variable is EnvPrefix followed by the path to the
+// field, with the keys of nested objects separated by a double underscore.
+// Keys are matched case-insensitively. For example, the variable
+//
+//	PIPANEL_FRONTEND__ALERTER__GTK_ALERTER__FONT_SIZE=32
+//
+// sets the font_size field of the gtk_alerter object within the alerter
+// object of the frontend object, creating any objects that do not exist.
+//
+// Each value is converted to the type of the field that it overrides, as
+// declared by pipanel.Config: strings are taken as they are, booleans and
+// numbers are parsed, and objects and arrays are decoded from JSON. Fields
+// that decode themselves, such as a pipanel.Duration, are given a number if
+// the value is one and a string otherwise. The types of the fields of
+// component configurations are only known to the components themselves, so
+// such a field takes the type of the value that it replaces, and is a string
+// if the configuration file does not set it.
+//
+// Variables that do not name a field of the configuration are ignored, since
+// other programs may use the same prefix.
+func ApplyEnvOverrides(tree map[string]interface{}, environ []string) error {
+	return applyEnvOverrides(tree, environ, reflect.TypeOf(pipanel.Config{}))
+}
+
+// applyEnvOverrides overrides fields of the tree, which describes a value of
+// type root.
+func applyEnvOverrides(tree map[string]interface{}, environ []string,
+	root reflect.Type) error {
+
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, EnvPrefix) {
+			continue
+		}
+
+		eq := strings.IndexByte(kv, '=')
+		if eq < 0 {
+			continue
+		}
+
+		name, value := kv[len(EnvPrefix):eq], kv[eq+1:]
+		path := strings.Split(strings.ToLower(name), envPathSeparator)
+
+		t, ok := envFieldType(root, path)
+		if !ok {
+			continue
+		}
+
+		err := setPath(tree, path, func(old interface{}) (interface{}, error) {
+			return parseEnvValue(value, t, old)
+		})
+		if err != nil {
+			return errors.Wrapf(err, "cannot apply %s", kv[:eq])
+		}
+	}
+
+	return nil
+}
+
+// envFieldType finds the type of the field at the given path within values of
+// type t. The type is nil for raw JSON values, such as component
+// configurations, and for fields within opaque values, whose structure is
+// unknown. False is returned if the path does not name a field.
+func envFieldType(t reflect.Type, path []string) (reflect.Type, bool) {
+	for _, key := range path {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		if isOpaque(t) {
+			return nil, true
+		}
+
+		switch t.Kind() {
+		case reflect.Struct:
+			f, ok := lookupField(jsonFields(t), key)
+			if !ok {
+				return nil, false
+			}
+			t = f.typ
+		case reflect.Map:
+			t = t.Elem()
+		default:
+			return nil, false
+		}
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == rawMessageType || t.Kind() == reflect.Interface {
+		return nil, true
+	}
+
+	return t, true
+}
+
+// parseEnvValue converts value to the given type of field. If the type is
+// unknown, value is converted to the type of the old value of the field
+// instead, or is left as a string if there is no old value.
+//
+// Numbers are kept as json.Number so that large integers do not lose
+// precision.
+func parseEnvValue(value string, t reflect.Type, old interface{}) (interface{}, error) {
+	if t != nil && reflect.PtrTo(t).Implements(unmarshalerType) {
+		return numberOrString(value), nil
+	}
+
+	kind := reflect.String
+	if t != nil {
+		kind = t.Kind()
+	} else if isNumber(old) {
+		// The field may decode itself from a string as well, as a
+		// pipanel.Duration does, so a value that is not a number is not
+		// rejected here.
+		return numberOrString(value), nil
+	} else {
+		switch old.(type) {
+		case bool:
+			kind = reflect.Bool
+		case map[string]interface{}:
+			kind = reflect.Map
+		case []interface{}:
+			kind = reflect.Slice
+		}
+	}
+
+	switch kind {
+	case reflect.String:
+		return value, nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		return b, errors.Wrapf(err, "'%s' is not a boolean", value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		_, err := strconv.ParseInt(value, 10, t.Bits())
+		return json.Number(value), errors.Wrapf(err, "'%s' is not an integer", value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		_, err := strconv.ParseUint(value, 10, t.Bits())
+		return json.Number(value), errors.Wrapf(err,
+			"'%s' is not a non-negative integer", value)
+	case reflect.Float32, reflect.Float64:
+		_, err := strconv.ParseFloat(value, 64)
+		return json.Number(value), errors.Wrapf(err, "'%s' is not a number", value)
+	}
+
+	var v interface{}
+
+	d := json.NewDecoder(strings.NewReader(value))
+	d.UseNumber()
+
+	err := d.Decode(&v)
+	return v, errors.Wrapf(err, "'%s' is not valid JSON", value)
+}
+
+// numberOrString returns value as a json.Number if it is a number, or as a
+// string otherwise.
+func numberOrString(value string) interface{} {
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return json.Number(value)
+	}
+
+	return value
+}
+
+// setPath sets the field at the given path within the tree to the result of
+// value, which is given the old value of the field, or nil if it is not set.
+func setPath(tree map[string]interface{}, path []string,
+	value func(old interface{}) (interface{}, error)) error {
+
+	for i, key := range path {
+		if len(key) < 1 {
+			return errors.New("empty key in path")
+		}
+
+		// Prefer an existing key that matches regardless of case.
+		for k := range tree {
+			if strings.EqualFold(k, key) {
+				key = k
+				break
+			}
+		}
+
+		if i == len(path)-1 {
+			v, err := value(tree[key])
+			if err != nil {
+				return err
+			}
+
+			tree[key] = v
+			return nil
+		}
+
+		child, ok := tree[key]
+		if !ok || child == nil {
+			child = make(map[string]interface{})
+			tree[key] = child
+		}
+
+		if tree, ok = child.(map[string]interface{}); !ok {
+			return errors.Errorf("field '%s' is not an object",
+				strings.Join(path[:i+1], "."))
+		}
+	}
+
+	return nil
+}
+
+// fileRefPattern matches file references of the form ${file:/path/to/file}.
+var fileRefPattern = regexp.MustCompile(`\$\{file:([^}]+)\}`)
+
+// ResolveFileRefs replaces every file reference within the string values of
+// the configuration tree with the contents of the referenced file, minus any
+// trailing newline. A file reference takes the form
+//
+//	${file:/run/secrets/token}
+//
+// which allows secrets to be kept out of the configuration file itself.
+func ResolveFileRefs(tree map[string]interface{}) error {
+	_, err := resolveFileRefs(tree)
+	return err
+}
+
+func resolveFileRefs(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			r, err := resolveFileRefs(child)
+			if err != nil {
+				return nil, err
+			}
+			t[k] = r
+		}
+	case []interface{}:
+		for i, child := range t {
+			r, err := resolveFileRefs(child)
+			if err != nil {
+				return nil, err
+			}
+			t[i] = r
+		}
+	case string:
+		var err error
+
+		resolved := fileRefPattern.ReplaceAllStringFunc(t, func(ref string) string {
+			path := fileRefPattern.FindStringSubmatch(ref)[1]
+
+			data, readErr := ioutil.ReadFile(path)
+			if readErr != nil && err == nil {
+				err = errors.Wrapf(readErr, "could not read file '%s'", path)
+			}
+
+			return strings.TrimRight(string(data), "\r\n")
+		})
+
+		return resolved, err
+	}
+
+	return v, nil
+}
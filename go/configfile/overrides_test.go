@@ -0,0 +1,225 @@
+package configfile
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+)
+
+// testConfig has a field of each kind that overrides are converted to, so
+// that the tests do not depend on the fields of pipanel.Config.
+type testConfig struct {
+	Server struct {
+		Port      int    `json:"port"`
+		AuthToken string `json:"auth_token"`
+	} `json:"server"`
+	Frontend struct {
+		Name      string          `json:"name"`
+		Alerter   json.RawMessage `json:"alerter"`
+		AlertWake struct {
+			DisplayOn bool             `json:"display_on"`
+			Levels    map[string]uint8 `json:"levels"`
+		} `json:"alert_wake"`
+	} `json:"frontend"`
+	Shutdown *struct {
+		Timeout string           `json:"timeout"`
+		Delay   pipanel.Duration `json:"delay"`
+	} `json:"shutdown"`
+}
+
+func TestApplyEnvOverrides(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		environ []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "integer field",
+			file:    `{"server": {"port": 8080}}`,
+			environ: []string{"PIPANEL_SERVER__PORT=9090"},
+			want:    `{"server": {"port": 9090}}`,
+		},
+		{
+			name:    "creates missing objects",
+			file:    `{}`,
+			environ: []string{"PIPANEL_SERVER__PORT=9090"},
+			want:    `{"server": {"port": 9090}}`,
+		},
+		{
+			name:    "keys are case-insensitive",
+			file:    `{"Server": {"Port": 8080}}`,
+			environ: []string{"PIPANEL_SERVER__PORT=9090"},
+			want:    `{"Server": {"Port": 9090}}`,
+		},
+		{
+			name:    "numeric string field stays a string",
+			file:    `{}`,
+			environ: []string{"PIPANEL_SERVER__AUTH_TOKEN=123456"},
+			want:    `{"server": {"auth_token": "123456"}}`,
+		},
+		{
+			name:    "boolean string field stays a string",
+			file:    `{}`,
+			environ: []string{"PIPANEL_FRONTEND__NAME=true"},
+			want:    `{"frontend": {"name": "true"}}`,
+		},
+		{
+			name:    "boolean field",
+			file:    `{}`,
+			environ: []string{"PIPANEL_FRONTEND__ALERT_WAKE__DISPLAY_ON=true"},
+			want:    `{"frontend": {"alert_wake": {"display_on": true}}}`,
+		},
+		{
+			name:    "bad boolean",
+			file:    `{}`,
+			environ: []string{"PIPANEL_FRONTEND__ALERT_WAKE__DISPLAY_ON=maybe"},
+			wantErr: true,
+		},
+		{
+			name:    "bad integer",
+			file:    `{}`,
+			environ: []string{"PIPANEL_SERVER__PORT=eighty"},
+			wantErr: true,
+		},
+		{
+			name:    "map field",
+			file:    `{}`,
+			environ: []string{"PIPANEL_FRONTEND__ALERT_WAKE__LEVELS__HIGH=80"},
+			want:    `{"frontend": {"alert_wake": {"levels": {"high": 80}}}}`,
+		},
+		{
+			name:    "object field is decoded",
+			file:    `{}`,
+			environ: []string{`PIPANEL_SHUTDOWN={"timeout": "5s"}`},
+			want:    `{"shutdown": {"timeout": "5s"}}`,
+		},
+		{
+			name: "component field takes type of old value",
+			file: `{"frontend": {"alerter": {"font_size": 24, "title": "x"}}}`,
+			environ: []string{
+				"PIPANEL_FRONTEND__ALERTER__FONT_SIZE=32",
+				"PIPANEL_FRONTEND__ALERTER__TITLE=1234",
+			},
+			want: `{"frontend": {"alerter": {"font_size": 32, "title": "1234"}}}`,
+		},
+		{
+			name:    "new component field is a string",
+			file:    `{}`,
+			environ: []string{"PIPANEL_FRONTEND__ALERTER__TOKEN=007"},
+			want:    `{"frontend": {"alerter": {"token": "007"}}}`,
+		},
+		{
+			name:    "component number may become a string",
+			file:    `{"frontend": {"alerter": {"shutdown_delay": 60000}}}`,
+			environ: []string{"PIPANEL_FRONTEND__ALERTER__SHUTDOWN_DELAY=10s"},
+			want:    `{"frontend": {"alerter": {"shutdown_delay": "10s"}}}`,
+		},
+		{
+			name:    "self-decoding field given a string",
+			file:    `{"shutdown": {"delay": 60000}}`,
+			environ: []string{"PIPANEL_SHUTDOWN__DELAY=10s"},
+			want:    `{"shutdown": {"delay": "10s"}}`,
+		},
+		{
+			name:    "self-decoding field given a number",
+			file:    `{"shutdown": {"delay": "10s"}}`,
+			environ: []string{"PIPANEL_SHUTDOWN__DELAY=60000"},
+			want:    `{"shutdown": {"delay": 60000}}`,
+		},
+		{
+			name:    "large integers keep their precision",
+			file:    `{}`,
+			environ: []string{"PIPANEL_SERVER__PORT=9007199254740993"},
+			want:    `{"server": {"port": 9007199254740993}}`,
+		},
+		{
+			name: "unknown paths are ignored",
+			file: `{}`,
+			environ: []string{
+				"PIPANEL_HOME=/opt/pipanel",
+				"PIPANEL_SERVER__PROT=80",
+				"PIPANEL_SERVER__PORT__NUMBER=80",
+				"PIPANEL_=x",
+			},
+			want: `{}`,
+		},
+		{
+			name:    "variables without the prefix are ignored",
+			file:    `{}`,
+			environ: []string{"HOME=/root", "SERVER__PORT=80"},
+			want:    `{}`,
+		},
+		{
+			name:    "cannot override inside a non-object",
+			file:    `{"frontend": {"alerter": "x"}}`,
+			environ: []string{"PIPANEL_FRONTEND__ALERTER__FONT_SIZE=32"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := Parse(FormatJSON, []byte(tt.file))
+			if err != nil {
+				t.Fatalf("bad test file: %v", err)
+			}
+
+			err = applyEnvOverrides(tree, tt.environ,
+				reflect.TypeOf(testConfig{}))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got tree %v", tree)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			want, err := Parse(FormatJSON, []byte(tt.want))
+			if err != nil {
+				t.Fatalf("bad test want: %v", err)
+			}
+
+			if !reflect.DeepEqual(tree, want) {
+				got, _ := json.Marshal(tree)
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeEnvOverrides(t *testing.T) {
+	tree, err := Parse(FormatJSON, []byte(`{"frontend": {"name": "console"}}`))
+	if err != nil {
+		t.Fatalf("bad test file: %v", err)
+	}
+
+	err = ApplyEnvOverrides(tree, []string{
+		"PIPANEL_SERVER__PORT=9090",
+		"PIPANEL_FRONTEND__NAME=123456",
+		"PIPANEL_SHUTDOWN__TIMEOUT=10s",
+		"PIPANEL_UNRELATED=1",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg, err := Decode(tree)
+	if err != nil {
+		t.Fatalf("overridden configuration does not decode: %v", err)
+	}
+
+	if cfg.Server.Port != 9090 || cfg.Frontend.Name != "123456" {
+		t.Errorf("got server port %d and frontend %s", cfg.Server.Port,
+			cfg.Frontend.Name)
+	}
+
+	if cfg.Shutdown.Timeout != pipanel.Duration(10*time.Second) {
+		t.Errorf("got shutdown timeout %s", time.Duration(cfg.Shutdown.Timeout))
+	}
+}
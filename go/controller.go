@@ -0,0 +1,208 @@
+package pipanel
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// A Controller is a PiPanel component that drives the Frontend by itself
+// rather than in response to events, such as by adjusting the brightness of
+// the panel to the ambient light. Init starts the controller and Cleanup stops
+// it.
+//
+// Controllers may implement the same optional interfaces as the components of
+// a Frontend, such as Reconfigurer and ConfigDescriber.
+type Controller interface {
+	InitCleaner
+}
+
+// A RouteProvider is a Controller that serves HTTP routes of its own, such as
+// to report its state. Controllers serves these routes below
+// "/controllers/<name>".
+//
+// Implementing this interface is optional.
+type RouteProvider interface {
+	// Routes maps each path, such as "/state", to the function that handles
+	// requests for it.
+	Routes() map[string]http.HandlerFunc
+}
+
+// A ControllerFactory creates a Controller that drives the given Frontend.
+type ControllerFactory func(f *Frontend) Controller
+
+// Controllers manages the Controllers that are running for a Frontend,
+// according to the controllers section of the configuration.
+type Controllers struct {
+	mux      sync.Mutex
+	log      *logrus.Entry
+	frontend *Frontend
+	register map[string]ControllerFactory
+	running  map[string]Controller
+	cfgs     map[string]json.RawMessage
+}
+
+// NewControllers creates a Controllers for the given Frontend, which creates
+// Controllers by name using the given register. No Controller is started
+// until Apply is called.
+func NewControllers(log *logrus.Entry, f *Frontend,
+	register map[string]ControllerFactory) *Controllers {
+
+	return &Controllers{
+		log:      log,
+		frontend: f,
+		register: register,
+		running:  make(map[string]Controller),
+		cfgs:     make(map[string]json.RawMessage),
+	}
+}
+
+// sortedNames returns the keys of the map in order.
+func sortedNames(m map[string]json.RawMessage) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Apply brings the running Controllers in line with the given configuration,
+// which maps the name of each Controller that should run to its raw
+// configuration. Controllers no longer configured are stopped, new ones are
+// started and those whose configuration changed are reconfigured.
+//
+// Every Controller is attempted, even if another fails; the errors of all
+// failing Controllers are returned together as an ErrorList.
+func (cs *Controllers) Apply(cfgs map[string]json.RawMessage) error {
+	cs.mux.Lock()
+	defer cs.mux.Unlock()
+
+	var errs ErrorList
+
+	for _, name := range sortedNames(cs.cfgs) {
+		if _, ok := cfgs[name]; ok {
+			continue
+		}
+
+		cs.log.WithField(componentLogKey, name).Println("Stopping controller.")
+
+		if err := cs.running[name].Cleanup(); err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to stop controller %s", name))
+		}
+
+		delete(cs.running, name)
+		delete(cs.cfgs, name)
+	}
+
+	for _, name := range sortedNames(cfgs) {
+		log := cs.log.WithField(componentLogKey, name)
+		newCfg := cfgs[name]
+
+		if c, ok := cs.running[name]; ok {
+			if sameRawConfig(cs.cfgs[name], newCfg) {
+				continue
+			}
+
+			log.Println("Configuration changed; reconfiguring.")
+
+			err := reconfigureComponent(log, c, cs.cfgs[name], newCfg)
+			if err != nil {
+				errs = append(errs, errors.Wrapf(err,
+					"failed to reconfigure controller %s", name))
+				continue
+			}
+
+			cs.cfgs[name] = newCfg
+			continue
+		}
+
+		create, ok := cs.register[name]
+		if !ok {
+			errs = append(errs, errors.Errorf("no such controller '%s' registered", name))
+			continue
+		}
+
+		log.Println("Starting controller.")
+
+		c := create(cs.frontend)
+		if err := c.Init(log, newCfg); err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to start controller %s", name))
+			continue
+		}
+
+		cs.running[name] = c
+		cs.cfgs[name] = newCfg
+	}
+
+	return errs.Err()
+}
+
+// Cleanup stops all running Controllers. Every Controller is stopped, even if
+// another fails; the errors of all failing Controllers are returned together
+// as an ErrorList.
+func (cs *Controllers) Cleanup() error {
+	return cs.Apply(nil)
+}
+
+// ControllersRoute is the pattern below which Controllers serves the routes of
+// each RouteProvider.
+const ControllersRoute = "/controllers/"
+
+// ServeHTTP routes requests for "/controllers/<name>/<path>" to the route for
+// the path of the running Controller with that name, if it is a RouteProvider.
+func (cs *Controllers) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(path.Clean(r.URL.Path), ControllersRoute)
+
+	name, route := rest, "/"
+	if i := strings.IndexByte(rest, '/'); i > -1 {
+		name, route = rest[:i], rest[i:]
+	}
+
+	cs.mux.Lock()
+	c, ok := cs.running[name]
+	cs.mux.Unlock()
+
+	var h http.HandlerFunc
+	if rp, isProvider := c.(RouteProvider); ok && isProvider {
+		h = rp.Routes()[route]
+	}
+
+	if h == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	h(w, r)
+}
+
+// HealthCheck runs the health check of every running Controller that is a
+// HealthChecker. The errors of all failing Controllers are returned together
+// as an ErrorList.
+func (cs *Controllers) HealthCheck(ctx context.Context) error {
+	cs.mux.Lock()
+	defer cs.mux.Unlock()
+
+	var errs ErrorList
+
+	for _, name := range sortedNames(cs.cfgs) {
+		hc, ok := cs.running[name].(HealthChecker)
+		if !ok {
+			continue
+		}
+
+		if err := hc.HealthCheck(ctx); err != nil {
+			errs = append(errs, errors.Wrapf(err, "controller %s is unhealthy", name))
+		}
+	}
+
+	return errs.Err()
+}
@@ -0,0 +1,397 @@
+// Package ambient provides a controller that adjusts the brightness of the
+// panel to the ambient light, as measured by a Linux IIO light sensor.
+package ambient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+	"github.com/BenJetson/pipanel/go/logfmt"
+)
+
+var _ pipanel.Controller = (*Controller)(nil)
+var _ pipanel.ConfigDescriber = (*Controller)(nil)
+
+// sensorGlob matches the illuminance files of IIO light sensors.
+const sensorGlob = "/sys/bus/iio/devices/iio:device*/in_illuminance_*"
+
+const (
+	pollIntervalDefault = pipanel.Duration(time.Second)
+	smoothingDefault    = 0.2
+	hysteresisDefault   = 8
+	holdDefault         = pipanel.Duration(30 * time.Minute)
+	transitionDefault   = pipanel.Duration(time.Second)
+)
+
+// curveDefault is the curve used if none is configured.
+var curveDefault = []CurvePoint{
+	{Lux: 0, Level: 20},
+	{Lux: 10, Level: 60},
+	{Lux: 100, Level: 150},
+	{Lux: 1000, Level: 255},
+}
+
+// A CurvePoint maps an illuminance to a brightness level.
+type CurvePoint struct {
+	// Lux is the illuminance, in lux.
+	Lux float64 `json:"lux"`
+	// Level is the brightness level on the range [0,255].
+	Level uint8 `json:"level"`
+}
+
+// Config specifies the options that modify the behavior of Controller.
+type Config struct {
+	// SensorPath is the file from which raw illuminance readings are taken.
+	//
+	// If not set, the in_illuminance_input or in_illuminance_raw file of the
+	// first IIO device that has one is used.
+	SensorPath string `json:"sensor_path"`
+	// Scale is the number of lux per unit of the raw readings.
+	//
+	// If not set, it is read from the in_illuminance_scale file next to the
+	// sensor if there is one, or is otherwise one.
+	Scale float64 `json:"scale"`
+	// Offset is added to raw readings before scaling.
+	//
+	// If not set, it is read from the in_illuminance_offset file next to the
+	// sensor if there is one, or is otherwise zero.
+	Offset float64 `json:"offset"`
+	// Curve maps illuminance to brightness. Levels between points are
+	// interpolated linearly; beyond the ends, the level of the nearest point is
+	// used.
+	//
+	// Defaults to a curve from level 20 in darkness to 255 at 1000 lux.
+	Curve []CurvePoint `json:"curve"`
+	// PollInterval is how often the sensor is read.
+	//
+	// Defaults to one second if not set.
+	PollInterval pipanel.Duration `json:"poll_interval"`
+	// Smoothing is the weight, on the range (0,1], given to each new reading
+	// in an exponential moving average. Lower values react more slowly.
+	//
+	// Defaults to 0.2 if not set.
+	Smoothing float64 `json:"smoothing"`
+	// Hysteresis is how far the brightness level for the ambient light must
+	// move away from the current level before the brightness is adjusted.
+	//
+	// Defaults to 8 if not set.
+	Hysteresis uint8 `json:"hysteresis"`
+	// Hold is how long automatic adjustment pauses after the brightness is set
+	// manually.
+	//
+	// Defaults to 30 minutes if not set.
+	Hold pipanel.Duration `json:"hold"`
+	// Transition is the time over which each adjustment fades.
+	//
+	// Defaults to one second if not set.
+	Transition pipanel.Duration `json:"transition"`
+}
+
+// fillDefaults will overwrite zero values with the default configuration.
+func (cfg *Config) fillDefaults() {
+	if len(cfg.Curve) < 1 {
+		cfg.Curve = curveDefault
+	}
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = pollIntervalDefault
+	}
+	if cfg.Smoothing == 0 {
+		cfg.Smoothing = smoothingDefault
+	}
+	if cfg.Hysteresis == 0 {
+		cfg.Hysteresis = hysteresisDefault
+	}
+	if cfg.Hold == 0 {
+		cfg.Hold = holdDefault
+	}
+	if cfg.Transition == 0 {
+		cfg.Transition = transitionDefault
+	}
+}
+
+// validate checks that the configuration values are sensible.
+func (cfg *Config) validate() error {
+	if cfg.PollInterval < 0 {
+		return errors.New("poll_interval cannot be negative")
+	} else if cfg.Smoothing < 0 || cfg.Smoothing > 1 {
+		return errors.New("smoothing must be on the range (0,1]")
+	} else if cfg.Hold < 0 {
+		return errors.New("hold cannot be negative")
+	} else if cfg.Transition < 0 {
+		return errors.New("transition cannot be negative")
+	}
+
+	for i := 1; i < len(cfg.Curve); i++ {
+		if cfg.Curve[i].Lux <= cfg.Curve[i-1].Lux {
+			return errors.New("curve points must be in increasing order of lux")
+		}
+	}
+
+	return nil
+}
+
+// levelFor maps an illuminance to a brightness level using the curve.
+func (cfg *Config) levelFor(lux float64) uint8 {
+	c := cfg.Curve
+
+	i := sort.Search(len(c), func(i int) bool { return c[i].Lux >= lux })
+
+	if i == 0 {
+		return c[0].Level
+	} else if i == len(c) {
+		return c[len(c)-1].Level
+	}
+
+	lo, hi := c[i-1], c[i]
+	t := (lux - lo.Lux) / (hi.Lux - lo.Lux)
+
+	return uint8(math.Round(float64(lo.Level) + t*(float64(hi.Level)-float64(lo.Level))))
+}
+
+// Controller implements pipanel.Controller, adjusting the brightness of the
+// panel to the ambient light.
+type Controller struct {
+	frontend *pipanel.Frontend
+	log      *logrus.Entry
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// New creates a Controller instance for the given frontend.
+func New(f *pipanel.Frontend) *Controller { return &Controller{frontend: f} }
+
+// detectSensor finds the illuminance file of the first IIO light sensor,
+// preferring processed readings over raw ones.
+func detectSensor() (string, error) {
+	matches, err := filepath.Glob(sensorGlob)
+	if err != nil {
+		return "", errors.Wrap(err, "could not search for light sensors")
+	}
+
+	sort.Strings(matches)
+
+	for _, suffix := range []string{"_input", "_raw"} {
+		for _, m := range matches {
+			if strings.HasSuffix(m, suffix) {
+				return m, nil
+			}
+		}
+	}
+
+	return "", errors.New("no IIO light sensor found")
+}
+
+// readFloat reads a single number from a file.
+func readFloat(path string) (float64, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := strconv.ParseFloat(strings.TrimSpace(string(b)), 64)
+	return v, errors.Wrapf(err, "malformed value in %s", path)
+}
+
+// readSibling reads a number from a file next to the sensor file, returning
+// def if there is no such file.
+func readSibling(sensorPath, name string, def float64) (float64, error) {
+	v, err := readFloat(filepath.Join(filepath.Dir(sensorPath), name))
+	if os.IsNotExist(errors.Cause(err)) {
+		return def, nil
+	}
+
+	return v, err
+}
+
+// sensor reads illuminance in lux.
+type sensor struct {
+	path          string
+	scale, offset float64
+}
+
+// openSensor locates the sensor and its calibration for the configuration.
+func openSensor(cfg Config) (sensor, error) {
+	s := sensor{path: cfg.SensorPath, scale: cfg.Scale, offset: cfg.Offset}
+
+	var err error
+
+	if len(s.path) < 1 {
+		if s.path, err = detectSensor(); err != nil {
+			return s, err
+		}
+	}
+
+	if s.scale == 0 {
+		if s.scale, err = readSibling(s.path, "in_illuminance_scale", 1); err != nil {
+			return s, errors.Wrap(err, "could not read sensor scale")
+		}
+	}
+
+	if s.offset == 0 {
+		if s.offset, err = readSibling(s.path, "in_illuminance_offset", 0); err != nil {
+			return s, errors.Wrap(err, "could not read sensor offset")
+		}
+	}
+
+	return s, nil
+}
+
+// lux reads the illuminance from the sensor.
+func (s sensor) lux() (float64, error) {
+	raw, err := readFloat(s.path)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not read light sensor")
+	}
+
+	return (raw + s.offset) * s.scale, nil
+}
+
+// decodeConfig decodes and validates the raw JSON configuration, filling in
+// defaults.
+func decodeConfig(rawCfg json.RawMessage) (Config, error) {
+	var cfg Config
+
+	if len(rawCfg) > 0 {
+		d := json.NewDecoder(bytes.NewReader(rawCfg))
+		d.DisallowUnknownFields()
+
+		if err := d.Decode(&cfg); err != nil {
+			return cfg, errors.Wrap(err, "malformed JSON for ambient configuration")
+		}
+	}
+
+	cfg.fillDefaults()
+
+	return cfg, cfg.validate()
+}
+
+// ConfigTemplate returns a pointer to a zero Config.
+func (c *Controller) ConfigTemplate() interface{} { return &Config{} }
+
+// ValidateConfig decodes and validates the raw JSON configuration without
+// applying it.
+func (c *Controller) ValidateConfig(rawCfg json.RawMessage) error {
+	_, err := decodeConfig(rawCfg)
+	return err
+}
+
+// run polls the sensor and adjusts the brightness until ctx is done.
+func (c *Controller) run(ctx context.Context, cfg Config, s sensor) {
+	ticker := time.NewTicker(time.Duration(cfg.PollInterval))
+	defer ticker.Stop()
+
+	var smoothed float64
+	var seeded, failing bool
+
+	// last is the level most recently set, or -1 if the next level should be
+	// set regardless of hysteresis.
+	last := -1
+
+	for {
+		lux, err := s.lux()
+
+		switch {
+		case err != nil:
+			// Only log the first of a series of failures.
+			if !failing {
+				logfmt.WithError(c.log, err).
+					Errorln("Problem when reading light sensor.")
+			}
+			failing = true
+		case time.Since(c.frontend.ManualBrightnessAt()) < time.Duration(cfg.Hold):
+			// The user chose a level; adjust straight away once the hold ends.
+			failing, last = false, -1
+		default:
+			failing = false
+
+			if seeded {
+				smoothed += cfg.Smoothing * (lux - smoothed)
+			} else {
+				smoothed, seeded = lux, true
+			}
+
+			level := int(cfg.levelFor(smoothed))
+			if last < 0 || math.Abs(float64(level-last)) >= float64(cfg.Hysteresis) {
+				c.adjust(ctx, cfg, smoothed, uint8(level))
+				last = level
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// adjust sets the brightness to the level for the ambient light.
+func (c *Controller) adjust(ctx context.Context, cfg Config, lux float64,
+	level uint8) {
+
+	c.log.WithFields(logrus.Fields{
+		"lux":        math.Round(lux*10) / 10,
+		"brightness": level,
+	}).Println("Adjusting brightness to ambient light.")
+
+	err := c.frontend.AdjustBrightness(ctx, pipanel.BrightnessEvent{
+		Level:      level,
+		Transition: time.Duration(cfg.Transition),
+	})
+
+	if err != nil {
+		logfmt.WithError(c.log, err).Errorln("Problem when adjusting brightness.")
+	}
+}
+
+// Init starts this Controller.
+func (c *Controller) Init(log *logrus.Entry, rawCfg json.RawMessage) error {
+	c.log = log
+
+	cfg, err := decodeConfig(rawCfg)
+	if err != nil {
+		return err
+	}
+
+	s, err := openSensor(cfg)
+	if err != nil {
+		return err
+	}
+
+	c.log.WithField("sensor", s.path).Println("Using light sensor.")
+
+	ctx, stop := context.WithCancel(context.Background())
+	c.stop, c.done = stop, make(chan struct{})
+
+	go func(done chan struct{}) {
+		defer close(done)
+		c.run(ctx, cfg, s)
+	}(c.done)
+
+	return nil
+}
+
+// Cleanup stops this Controller.
+func (c *Controller) Cleanup() error {
+	if c.stop != nil {
+		c.stop()
+		<-c.done
+		c.stop, c.done = nil, nil
+	}
+
+	return nil
+}
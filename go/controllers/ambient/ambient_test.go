@@ -0,0 +1,173 @@
+package ambient
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLevelFor(t *testing.T) {
+	cfg := Config{Curve: []CurvePoint{
+		{Lux: 0, Level: 20},
+		{Lux: 10, Level: 60},
+		{Lux: 100, Level: 150},
+		{Lux: 1000, Level: 255},
+	}}
+
+	tests := []struct {
+		lux  float64
+		want uint8
+	}{
+		{lux: -5, want: 20},
+		{lux: 0, want: 20},
+		{lux: 5, want: 40},
+		{lux: 10, want: 60},
+		{lux: 55, want: 105},
+		{lux: 100, want: 150},
+		{lux: 550, want: 203},
+		{lux: 1000, want: 255},
+		{lux: 50000, want: 255},
+	}
+
+	for _, tt := range tests {
+		if got := cfg.levelFor(tt.lux); got != tt.want {
+			t.Errorf("levelFor(%v) = %d, want %d", tt.lux, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr bool
+	}{
+		{name: "empty", raw: ``},
+		{name: "defaults", raw: `{}`},
+		{name: "durations", raw: `{"poll_interval": "500ms", "hold": "1h"}`},
+		{name: "unknown field", raw: `{"sensitivity": 3}`, wantErr: true},
+		{name: "negative poll interval", raw: `{"poll_interval": "-1s"}`,
+			wantErr: true},
+		{name: "smoothing too high", raw: `{"smoothing": 1.5}`, wantErr: true},
+		{name: "negative hold", raw: `{"hold": "-1m"}`, wantErr: true},
+		{name: "negative transition", raw: `{"transition": "-1s"}`, wantErr: true},
+		{name: "curve out of order",
+			raw:     `{"curve": [{"lux": 10, "level": 50}, {"lux": 5, "level": 60}]}`,
+			wantErr: true},
+		{name: "curve with repeated lux",
+			raw:     `{"curve": [{"lux": 10, "level": 50}, {"lux": 10, "level": 60}]}`,
+			wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := decodeConfig([]byte(tt.raw))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", cfg)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(cfg.Curve) < 1 || cfg.PollInterval <= 0 || cfg.Smoothing <= 0 {
+				t.Errorf("defaults not filled in: %+v", cfg)
+			}
+		})
+	}
+}
+
+func TestOpenSensor(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		cfg      Config
+		wantLux  float64
+		wantErr  bool
+		readFail bool
+	}{
+		{
+			name:    "processed reading",
+			files:   map[string]string{"in_illuminance_input": "123.5\n"},
+			wantLux: 123.5,
+		},
+		{
+			name: "calibration read from sysfs",
+			files: map[string]string{
+				"in_illuminance_input":  "100\n",
+				"in_illuminance_scale":  "0.5\n",
+				"in_illuminance_offset": "10\n",
+			},
+			wantLux: 55,
+		},
+		{
+			name: "configured calibration wins",
+			files: map[string]string{
+				"in_illuminance_input": "100\n",
+				"in_illuminance_scale": "0.5\n",
+			},
+			cfg:     Config{Scale: 2, Offset: -50},
+			wantLux: 100,
+		},
+		{
+			name: "malformed scale",
+			files: map[string]string{
+				"in_illuminance_input": "100\n",
+				"in_illuminance_scale": "half\n",
+			},
+			wantErr: true,
+		},
+		{
+			name:     "malformed reading",
+			files:    map[string]string{"in_illuminance_input": "dark\n"},
+			readFail: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "ambient")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			for name, value := range tt.files {
+				err := ioutil.WriteFile(filepath.Join(dir, name), []byte(value), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cfg := tt.cfg
+			cfg.SensorPath = filepath.Join(dir, "in_illuminance_input")
+
+			s, err := openSensor(cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", s)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			lux, err := s.lux()
+			if tt.readFail {
+				if err == nil {
+					t.Fatalf("expected a read error, got %v lux", lux)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected read error: %v", err)
+			}
+
+			if math.Abs(lux-tt.wantLux) > 1e-9 {
+				t.Errorf("got %v lux, want %v", lux, tt.wantLux)
+			}
+		})
+	}
+}
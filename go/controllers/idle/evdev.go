@@ -0,0 +1,107 @@
+package idle
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+// Linux input event types and values, from linux/input-event-codes.h.
+const (
+	evKey        = 0x01
+	keyReleased  = 0
+	evdevGrabReq = 0x40044590 // EVIOCGRAB, _IOW('E', 0x90, int)
+)
+
+// eventSize is the size of struct input_event, which begins with a timeval
+// whose size depends upon the architecture.
+var eventSize = int(unsafe.Sizeof(syscall.Timeval{})) + 8
+
+// inputEvent is the part of struct input_event that matters here.
+type inputEvent struct {
+	typ   uint16
+	code  uint16
+	value int32
+}
+
+// released reports whether the event is the release of a key or button,
+// including the end of a touch.
+func (e inputEvent) released() bool {
+	return e.typ == evKey && e.value == keyReleased
+}
+
+// device is an open evdev input device, or a file or fifo of recorded events.
+type device struct {
+	path string
+	f    *os.File
+}
+
+// openDevice opens the input device at the given path. Devices are opened in
+// non-blocking mode so that reads may be interrupted by closing them. Fifos
+// are opened for writing as well, so that reads do not end when a writer
+// closes them.
+func openDevice(path string) (*device, error) {
+	flag := os.O_RDONLY
+
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeNamedPipe != 0 {
+		flag = os.O_RDWR
+	}
+
+	f, err := os.OpenFile(path, flag|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open input device %s", path)
+	}
+
+	return &device{path: path, f: f}, nil
+}
+
+// read blocks until the next event is read from the device.
+func (d *device) read(buf []byte) (inputEvent, error) {
+	if _, err := io.ReadFull(d.f, buf); err != nil {
+		return inputEvent{}, err
+	}
+
+	// Events are in the native byte order, which is little endian on every
+	// platform the panel runs on.
+	tail := buf[len(buf)-8:]
+
+	return inputEvent{
+		typ:   binary.LittleEndian.Uint16(tail[0:2]),
+		code:  binary.LittleEndian.Uint16(tail[2:4]),
+		value: int32(binary.LittleEndian.Uint32(tail[4:8])),
+	}, nil
+}
+
+// grab gives this process exclusive access to the events of the device, so
+// that they do not reach the display server, or releases that access.
+func (d *device) grab(exclusive bool) error {
+	var arg uintptr
+	if exclusive {
+		arg = 1
+	}
+
+	conn, err := d.f.SyscallConn()
+	if err != nil {
+		return errors.Wrap(err, "could not access input device")
+	}
+
+	var errno syscall.Errno
+	err = conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, evdevGrabReq, arg)
+	})
+
+	if err != nil {
+		return errors.Wrap(err, "could not access input device")
+	} else if errno != 0 {
+		return errors.Wrap(errno, "could not grab input device")
+	}
+
+	return nil
+}
+
+// Close closes the device, interrupting any read in progress.
+func (d *device) Close() error { return d.f.Close() }
@@ -0,0 +1,419 @@
+// Package idle provides a controller that blanks the display after a period
+// without input, and wakes it again upon input such as a touch.
+package idle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+	"github.com/BenJetson/pipanel/go/logfmt"
+)
+
+var _ pipanel.Controller = (*Controller)(nil)
+var _ pipanel.ConfigDescriber = (*Controller)(nil)
+
+const (
+	timeoutDefault        = pipanel.Duration(5 * time.Minute)
+	swallowTimeoutDefault = pipanel.Duration(2 * time.Second)
+)
+
+// powerCheckInterval is how often the display power state is checked for
+// changes made elsewhere, such as through the API or by an alert.
+var powerCheckInterval = time.Second / 2
+
+// devicesDefault are the input devices watched if none are configured.
+var devicesDefault = []string{"/dev/input/event*"}
+
+// Config specifies the options that modify the behavior of Controller.
+type Config struct {
+	// Devices are the paths of the evdev input devices to watch, which may
+	// contain glob patterns. Files and fifos of recorded events also work.
+	//
+	// Defaults to every device matching "/dev/input/event*" if not set.
+	Devices []string `json:"devices"`
+	// Timeout is how long the panel may go without input before the display
+	// is blanked.
+	//
+	// Defaults to five minutes if not set.
+	Timeout pipanel.Duration `json:"timeout"`
+	// Blank is the display power state used to blank the display, either
+	// "off" or "standby".
+	//
+	// Defaults to "off" if not set.
+	Blank pipanel.DisplayPower `json:"blank"`
+	// SwallowTimeout is the longest that input is kept from the display
+	// server after waking the display, should the touch that woke it not be
+	// seen to end.
+	//
+	// Defaults to two seconds if not set.
+	SwallowTimeout pipanel.Duration `json:"swallow_timeout"`
+}
+
+// fillDefaults will overwrite zero values with the default configuration.
+func (cfg *Config) fillDefaults() {
+	if len(cfg.Devices) < 1 {
+		cfg.Devices = devicesDefault
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = timeoutDefault
+	}
+	if len(cfg.Blank) < 1 {
+		cfg.Blank = pipanel.DisplayPowerOff
+	}
+	if cfg.SwallowTimeout == 0 {
+		cfg.SwallowTimeout = swallowTimeoutDefault
+	}
+}
+
+// validate checks that the configuration values are sensible.
+func (cfg *Config) validate() error {
+	if cfg.Timeout < 0 {
+		return errors.New("timeout cannot be negative")
+	} else if cfg.SwallowTimeout < 0 {
+		return errors.New("swallow_timeout cannot be negative")
+	} else if cfg.Blank == pipanel.DisplayPowerOn {
+		return errors.New("blank must be off or standby")
+	}
+
+	for _, pattern := range cfg.Devices {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return errors.Wrapf(err, "malformed device pattern '%s'", pattern)
+		}
+	}
+
+	return nil
+}
+
+// Controller implements pipanel.Controller, blanking the display after a
+// period without input and waking it upon input.
+//
+// While the display is blanked, the input devices are grabbed so that the
+// input which wakes the display does not also reach the display server, where
+// it might press a button nobody could see. They are released once that input
+// ends.
+//
+// The display may also be blanked or woken elsewhere, such as through the API
+// or for an alert. The Controller follows such changes, so that input is only
+// swallowed while the display is actually blanked.
+type Controller struct {
+	frontend *pipanel.Frontend
+	log      *logrus.Entry
+	cfg      Config
+
+	devices []*device
+	input   chan inputEvent
+	readers sync.WaitGroup
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// New creates a Controller instance for the given frontend.
+func New(f *pipanel.Frontend) *Controller { return &Controller{frontend: f} }
+
+// openDevices opens every configured input device.
+func (c *Controller) openDevices() error {
+	var paths []string
+	for _, pattern := range c.cfg.Devices {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return errors.Wrapf(err, "malformed device pattern '%s'", pattern)
+		}
+		paths = append(paths, matches...)
+	}
+
+	if len(paths) < 1 {
+		return errors.New("no input devices found")
+	}
+
+	for _, path := range paths {
+		d, err := openDevice(path)
+		if err != nil {
+			// Some devices, such as those of other users, may be unreadable.
+			logfmt.WithError(c.log, err).Warnln("Skipping input device.")
+			continue
+		}
+		c.devices = append(c.devices, d)
+	}
+
+	if len(c.devices) < 1 {
+		return errors.New("none of the input devices could be opened")
+	}
+
+	return nil
+}
+
+// readDevice sends the events read from the device to the input channel until
+// the device is closed or runs out of events.
+func (c *Controller) readDevice(d *device) {
+	defer c.readers.Done()
+
+	buf := make([]byte, eventSize)
+
+	for {
+		e, err := d.read(buf)
+		if err != nil {
+			c.log.WithField("device", d.path).WithError(err).
+				Debugln("Stopped reading input device.")
+			return
+		}
+
+		c.input <- e
+	}
+}
+
+// grabAll grabs or releases every input device. Devices that cannot be
+// grabbed, such as fifos, are left as they are.
+func (c *Controller) grabAll(exclusive bool) {
+	for _, d := range c.devices {
+		if err := d.grab(exclusive); err != nil {
+			c.log.WithField("device", d.path).WithError(err).
+				Debugln("Could not change input device grab.")
+		}
+	}
+}
+
+// blank blanks the display, returning the brightness level to restore upon
+// waking and whether there is one.
+func (c *Controller) blank(ctx context.Context) (uint8, bool) {
+	c.log.Println("No input for a while; blanking the display.")
+
+	level, err := c.frontend.Brightness(ctx)
+	restore := err == nil
+
+	c.grabAll(true)
+
+	err = c.frontend.SetDisplayPower(ctx, pipanel.DisplayPowerEvent{
+		State: c.cfg.Blank,
+	})
+
+	if err != nil {
+		logfmt.WithError(c.log, err).Errorln("Problem when blanking the display.")
+	}
+
+	return level, restore
+}
+
+// wake lights the display and restores the brightness it had before it was
+// blanked, if known.
+func (c *Controller) wake(ctx context.Context, level uint8, restore bool) {
+	err := c.frontend.SetDisplayPower(ctx, pipanel.DisplayPowerEvent{
+		State: pipanel.DisplayPowerOn,
+	})
+
+	if err != nil {
+		logfmt.WithError(c.log, err).Errorln("Problem when waking the display.")
+	}
+
+	if !restore {
+		return
+	}
+
+	err = c.frontend.AdjustBrightness(ctx, pipanel.BrightnessEvent{Level: level})
+	if err != nil {
+		logfmt.WithError(c.log, err).Errorln("Problem when restoring brightness.")
+	}
+}
+
+// run blanks and wakes the display according to the input until ctx is done.
+// The display is woken before returning, should the Controller have blanked
+// it.
+func (c *Controller) run(ctx context.Context) {
+	idle := time.NewTimer(time.Duration(c.cfg.Timeout))
+	defer idle.Stop()
+
+	// swallow is non-nil while input is kept from the display server after
+	// waking the display.
+	var swallow <-chan time.Time
+
+	// blanked is true while the display is blanked, and blankedHere while
+	// that is because of the Controller.
+	var blanked, blankedHere, restore bool
+	var level uint8
+
+	endSwallow := func() {
+		c.grabAll(false)
+		swallow = nil
+	}
+
+	resetIdle := func() {
+		if !idle.Stop() {
+			select {
+			case <-idle.C:
+			default:
+			}
+		}
+		idle.Reset(time.Duration(c.cfg.Timeout))
+	}
+
+	// followPower catches up with changes to the display power made
+	// elsewhere.
+	followPower := func() {
+		power, _ := c.frontend.DisplayPowerState()
+
+		switch {
+		case blanked && power == pipanel.DisplayPowerOn:
+			c.log.Println("Display woken elsewhere; releasing input.")
+			blanked, blankedHere = false, false
+			c.grabAll(false)
+			resetIdle()
+		case !blanked && power != pipanel.DisplayPowerOn:
+			c.log.Println("Display blanked elsewhere; grabbing input.")
+			blanked, restore = true, false
+			if swallow != nil {
+				// The devices are grabbed already.
+				swallow = nil
+			} else {
+				c.grabAll(true)
+			}
+		}
+	}
+
+	check := time.NewTicker(powerCheckInterval)
+	defer check.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if blankedHere {
+				c.log.Println("Stopping; waking the display.")
+				c.wake(context.Background(), level, restore)
+			}
+			if blanked || swallow != nil {
+				c.grabAll(false)
+			}
+			return
+		case e := <-c.input:
+			followPower()
+
+			if blanked {
+				c.log.Println("Input detected; waking the display.")
+				c.wake(ctx, level, restore)
+				blanked, blankedHere = false, false
+
+				swallow = time.After(time.Duration(c.cfg.SwallowTimeout))
+				if e.released() {
+					endSwallow()
+				}
+			} else if swallow != nil && e.released() {
+				endSwallow()
+			}
+
+			resetIdle()
+		case <-check.C:
+			followPower()
+		case <-swallow:
+			endSwallow()
+		case <-idle.C:
+			followPower()
+
+			if !blanked {
+				level, restore = c.blank(ctx)
+				blanked, blankedHere = true, true
+			}
+		}
+	}
+}
+
+// decodeConfig decodes and validates the raw JSON configuration, filling in
+// defaults.
+func decodeConfig(rawCfg json.RawMessage) (Config, error) {
+	var cfg Config
+
+	if len(rawCfg) > 0 {
+		d := json.NewDecoder(bytes.NewReader(rawCfg))
+		d.DisallowUnknownFields()
+
+		if err := d.Decode(&cfg); err != nil {
+			return cfg, errors.Wrap(err, "malformed JSON for idle configuration")
+		}
+	}
+
+	cfg.fillDefaults()
+
+	return cfg, cfg.validate()
+}
+
+// ConfigTemplate returns a pointer to a zero Config.
+func (c *Controller) ConfigTemplate() interface{} { return &Config{} }
+
+// ValidateConfig decodes and validates the raw JSON configuration without
+// applying it.
+func (c *Controller) ValidateConfig(rawCfg json.RawMessage) error {
+	_, err := decodeConfig(rawCfg)
+	return err
+}
+
+// Init starts this Controller, opening the input devices.
+func (c *Controller) Init(log *logrus.Entry, rawCfg json.RawMessage) error {
+	c.log = log
+
+	cfg, err := decodeConfig(rawCfg)
+	if err != nil {
+		return err
+	}
+
+	c.cfg = cfg
+
+	if err = c.openDevices(); err != nil {
+		return err
+	}
+
+	c.input = make(chan inputEvent)
+
+	for _, d := range c.devices {
+		c.log.WithField("device", d.path).Println("Watching input device.")
+
+		c.readers.Add(1)
+		go c.readDevice(d)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	c.stop, c.done = stop, make(chan struct{})
+
+	go func(done chan struct{}) {
+		defer close(done)
+		c.run(ctx)
+	}(c.done)
+
+	return nil
+}
+
+// Cleanup stops this Controller, waking the display should it be blanked, and
+// closes the input devices.
+func (c *Controller) Cleanup() error {
+	if c.stop == nil {
+		return nil
+	}
+
+	c.stop()
+	<-c.done
+	c.stop, c.done = nil, nil
+
+	var errs pipanel.ErrorList
+	for _, d := range c.devices {
+		if err := d.Close(); err != nil {
+			errs = append(errs, errors.Wrapf(err, "could not close %s", d.path))
+		}
+	}
+
+	// Readers may be blocked sending input that nobody will receive.
+	go func() {
+		for range c.input {
+		}
+	}()
+
+	c.readers.Wait()
+	close(c.input)
+	c.devices = nil
+
+	return errs.Err()
+}
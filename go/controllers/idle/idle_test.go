@@ -0,0 +1,276 @@
+package idle
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+)
+
+// display is a DisplayManager that reports every change made to it.
+type display struct {
+	mux     sync.Mutex
+	level   uint8
+	changes chan string
+}
+
+func (d *display) Init(*logrus.Entry, json.RawMessage) error { return nil }
+func (d *display) Cleanup() error                            { return nil }
+
+func (d *display) SetBrightness(ctx context.Context, e pipanel.BrightnessEvent) error {
+	d.mux.Lock()
+	d.level = e.Level
+	d.mux.Unlock()
+
+	d.changes <- fmt.Sprintf("brightness %d", e.Level)
+	return nil
+}
+
+func (d *display) SetDisplayPower(ctx context.Context, e pipanel.DisplayPowerEvent) error {
+	d.changes <- fmt.Sprintf("power %s", e.State)
+	return nil
+}
+
+func (d *display) Brightness(ctx context.Context) (uint8, error) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	return d.level, nil
+}
+
+// expect waits for the next change to the display.
+func (d *display) expect(t *testing.T, want string) {
+	t.Helper()
+
+	select {
+	case got := <-d.changes:
+		if got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for %s", want)
+	}
+}
+
+// expectNone checks that the display does not change for a while.
+func (d *display) expectNone(t *testing.T, wait time.Duration) {
+	t.Helper()
+
+	select {
+	case got := <-d.changes:
+		t.Fatalf("got unexpected %s", got)
+	case <-time.After(wait):
+	}
+}
+
+// writeEvent writes an input event to the fifo in the evdev format.
+func writeEvent(t *testing.T, w *os.File, typ, code uint16, value int32) {
+	t.Helper()
+
+	buf := make([]byte, eventSize)
+	tail := buf[len(buf)-8:]
+	binary.LittleEndian.PutUint16(tail[0:2], typ)
+	binary.LittleEndian.PutUint16(tail[2:4], code)
+	binary.LittleEndian.PutUint32(tail[4:8], uint32(value))
+
+	if _, err := w.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const btnTouch = 0x14a
+
+// startController starts a Controller that watches a fifo, returning the
+// display it controls and the writing end of the fifo.
+func startController(t *testing.T, dir string, timeout time.Duration) (*Controller,
+	*display, *os.File) {
+
+	t.Helper()
+
+	fifo := filepath.Join(dir, "event0")
+	if err := syscall.Mkfifo(fifo, 0600); err != nil {
+		t.Skip("fifos unsupported:", err)
+	}
+
+	log := logrus.New()
+	log.Out = ioutil.Discard
+
+	d := &display{changes: make(chan string, 16)}
+	f := &pipanel.Frontend{DisplayManager: d}
+	if err := f.Init(logrus.NewEntry(log), &pipanel.FrontendConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if err := f.AdjustBrightness(ctx, pipanel.BrightnessEvent{Level: 200}); err != nil {
+		t.Fatal(err)
+	}
+	d.expect(t, "brightness 200")
+
+	c := New(f)
+	rawCfg := fmt.Sprintf(`{"devices": [%q], "timeout": "%s", "swallow_timeout": "1s"}`,
+		filepath.Join(dir, "event*"), timeout)
+	if err := c.Init(logrus.NewEntry(log), []byte(rawCfg)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The controller holds the fifo open for writing too, so this does not
+	// block.
+	w, err := os.OpenFile(fifo, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c, d, w
+}
+
+func TestBlankAndWake(t *testing.T) {
+	dir, err := ioutil.TempDir("", "idle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, d, w := startController(t, dir, 50*time.Millisecond)
+	defer w.Close()
+
+	d.expect(t, "power off")
+
+	// A touch wakes the display and restores its brightness.
+	writeEvent(t, w, evKey, btnTouch, 1)
+	d.expect(t, "power on")
+	d.expect(t, "brightness 200")
+
+	writeEvent(t, w, evKey, btnTouch, keyReleased)
+
+	// The display is blanked again after another period without input, and
+	// woken when the controller stops.
+	d.expect(t, "power off")
+
+	if err := c.Cleanup(); err != nil {
+		t.Fatalf("failed to clean up: %v", err)
+	}
+
+	d.expect(t, "power on")
+	d.expect(t, "brightness 200")
+}
+
+func TestInputDelaysBlanking(t *testing.T) {
+	dir, err := ioutil.TempDir("", "idle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const timeout = 300 * time.Millisecond
+
+	c, d, w := startController(t, dir, timeout)
+	defer w.Close()
+
+	for i := 0; i < 8; i++ {
+		time.Sleep(timeout / 4)
+		writeEvent(t, w, evKey, btnTouch, int32(i%2))
+	}
+
+	d.expectNone(t, timeout/4)
+	d.expect(t, "power off")
+
+	if err := c.Cleanup(); err != nil {
+		t.Fatalf("failed to clean up: %v", err)
+	}
+}
+
+func TestFollowsPowerChangedElsewhere(t *testing.T) {
+	dir, err := ioutil.TempDir("", "idle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(interval time.Duration) { powerCheckInterval = interval }(powerCheckInterval)
+	powerCheckInterval = 10 * time.Millisecond
+
+	c, d, w := startController(t, dir, time.Hour)
+	defer w.Close()
+
+	ctx := context.Background()
+	setPower := func(p pipanel.DisplayPower) {
+		err := c.frontend.SetDisplayPower(ctx, pipanel.DisplayPowerEvent{State: p})
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.expect(t, "power "+string(p))
+	}
+
+	// A touch wakes a display blanked elsewhere, without knowing what
+	// brightness to restore.
+	setPower(pipanel.DisplayPowerOff)
+	time.Sleep(5 * powerCheckInterval)
+
+	writeEvent(t, w, evKey, btnTouch, 1)
+	d.expect(t, "power on")
+	writeEvent(t, w, evKey, btnTouch, keyReleased)
+	d.expectNone(t, 5*powerCheckInterval)
+
+	// A touch after the display was woken elsewhere is left alone.
+	setPower(pipanel.DisplayPowerOff)
+	time.Sleep(5 * powerCheckInterval)
+	setPower(pipanel.DisplayPowerOn)
+	time.Sleep(5 * powerCheckInterval)
+
+	writeEvent(t, w, evKey, btnTouch, 1)
+	writeEvent(t, w, evKey, btnTouch, keyReleased)
+	d.expectNone(t, 5*powerCheckInterval)
+
+	// A display blanked elsewhere is not woken when the controller stops.
+	setPower(pipanel.DisplayPowerOff)
+	time.Sleep(5 * powerCheckInterval)
+
+	if err := c.Cleanup(); err != nil {
+		t.Fatalf("failed to clean up: %v", err)
+	}
+
+	d.expectNone(t, 5*powerCheckInterval)
+}
+
+func TestDecodeConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr bool
+	}{
+		{name: "empty", raw: ``},
+		{name: "standby", raw: `{"blank": "standby", "timeout": "1m"}`},
+		{name: "blank on", raw: `{"blank": "on"}`, wantErr: true},
+		{name: "negative timeout", raw: `{"timeout": "-1s"}`, wantErr: true},
+		{name: "negative swallow timeout", raw: `{"swallow_timeout": "-1s"}`,
+			wantErr: true},
+		{name: "malformed pattern", raw: `{"devices": ["/dev/input/[event"]}`,
+			wantErr: true},
+		{name: "unknown field", raw: `{"grab": true}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := decodeConfig([]byte(tt.raw))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", cfg)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,384 @@
+// Package solar provides a controller that ramps the brightness of the panel
+// between day and night levels across twilight, using sunrise and sunset
+// times computed locally from the position of the panel.
+package solar
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+	"github.com/BenJetson/pipanel/go/logfmt"
+)
+
+var _ pipanel.Controller = (*Controller)(nil)
+var _ pipanel.ConfigDescriber = (*Controller)(nil)
+var _ pipanel.RouteProvider = (*Controller)(nil)
+
+const (
+	dayLevelDefault       = 255
+	nightLevelDefault     = 30
+	updateIntervalDefault = pipanel.Duration(time.Minute)
+	transitionDefault     = pipanel.Duration(5 * time.Second)
+)
+
+// Config specifies the options that modify the behavior of Controller.
+type Config struct {
+	// Latitude is the latitude of the panel in degrees, positive north of the
+	// equator. Required.
+	Latitude *float64 `json:"latitude"`
+	// Longitude is the longitude of the panel in degrees, positive east of
+	// Greenwich. Required.
+	Longitude *float64 `json:"longitude"`
+	// DayLevel is the brightness level between sunrise and sunset.
+	//
+	// Defaults to 255 if not set.
+	DayLevel uint8 `json:"day_level"`
+	// NightLevel is the brightness level between the end of civil twilight in
+	// the evening and its start in the morning.
+	//
+	// Defaults to 30 if not set.
+	NightLevel *uint8 `json:"night_level"`
+	// UpdateInterval is how often the brightness is updated while ramping.
+	//
+	// Defaults to one minute if not set.
+	UpdateInterval pipanel.Duration `json:"update_interval"`
+	// Transition is the time over which each update fades.
+	//
+	// Defaults to five seconds if not set.
+	Transition pipanel.Duration `json:"transition"`
+}
+
+// fillDefaults will overwrite zero values with the default configuration.
+func (cfg *Config) fillDefaults() {
+	if cfg.DayLevel == 0 {
+		cfg.DayLevel = dayLevelDefault
+	}
+	if cfg.NightLevel == nil {
+		level := uint8(nightLevelDefault)
+		cfg.NightLevel = &level
+	}
+	if cfg.UpdateInterval == 0 {
+		cfg.UpdateInterval = updateIntervalDefault
+	}
+	if cfg.Transition == 0 {
+		cfg.Transition = transitionDefault
+	}
+}
+
+// validate checks that the configuration values are sensible.
+func (cfg *Config) validate() error {
+	if cfg.Latitude == nil || cfg.Longitude == nil {
+		return errors.New("latitude and longitude are required")
+	} else if math.Abs(*cfg.Latitude) > 90 {
+		return errors.New("latitude must be on the range [-90,90]")
+	} else if math.Abs(*cfg.Longitude) > 180 {
+		return errors.New("longitude must be on the range [-180,180]")
+	} else if cfg.UpdateInterval < 0 {
+		return errors.New("update_interval cannot be negative")
+	} else if cfg.Transition < 0 {
+		return errors.New("transition cannot be negative")
+	}
+
+	return nil
+}
+
+// Phase is a part of the solar day.
+type Phase string
+
+const (
+	// PhaseNight lasts from the end of civil twilight in the evening until its
+	// start in the morning.
+	PhaseNight Phase = "night"
+	// PhaseDawn lasts from the start of civil twilight until sunrise.
+	PhaseDawn Phase = "dawn"
+	// PhaseDay lasts from sunrise until sunset.
+	PhaseDay Phase = "day"
+	// PhaseDusk lasts from sunset until the end of civil twilight.
+	PhaseDusk Phase = "dusk"
+)
+
+// transition is a moment at which a new phase begins.
+type transition struct {
+	at    time.Time
+	phase Phase
+}
+
+// timeline lists the transitions from the day before until the day after the
+// given moment, in order.
+func (cfg *Config) timeline(now time.Time) []transition {
+	var ts []transition
+
+	add := func(at time.Time, phase Phase) {
+		if !at.IsZero() {
+			ts = append(ts, transition{at, phase})
+		}
+	}
+
+	for d := -1; d < 2; d++ {
+		t := Compute(now.AddDate(0, 0, d), *cfg.Latitude, *cfg.Longitude)
+
+		dawn, dusk := t.Dawn, t.Dusk
+		if t.Sun == Crosses && t.Twilight == AlwaysAbove {
+			// Civil twilight lasts all night, so ramp until solar midnight.
+			dawn, dusk = t.Noon.Add(-12*time.Hour), t.Noon.Add(12*time.Hour)
+		}
+
+		add(dawn, PhaseDawn)
+		add(t.Sunrise, PhaseDay)
+		add(t.Sunset, PhaseDusk)
+		add(dusk, PhaseNight)
+	}
+
+	sort.SliceStable(ts, func(i, j int) bool { return ts[i].at.Before(ts[j].at) })
+
+	return ts
+}
+
+// State describes the position in the solar day.
+type State struct {
+	// Phase is the current phase.
+	Phase Phase `json:"phase"`
+	// Level is the brightness level for the current moment.
+	Level uint8 `json:"level"`
+	// Held is true while a manual brightness change is respected.
+	Held bool `json:"held"`
+	// PhaseStart is when the current phase began, or nil if it did not begin
+	// recently, as during polar day or night.
+	PhaseStart *time.Time `json:"phase_start,omitempty"`
+	// NextTransition is when the next phase begins, or nil if it does not
+	// begin soon, as during polar day or night.
+	NextTransition *time.Time `json:"next_transition,omitempty"`
+}
+
+// stateAt computes the State at the given moment, without regard to manual
+// changes.
+func (cfg *Config) stateAt(now time.Time) State {
+	ts := cfg.timeline(now)
+
+	i := sort.Search(len(ts), func(i int) bool { return ts[i].at.After(now) }) - 1
+
+	var s State
+	if i+1 < len(ts) {
+		next := ts[i+1].at
+		s.NextTransition = &next
+	}
+
+	if i < 0 {
+		// No transition happened recently; the sun stays up or down all day.
+		s.Phase = PhaseNight
+		if Compute(now, *cfg.Latitude, *cfg.Longitude).Sun == AlwaysAbove {
+			s.Phase = PhaseDay
+		}
+	} else {
+		start := ts[i].at
+		s.Phase, s.PhaseStart = ts[i].phase, &start
+	}
+
+	day, night := float64(cfg.DayLevel), float64(*cfg.NightLevel)
+
+	// progress is how far through the current phase the moment is.
+	progress := func() float64 {
+		if s.PhaseStart == nil || s.NextTransition == nil {
+			return 0
+		}
+		return float64(now.Sub(*s.PhaseStart)) /
+			float64(s.NextTransition.Sub(*s.PhaseStart))
+	}
+
+	level := night
+	switch s.Phase {
+	case PhaseDay:
+		level = day
+	case PhaseDawn:
+		level = night + (day-night)*progress()
+	case PhaseDusk:
+		level = day + (night-day)*progress()
+	}
+
+	s.Level = uint8(math.Round(level))
+
+	return s
+}
+
+// Controller implements pipanel.Controller, ramping the brightness of the
+// panel between the day and night levels across twilight.
+type Controller struct {
+	frontend *pipanel.Frontend
+	log      *logrus.Entry
+
+	cfgMux sync.RWMutex
+	cfg    Config
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// New creates a Controller instance for the given frontend.
+func New(f *pipanel.Frontend) *Controller { return &Controller{frontend: f} }
+
+// state computes the current State, including whether a manual brightness
+// change is being respected. A manual change is respected until the next
+// transition.
+func (c *Controller) state(now time.Time) State {
+	c.cfgMux.RLock()
+	s := c.cfg.stateAt(now)
+	c.cfgMux.RUnlock()
+
+	manualAt := c.frontend.ManualBrightnessAt()
+	s.Held = !manualAt.IsZero() &&
+		(s.PhaseStart == nil || manualAt.After(*s.PhaseStart))
+
+	return s
+}
+
+// run updates the brightness until ctx is done.
+func (c *Controller) run(ctx context.Context) {
+	c.cfgMux.RLock()
+	interval := time.Duration(c.cfg.UpdateInterval)
+	transition := time.Duration(c.cfg.Transition)
+	c.cfgMux.RUnlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastPhase Phase
+	last := -1
+
+	for {
+		s := c.state(time.Now())
+
+		if s.Phase != lastPhase {
+			c.log.WithField("phase", s.Phase).Println("Solar phase began.")
+			lastPhase = s.Phase
+		}
+
+		if s.Held {
+			// Set the level for the phase straight away once the hold ends.
+			last = -1
+		} else if int(s.Level) != last {
+			err := c.frontend.AdjustBrightness(ctx, pipanel.BrightnessEvent{
+				Level:      s.Level,
+				Transition: transition,
+			})
+
+			if err != nil {
+				logfmt.WithError(c.log, err).
+					Errorln("Problem when adjusting brightness.")
+			}
+
+			last = int(s.Level)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Routes serves the computed solar times and the current state at "/times".
+func (c *Controller) Routes() map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{"/times": c.handleTimes}
+}
+
+// timesResponse is the response body for requests to "/times".
+type timesResponse struct {
+	State
+	Today Times `json:"today"`
+}
+
+func (c *Controller) handleTimes(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+
+	c.cfgMux.RLock()
+	today := Compute(now, *c.cfg.Latitude, *c.cfg.Longitude)
+	c.cfgMux.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(timesResponse{State: c.state(now), Today: today})
+	if err != nil {
+		logfmt.WithError(c.log, err).WithContext(r.Context()).
+			Errorln("Problem when writing solar times.")
+	}
+}
+
+// decodeConfig decodes and validates the raw JSON configuration, filling in
+// defaults.
+func decodeConfig(rawCfg json.RawMessage) (Config, error) {
+	var cfg Config
+
+	if len(rawCfg) > 0 {
+		d := json.NewDecoder(bytes.NewReader(rawCfg))
+		d.DisallowUnknownFields()
+
+		if err := d.Decode(&cfg); err != nil {
+			return cfg, errors.Wrap(err, "malformed JSON for solar configuration")
+		}
+	}
+
+	cfg.fillDefaults()
+
+	return cfg, cfg.validate()
+}
+
+// ConfigTemplate returns a pointer to a zero Config.
+func (c *Controller) ConfigTemplate() interface{} { return &Config{} }
+
+// ValidateConfig decodes and validates the raw JSON configuration without
+// applying it.
+func (c *Controller) ValidateConfig(rawCfg json.RawMessage) error {
+	_, err := decodeConfig(rawCfg)
+	return err
+}
+
+// Init starts this Controller.
+func (c *Controller) Init(log *logrus.Entry, rawCfg json.RawMessage) error {
+	c.log = log
+
+	cfg, err := decodeConfig(rawCfg)
+	if err != nil {
+		return err
+	}
+
+	c.cfgMux.Lock()
+	c.cfg = cfg
+	c.cfgMux.Unlock()
+
+	today := Compute(time.Now(), *cfg.Latitude, *cfg.Longitude)
+	c.log.WithFields(logrus.Fields{
+		"sunrise": today.Sunrise.Format(time.Kitchen),
+		"sunset":  today.Sunset.Format(time.Kitchen),
+	}).Println("Computed solar times for today.")
+
+	ctx, stop := context.WithCancel(context.Background())
+	c.stop, c.done = stop, make(chan struct{})
+
+	go func(done chan struct{}) {
+		defer close(done)
+		c.run(ctx)
+	}(c.done)
+
+	return nil
+}
+
+// Cleanup stops this Controller.
+func (c *Controller) Cleanup() error {
+	if c.stop != nil {
+		c.stop()
+		<-c.done
+		c.stop, c.done = nil, nil
+	}
+
+	return nil
+}
@@ -0,0 +1,225 @@
+package solar
+
+import (
+	"testing"
+	"time"
+)
+
+// clock parses a time of day on the given date in the location of the date.
+func clock(t *testing.T, date time.Time, hhmm string) time.Time {
+	t.Helper()
+
+	c, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	y, m, d := date.Date()
+	return time.Date(y, m, d, c.Hour(), c.Minute(), 0, 0, date.Location())
+}
+
+func TestCompute(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skip("time zone data unavailable:", err)
+	}
+
+	// Published times, to the minute. The sunrise equation is accurate to
+	// within a couple of minutes at these latitudes.
+	const tolerance = 3 * time.Minute
+
+	tests := []struct {
+		name          string
+		date          time.Time
+		lat, lon      float64
+		dawn, sunrise string
+		noon          string
+		sunset, dusk  string
+		sun, twilight Polar
+	}{
+		{
+			name: "London midsummer",
+			date: time.Date(2020, 6, 21, 0, 0, 0, 0, london),
+			lat:  51.5074, lon: -0.1278,
+			dawn: "03:56", sunrise: "04:43", noon: "13:02",
+			sunset: "21:21", dusk: "22:08",
+		},
+		{
+			name: "London midwinter",
+			date: time.Date(2020, 12, 21, 0, 0, 0, 0, london),
+			lat:  51.5074, lon: -0.1278,
+			dawn: "07:24", sunrise: "08:04", noon: "11:59",
+			sunset: "15:54", dusk: "16:34",
+		},
+		{
+			name: "equator at the equinox",
+			date: time.Date(2020, 3, 20, 0, 0, 0, 0, time.UTC),
+			lat:  0, lon: 0,
+			dawn: "05:43", sunrise: "06:04", noon: "12:07",
+			sunset: "18:11", dusk: "18:31",
+		},
+		{
+			name: "polar day",
+			date: time.Date(2020, 6, 21, 0, 0, 0, 0, time.UTC),
+			lat:  69.65, lon: 18.96,
+			noon: "10:46",
+			sun:  AlwaysAbove, twilight: AlwaysAbove,
+		},
+		{
+			name: "polar night with civil twilight",
+			date: time.Date(2020, 12, 21, 0, 0, 0, 0, time.UTC),
+			lat:  69.65, lon: 18.96,
+			dawn: "08:31", noon: "10:42", dusk: "12:53",
+			sun: AlwaysBelow,
+		},
+		{
+			name: "polar night without twilight",
+			date: time.Date(2020, 12, 21, 0, 0, 0, 0, time.UTC),
+			lat:  85, lon: 0,
+			noon: "11:58",
+			sun:  AlwaysBelow, twilight: AlwaysBelow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Compute(tt.date, tt.lat, tt.lon)
+
+			if got.Sun != tt.sun || got.Twilight != tt.twilight {
+				t.Errorf("got sun %s and twilight %s, want %s and %s",
+					polarNames[got.Sun], polarNames[got.Twilight],
+					polarNames[tt.sun], polarNames[tt.twilight])
+			}
+
+			events := []struct {
+				name string
+				got  time.Time
+				want string
+			}{
+				{"dawn", got.Dawn, tt.dawn},
+				{"sunrise", got.Sunrise, tt.sunrise},
+				{"noon", got.Noon, tt.noon},
+				{"sunset", got.Sunset, tt.sunset},
+				{"dusk", got.Dusk, tt.dusk},
+			}
+
+			for _, e := range events {
+				if len(e.want) < 1 {
+					if !e.got.IsZero() {
+						t.Errorf("got %s at %s, want none", e.name, e.got)
+					}
+					continue
+				}
+
+				want := clock(t, tt.date, e.want)
+				if diff := e.got.Sub(want); diff < -tolerance || diff > tolerance {
+					t.Errorf("got %s at %s, want %s", e.name, e.got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStateAt(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skip("time zone data unavailable:", err)
+	}
+
+	midsummer := time.Date(2020, 6, 21, 0, 0, 0, 0, london)
+	midwinter := time.Date(2020, 12, 21, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		lat, lon       float64
+		at             time.Time
+		phase          Phase
+		minLevel       uint8
+		maxLevel       uint8
+		wantTransition bool
+	}{
+		{name: "before dawn", lat: 51.5074, lon: -0.1278,
+			at: clock(t, midsummer, "02:00"), phase: PhaseNight,
+			minLevel: 30, maxLevel: 30, wantTransition: true},
+		{name: "dawn ramps up", lat: 51.5074, lon: -0.1278,
+			at: clock(t, midsummer, "04:20"), phase: PhaseDawn,
+			minLevel: 120, maxLevel: 170, wantTransition: true},
+		{name: "day", lat: 51.5074, lon: -0.1278,
+			at: clock(t, midsummer, "12:00"), phase: PhaseDay,
+			minLevel: 255, maxLevel: 255, wantTransition: true},
+		{name: "dusk ramps down", lat: 51.5074, lon: -0.1278,
+			at: clock(t, midsummer, "21:40"), phase: PhaseDusk,
+			minLevel: 140, maxLevel: 190, wantTransition: true},
+		{name: "after dusk", lat: 51.5074, lon: -0.1278,
+			at: clock(t, midsummer, "23:30"), phase: PhaseNight,
+			minLevel: 30, maxLevel: 30, wantTransition: true},
+		{name: "polar day", lat: 69.65, lon: 18.96,
+			at:    clock(t, time.Date(2020, 6, 21, 0, 0, 0, 0, time.UTC), "00:00"),
+			phase: PhaseDay, minLevel: 255, maxLevel: 255},
+		{name: "polar night", lat: 85, lon: 0,
+			at: clock(t, midwinter, "12:00"), phase: PhaseNight,
+			minLevel: 30, maxLevel: 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lat, lon := tt.lat, tt.lon
+			cfg := Config{Latitude: &lat, Longitude: &lon}
+			cfg.fillDefaults()
+
+			s := cfg.stateAt(tt.at)
+
+			if s.Phase != tt.phase {
+				t.Errorf("got phase %s, want %s", s.Phase, tt.phase)
+			}
+
+			if s.Level < tt.minLevel || s.Level > tt.maxLevel {
+				t.Errorf("got level %d, want [%d,%d]", s.Level, tt.minLevel, tt.maxLevel)
+			}
+
+			if tt.wantTransition {
+				if s.PhaseStart == nil || s.NextTransition == nil {
+					t.Fatalf("got phase start %v and next transition %v",
+						s.PhaseStart, s.NextTransition)
+				}
+				if s.PhaseStart.After(tt.at) || !s.NextTransition.After(tt.at) {
+					t.Errorf("%s is not between phase start %s and next transition %s",
+						tt.at, s.PhaseStart, s.NextTransition)
+				}
+			} else if s.PhaseStart != nil || s.NextTransition != nil {
+				t.Errorf("got phase start %v and next transition %v, want none",
+					s.PhaseStart, s.NextTransition)
+			}
+		})
+	}
+}
+
+func TestDecodeConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr bool
+	}{
+		{name: "location", raw: `{"latitude": 51.5, "longitude": -0.1}`},
+		{name: "zero night level", raw: `{"latitude": 0, "longitude": 0, "night_level": 0}`},
+		{name: "missing location", raw: `{}`, wantErr: true},
+		{name: "missing longitude", raw: `{"latitude": 51.5}`, wantErr: true},
+		{name: "bad latitude", raw: `{"latitude": 91, "longitude": 0}`, wantErr: true},
+		{name: "bad longitude", raw: `{"latitude": 0, "longitude": -181}`, wantErr: true},
+		{name: "negative transition",
+			raw: `{"latitude": 0, "longitude": 0, "transition": "-1s"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := decodeConfig([]byte(tt.raw))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", cfg)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package solar
+
+import (
+	"math"
+	"time"
+)
+
+// Elevations of the centre of the sun, in degrees, that mark solar events.
+const (
+	// sunriseElevation accounts for atmospheric refraction and the radius of
+	// the solar disc.
+	sunriseElevation = -0.833
+	// civilElevation marks the ends of civil twilight.
+	civilElevation = -6
+)
+
+const (
+	// julian2000 is the Julian date of the J2000 epoch.
+	julian2000 = 2451545.0
+	// julianUnixEpoch is the Julian date of the Unix epoch.
+	julianUnixEpoch = 2440587.5
+	// earthTilt is the obliquity of the ecliptic, in degrees.
+	earthTilt     = 23.4397
+	secondsPerDay = 86400
+)
+
+// Polar describes whether the sun crosses an elevation on a given day.
+type Polar int
+
+const (
+	// Crosses indicates that the sun rises above and sets below the elevation.
+	Crosses Polar = iota
+	// AlwaysAbove indicates that the sun stays above the elevation all day.
+	AlwaysAbove
+	// AlwaysBelow indicates that the sun stays below the elevation all day.
+	AlwaysBelow
+)
+
+var polarNames = map[Polar]string{
+	Crosses:     "crosses",
+	AlwaysAbove: "always_above",
+	AlwaysBelow: "always_below",
+}
+
+// MarshalText encodes the Polar by name.
+func (p Polar) MarshalText() ([]byte, error) { return []byte(polarNames[p]), nil }
+
+// Times holds the solar events of a single day. Events that do not occur on
+// that day, such as sunrise during polar night, are the zero time.
+type Times struct {
+	// Dawn is the start of morning civil twilight.
+	Dawn time.Time `json:"dawn"`
+	// Sunrise is when the upper edge of the sun appears on the horizon.
+	Sunrise time.Time `json:"sunrise"`
+	// Noon is when the sun is highest.
+	Noon time.Time `json:"noon"`
+	// Sunset is when the upper edge of the sun disappears below the horizon.
+	Sunset time.Time `json:"sunset"`
+	// Dusk is the end of evening civil twilight.
+	Dusk time.Time `json:"dusk"`
+	// Sun describes whether the sun rises and sets at all.
+	Sun Polar `json:"sun"`
+	// Twilight describes whether civil twilight begins and ends at all.
+	Twilight Polar `json:"twilight"`
+}
+
+func sin(deg float64) float64 { return math.Sin(deg * math.Pi / 180) }
+func cos(deg float64) float64 { return math.Cos(deg * math.Pi / 180) }
+
+func fromJulian(j float64) time.Time {
+	secs := (j - julianUnixEpoch) * secondsPerDay
+	return time.Unix(0, int64(secs*float64(time.Second))).Round(time.Second)
+}
+
+// Compute calculates the solar events on the day of date, in the location of
+// date, for the given latitude and longitude in degrees. Longitude is positive
+// east of Greenwich.
+//
+// The sunrise equation is used, which is accurate to within a minute or so
+// away from the polar circles.
+func Compute(date time.Time, latitude, longitude float64) Times {
+	y, m, d := date.Date()
+	noon := time.Date(y, m, d, 12, 0, 0, 0, date.Location())
+
+	// Days since J2000 of the local noon, corrected to mean solar time.
+	julianNoon := float64(noon.Unix())/secondsPerDay + julianUnixEpoch
+	n := math.Round(julianNoon - julian2000 + 0.0008)
+	meanNoon := n - longitude/360
+
+	anomaly := math.Mod(357.5291+0.98560028*meanNoon, 360)
+	centre := 1.9148*sin(anomaly) + 0.0200*sin(2*anomaly) + 0.0003*sin(3*anomaly)
+	eclipticLon := math.Mod(anomaly+centre+180+102.9372, 360)
+
+	transit := julian2000 + meanNoon + 0.0053*sin(anomaly) - 0.0069*sin(2*eclipticLon)
+
+	sinDecl := sin(eclipticLon) * sin(earthTilt)
+	cosDecl := math.Cos(math.Asin(sinDecl))
+
+	t := Times{Noon: fromJulian(transit).In(date.Location())}
+
+	// crossing finds when the sun crosses the elevation before and after the
+	// transit.
+	crossing := func(elevation float64) (time.Time, time.Time, Polar) {
+		cosHour := (sin(elevation) - sin(latitude)*sinDecl) / (cos(latitude) * cosDecl)
+
+		if cosHour < -1 {
+			return time.Time{}, time.Time{}, AlwaysAbove
+		} else if cosHour > 1 {
+			return time.Time{}, time.Time{}, AlwaysBelow
+		}
+
+		hour := math.Acos(cosHour) * 180 / math.Pi
+
+		return fromJulian(transit - hour/360).In(date.Location()),
+			fromJulian(transit + hour/360).In(date.Location()), Crosses
+	}
+
+	t.Sunrise, t.Sunset, t.Sun = crossing(sunriseElevation)
+	t.Dawn, t.Dusk, t.Twilight = crossing(civilElevation)
+
+	return t
+}
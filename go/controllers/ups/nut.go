@@ -0,0 +1,175 @@
+package ups
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+)
+
+// nutTimeout bounds each exchange with upsd, unless the context has an earlier
+// deadline.
+const nutTimeout = 5 * time.Second
+
+// nutSource reads the state of a UPS from a Network UPS Tools upsd server,
+// using its text protocol.
+type nutSource struct {
+	address string
+	ups     string
+}
+
+// read connects to upsd and reads the variables of the UPS.
+func (s *nutSource) read(ctx context.Context) (Reading, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return Reading{}, errors.Wrap(err, "could not connect to upsd")
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(nutTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	if err = conn.SetDeadline(deadline); err != nil {
+		return Reading{}, errors.Wrap(err, "could not set deadline for upsd")
+	}
+
+	vars, err := listVars(conn, s.ups)
+	if err != nil {
+		return Reading{}, err
+	}
+
+	// Say goodbye, so that upsd does not log a dropped connection.
+	fmt.Fprint(conn, "LOGOUT\n")
+
+	return nutReading(vars)
+}
+
+// listVars asks upsd for every variable of the UPS, returning them by name.
+func listVars(rw io.ReadWriter, ups string) (map[string]string, error) {
+	if _, err := fmt.Fprintf(rw, "LIST VAR %s\n", ups); err != nil {
+		return nil, errors.Wrap(err, "could not send request to upsd")
+	}
+
+	sc := bufio.NewScanner(rw)
+	vars := make(map[string]string)
+	begun := false
+
+	for sc.Scan() {
+		words, err := splitNUT(sc.Text())
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case len(words) > 0 && words[0] == "ERR":
+			return nil, errors.Errorf("upsd refused to list variables: %s",
+				strings.Join(words[1:], " "))
+		case len(words) == 4 && words[0] == "BEGIN" && words[3] == ups:
+			begun = true
+		case len(words) == 4 && words[0] == "END" && words[3] == ups:
+			return vars, nil
+		case begun && len(words) == 4 && words[0] == "VAR" && words[1] == ups:
+			vars[words[2]] = words[3]
+		default:
+			return nil, errors.Errorf("unexpected line from upsd: %s", sc.Text())
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, errors.Wrap(err, "could not read response from upsd")
+	}
+
+	return nil, errors.New("upsd closed the connection before listing every variable")
+}
+
+// splitNUT splits a line of the NUT protocol into words. Words are separated
+// by spaces, unless enclosed in double quotes, within which a backslash escapes
+// the following character.
+func splitNUT(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+
+	inWord, quoted, escaped := false, false, false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+			inWord = true
+		case r == ' ' && !quoted:
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quoted || escaped {
+		return nil, errors.Errorf("unterminated quote in line from upsd: %s", line)
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// nutReading interprets the variables of a UPS. The status flags "OB" and "LB"
+// mean on battery and low battery.
+func nutReading(vars map[string]string) (Reading, error) {
+	status, ok := vars["ups.status"]
+	if !ok {
+		return Reading{}, errors.New("upsd did not report ups.status")
+	}
+
+	var r Reading
+
+	for _, flag := range strings.Fields(status) {
+		switch flag {
+		case "OB":
+			r.OnBattery = true
+		case "LB":
+			r.Low = true
+		}
+	}
+
+	if v, ok := vars["battery.charge"]; ok {
+		charge, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return r, errors.Wrap(err, "malformed battery.charge from upsd")
+		}
+		r.Charge = &charge
+	}
+
+	if v, ok := vars["battery.runtime"]; ok {
+		seconds, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return r, errors.Wrap(err, "malformed battery.runtime from upsd")
+		}
+		runtime := pipanel.Duration(seconds * float64(time.Second))
+		r.Runtime = &runtime
+	}
+
+	return r, nil
+}
@@ -0,0 +1,198 @@
+package ups
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+)
+
+func TestSplitNUT(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    []string
+		wantErr bool
+	}{
+		{line: "", want: nil},
+		{line: "BEGIN LIST VAR ups", want: []string{"BEGIN", "LIST", "VAR", "ups"}},
+		{line: "  extra   spaces ", want: []string{"extra", "spaces"}},
+		{
+			line: `VAR ups ups.status "OB LB"`,
+			want: []string{"VAR", "ups", "ups.status", "OB LB"},
+		},
+		{
+			line: `VAR ups device.model "Back-UPS \"ES\" 700"`,
+			want: []string{"VAR", "ups", "device.model", `Back-UPS "ES" 700`},
+		},
+		{line: `VAR ups x "a\\b"`, want: []string{"VAR", "ups", "x", `a\b`}},
+		{line: `VAR ups x ""`, want: []string{"VAR", "ups", "x", ""}},
+		{line: `VAR ups x "open`, wantErr: true},
+		{line: `VAR ups x "escaped\`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := splitNUT(tt.line)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("splitNUT(%q) = %q, expected an error", tt.line, got)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("splitNUT(%q): unexpected error: %v", tt.line, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitNUT(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestNUTReading(t *testing.T) {
+	charge := func(v float64) *float64 { return &v }
+	runtime := func(d time.Duration) *pipanel.Duration {
+		v := pipanel.Duration(d)
+		return &v
+	}
+
+	tests := []struct {
+		name    string
+		vars    map[string]string
+		want    Reading
+		wantErr bool
+	}{
+		{
+			name: "on line",
+			vars: map[string]string{"ups.status": "OL"},
+			want: Reading{},
+		},
+		{
+			name: "on battery",
+			vars: map[string]string{
+				"ups.status":      "OB DISCHRG",
+				"battery.charge":  "87",
+				"battery.runtime": "1260",
+			},
+			want: Reading{
+				OnBattery: true,
+				Charge:    charge(87),
+				Runtime:   runtime(21 * time.Minute),
+			},
+		},
+		{
+			name: "low battery",
+			vars: map[string]string{"ups.status": "OB LB", "battery.charge": "9.5"},
+			want: Reading{OnBattery: true, Low: true, Charge: charge(9.5)},
+		},
+		{
+			name:    "missing status",
+			vars:    map[string]string{"battery.charge": "100"},
+			wantErr: true,
+		},
+		{
+			name:    "malformed charge",
+			vars:    map[string]string{"ups.status": "OL", "battery.charge": "full"},
+			wantErr: true,
+		},
+		{
+			name:    "malformed runtime",
+			vars:    map[string]string{"ups.status": "OL", "battery.runtime": "1h"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nutReading(tt.vars)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// conversation is a fake upsd connection that records the request and replies
+// with a canned response.
+type conversation struct {
+	bytes.Buffer
+	reply *strings.Reader
+}
+
+func (c *conversation) Read(p []byte) (int, error) { return c.reply.Read(p) }
+
+func TestListVars(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "variables",
+			reply: "BEGIN LIST VAR ups\n" +
+				"VAR ups battery.charge \"100\"\n" +
+				"VAR ups ups.status \"OL CHRG\"\n" +
+				"END LIST VAR ups\n",
+			want: map[string]string{
+				"battery.charge": "100",
+				"ups.status":     "OL CHRG",
+			},
+		},
+		{
+			name:    "refused",
+			reply:   "ERR UNKNOWN-UPS\n",
+			wantErr: true,
+		},
+		{
+			name:    "variable for another UPS",
+			reply:   "BEGIN LIST VAR ups\nVAR other ups.status \"OL\"\n",
+			wantErr: true,
+		},
+		{
+			name:    "variable before the list begins",
+			reply:   "VAR ups ups.status \"OL\"\nEND LIST VAR ups\n",
+			wantErr: true,
+		},
+		{
+			name:    "connection closed early",
+			reply:   "BEGIN LIST VAR ups\nVAR ups ups.status \"OL\"\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conv := &conversation{reply: strings.NewReader(tt.reply)}
+
+			got, err := listVars(conv, "ups")
+			if req := conv.String(); req != "LIST VAR ups\n" {
+				t.Errorf("sent request %q", req)
+			}
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
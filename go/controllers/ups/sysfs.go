@@ -0,0 +1,138 @@
+package ups
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+)
+
+// sysfsSource reads the state of a battery from the Linux sysfs power_supply
+// class.
+type sysfsSource struct {
+	root   string
+	supply string
+}
+
+// readAttr reads an attribute of a power supply, returning false if the supply
+// does not have it.
+func (s *sysfsSource) readAttr(supply, attr string) (string, bool, error) {
+	b, err := ioutil.ReadFile(filepath.Join(s.root, supply, attr))
+	if os.IsNotExist(err) {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, errors.Wrapf(err, "could not read %s of %s", attr, supply)
+	}
+
+	return strings.TrimSpace(string(b)), true, nil
+}
+
+// supplies lists the power supplies by type.
+func (s *sysfsSource) supplies() (map[string][]string, error) {
+	entries, err := ioutil.ReadDir(s.root)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list power supplies")
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+
+	byType := make(map[string][]string)
+	for _, name := range names {
+		t, ok, err := s.readAttr(name, "type")
+		if err != nil {
+			return nil, err
+		} else if ok {
+			byType[t] = append(byType[t], name)
+		}
+	}
+
+	return byType, nil
+}
+
+// read reads the state of the battery. The panel is on battery if there are
+// external supplies, such as mains or USB, and none is online; if there are
+// none to be found, the battery reporting that it discharges is taken instead.
+func (s *sysfsSource) read(context.Context) (Reading, error) {
+	byType, err := s.supplies()
+	if err != nil {
+		return Reading{}, err
+	}
+
+	battery := s.supply
+	if len(battery) < 1 {
+		if len(byType["Battery"]) < 1 {
+			return Reading{}, errors.Errorf("no battery found in %s", s.root)
+		}
+		battery = byType["Battery"][0]
+	}
+
+	var r Reading
+
+	status, _, err := s.readAttr(battery, "status")
+	if err != nil {
+		return r, err
+	}
+	r.OnBattery = status == "Discharging"
+
+	external, online := 0, false
+	for t, names := range byType {
+		if t == "Battery" {
+			continue
+		}
+
+		for _, name := range names {
+			v, ok, err := s.readAttr(name, "online")
+			if err != nil {
+				return r, err
+			} else if ok {
+				external++
+				online = online || v == "1"
+			}
+		}
+	}
+
+	if external > 0 {
+		r.OnBattery = !online
+	}
+
+	if v, ok, err := s.readAttr(battery, "capacity"); err != nil {
+		return r, err
+	} else if ok {
+		charge, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return r, errors.Wrapf(err, "malformed capacity of %s", battery)
+		}
+		r.Charge = &charge
+	}
+
+	if v, _, err := s.readAttr(battery, "capacity_level"); err != nil {
+		return r, err
+	} else if v == "Low" || v == "Critical" {
+		r.Low = true
+	}
+
+	if v, ok, err := s.readAttr(battery, "time_to_empty_now"); err != nil {
+		return r, err
+	} else if ok && r.OnBattery {
+		seconds, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return r, errors.Wrapf(err, "malformed time_to_empty_now of %s", battery)
+		}
+		runtime := pipanel.Duration(time.Duration(seconds) * time.Second)
+		r.Runtime = &runtime
+	}
+
+	return r, nil
+}
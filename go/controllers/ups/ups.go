@@ -0,0 +1,516 @@
+// Package ups provides a controller that monitors the power source of the
+// panel, such as a UPS served by Network UPS Tools or a battery known to the
+// kernel. It alerts the user when the panel switches to battery power or the
+// battery runs low, and shuts the panel down safely before the battery is
+// exhausted.
+package ups
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+	"github.com/BenJetson/pipanel/go/logfmt"
+)
+
+var _ pipanel.Controller = (*Controller)(nil)
+var _ pipanel.ConfigDescriber = (*Controller)(nil)
+var _ pipanel.RouteProvider = (*Controller)(nil)
+
+const (
+	nutAddressDefault    = "localhost:3493"
+	upsDefault           = "ups"
+	sysfsRootDefault     = "/sys/class/power_supply"
+	pollIntervalDefault  = pipanel.Duration(10 * time.Second)
+	lowChargeDefault     = 20
+	shutdownDelayDefault = pipanel.Duration(time.Minute)
+	soundDefault         = "tone"
+	alertTimeoutDefault  = pipanel.Duration(30 * time.Second)
+)
+
+// Source names a way of reading the state of the power source.
+type Source string
+
+const (
+	// SourceNUT reads the state of a UPS from a Network UPS Tools upsd server.
+	SourceNUT Source = "nut"
+	// SourceSysfs reads the state of a battery from the sysfs power_supply
+	// class.
+	SourceSysfs Source = "sysfs"
+)
+
+// Config specifies the options that modify the behavior of Controller.
+type Config struct {
+	// Source selects how the state of the power source is read: "nut" or
+	// "sysfs".
+	//
+	// Defaults to "nut" if not set.
+	Source Source `json:"source"`
+	// NUTAddress is the host and port of the upsd server.
+	//
+	// Defaults to "localhost:3493" if not set.
+	NUTAddress string `json:"nut_address"`
+	// UPS is the name of the UPS on the upsd server.
+	//
+	// Defaults to "ups" if not set.
+	UPS string `json:"ups"`
+	// SysfsRoot is the directory containing the power supplies.
+	//
+	// Defaults to "/sys/class/power_supply" if not set.
+	SysfsRoot string `json:"sysfs_root"`
+	// Supply is the name of the battery within SysfsRoot.
+	//
+	// If not set, the first battery found is used.
+	Supply string `json:"supply"`
+	// PollInterval is how often the power source is read.
+	//
+	// Defaults to ten seconds if not set.
+	PollInterval pipanel.Duration `json:"poll_interval"`
+	// LowCharge is the charge, in percent, at or below which the battery is
+	// considered low, in addition to when the power source reports it so.
+	//
+	// Defaults to 20 if not set.
+	LowCharge uint8 `json:"low_charge"`
+	// ShutdownCharge is the charge, in percent, at or below which the panel
+	// shuts down while on battery. If the charge is unknown, the panel shuts
+	// down once the battery is low instead.
+	//
+	// If not set, the panel is never shut down.
+	ShutdownCharge *uint8 `json:"shutdown_charge"`
+	// ShutdownDelay is how long the shutdown counts down on-screen, during
+	// which the user may cancel it. Should the battery still be below
+	// ShutdownCharge at the next reading, the shutdown is requested again.
+	//
+	// Defaults to one minute if not set.
+	ShutdownDelay pipanel.Duration `json:"shutdown_delay"`
+	// Sound is the name of the sound played with warnings.
+	//
+	// Defaults to "tone" if not set.
+	Sound string `json:"sound"`
+	// AlertTimeout is how long alerts stay on-screen.
+	//
+	// Defaults to thirty seconds if not set.
+	AlertTimeout pipanel.Duration `json:"alert_timeout"`
+}
+
+// fillDefaults will overwrite zero values with the default configuration.
+func (cfg *Config) fillDefaults() {
+	if len(cfg.Source) < 1 {
+		cfg.Source = SourceNUT
+	}
+	if len(cfg.NUTAddress) < 1 {
+		cfg.NUTAddress = nutAddressDefault
+	}
+	if len(cfg.UPS) < 1 {
+		cfg.UPS = upsDefault
+	}
+	if len(cfg.SysfsRoot) < 1 {
+		cfg.SysfsRoot = sysfsRootDefault
+	}
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = pollIntervalDefault
+	}
+	if cfg.LowCharge == 0 {
+		cfg.LowCharge = lowChargeDefault
+	}
+	if cfg.ShutdownDelay == 0 {
+		cfg.ShutdownDelay = shutdownDelayDefault
+	}
+	if len(cfg.Sound) < 1 {
+		cfg.Sound = soundDefault
+	}
+	if cfg.AlertTimeout == 0 {
+		cfg.AlertTimeout = alertTimeoutDefault
+	}
+}
+
+// validate checks that the configuration values are sensible.
+func (cfg *Config) validate() error {
+	switch cfg.Source {
+	case SourceNUT, SourceSysfs:
+	default:
+		return errors.Errorf("no such source '%s'", cfg.Source)
+	}
+
+	if cfg.PollInterval < 0 {
+		return errors.New("poll_interval cannot be negative")
+	} else if cfg.LowCharge > 100 {
+		return errors.New("low_charge must be on the range [0,100]")
+	} else if cfg.ShutdownCharge != nil && *cfg.ShutdownCharge > 100 {
+		return errors.New("shutdown_charge must be on the range [0,100]")
+	} else if cfg.ShutdownDelay < 0 {
+		return errors.New("shutdown_delay cannot be negative")
+	} else if cfg.AlertTimeout < 0 {
+		return errors.New("alert_timeout cannot be negative")
+	}
+
+	return nil
+}
+
+// source returns the source that the configuration selects.
+func (cfg *Config) source() source {
+	if cfg.Source == SourceSysfs {
+		return &sysfsSource{root: cfg.SysfsRoot, supply: cfg.Supply}
+	}
+
+	return &nutSource{address: cfg.NUTAddress, ups: cfg.UPS}
+}
+
+// source reads the state of the power source.
+type source interface {
+	read(ctx context.Context) (Reading, error)
+}
+
+// A Reading is the state of the power source at one moment.
+type Reading struct {
+	// OnBattery is true while the panel runs on battery power.
+	OnBattery bool `json:"on_battery"`
+	// Low is true while the battery is low.
+	Low bool `json:"low_battery"`
+	// Charge is the charge of the battery in percent, if known.
+	Charge *float64 `json:"charge,omitempty"`
+	// Runtime is the estimated time until the battery is exhausted, if known.
+	Runtime *pipanel.Duration `json:"runtime,omitempty"`
+}
+
+// State describes the power source and what the Controller has done about it.
+type State struct {
+	Reading
+	// Source is how the power source is read.
+	Source Source `json:"source"`
+	// UpdatedAt is when the power source was last read successfully, or nil
+	// if it has not been yet.
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	// Error describes why the power source could not be read last time.
+	Error string `json:"error,omitempty"`
+	// ShutdownScheduled is true while the shutdown requested because of the
+	// battery is pending.
+	ShutdownScheduled bool `json:"shutdown_scheduled"`
+}
+
+// Controller implements pipanel.Controller, monitoring the power source of
+// the panel.
+type Controller struct {
+	frontend *pipanel.Frontend
+	log      *logrus.Entry
+	cfg      Config
+	source   source
+
+	// stateMux guards state and shutdownReason, which is the reason given for
+	// the shutdown last requested because of the battery. Whether it is still
+	// pending is up to the Frontend, since it may have been cancelled.
+	stateMux       sync.RWMutex
+	state          State
+	shutdownReason string
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// New creates a Controller instance for the given frontend.
+func New(f *pipanel.Frontend) *Controller { return &Controller{frontend: f} }
+
+// run reads the power source until ctx is done.
+func (c *Controller) run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(c.cfg.PollInterval))
+	defer ticker.Stop()
+
+	first := true
+
+	for {
+		r, err := c.source.read(ctx)
+		if err != nil {
+			c.readFailed(err)
+		} else {
+			c.update(ctx, r, first)
+			first = false
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// readFailed records that the power source could not be read, logging the
+// error unless it is the same as last time.
+func (c *Controller) readFailed(err error) {
+	c.stateMux.Lock()
+	repeated := c.state.Error == err.Error()
+	c.state.Error = err.Error()
+	c.stateMux.Unlock()
+
+	if !repeated {
+		logfmt.WithError(c.log, err).Errorln("Problem when reading power source.")
+	}
+}
+
+// update records a reading, alerting the user to changes and shutting the
+// panel down if the battery is nearly exhausted. The first reading only
+// alerts the user if the panel is already on battery.
+func (c *Controller) update(ctx context.Context, r Reading, first bool) {
+	if r.Charge != nil && *r.Charge <= float64(c.cfg.LowCharge) {
+		r.Low = true
+	}
+
+	now := time.Now()
+
+	c.stateMux.Lock()
+	prev := c.state.Reading
+	recovered := len(c.state.Error) > 0
+	c.state.Reading, c.state.UpdatedAt, c.state.Error = r, &now, ""
+	c.stateMux.Unlock()
+
+	if recovered {
+		c.log.Println("Power source can be read again.")
+	}
+
+	if first {
+		c.log.WithFields(readingFields(r)).Println("Read power source.")
+	}
+
+	switch {
+	case r.OnBattery && (first || !prev.OnBattery):
+		c.log.WithFields(readingFields(r)).Warnln("Running on battery power.")
+		c.alert(ctx, "The panel is running on battery power"+chargeSuffix(r)+".",
+			"battery-caution", pipanel.PriorityHigh, true)
+	case !r.OnBattery && prev.OnBattery && !first:
+		c.log.WithFields(readingFields(r)).Println("External power restored.")
+		c.alert(ctx, "External power has been restored"+chargeSuffix(r)+".",
+			"battery-good-charging", pipanel.PriorityNormal, false)
+		c.cancelShutdown(ctx)
+	}
+
+	if r.OnBattery && r.Low && (first || !prev.Low) {
+		c.log.WithFields(readingFields(r)).Warnln("Battery is low.")
+		c.alert(ctx, "The battery is low"+chargeSuffix(r)+".",
+			"battery-low", pipanel.PriorityCritical, true)
+	}
+
+	if r.OnBattery && c.belowShutdownCharge(r) {
+		c.scheduleShutdown(ctx, r)
+	}
+}
+
+// belowShutdownCharge reports whether the battery is so low that the panel
+// should shut down.
+func (c *Controller) belowShutdownCharge(r Reading) bool {
+	if c.cfg.ShutdownCharge == nil {
+		return false
+	} else if r.Charge == nil {
+		return r.Low
+	}
+
+	return *r.Charge <= float64(*c.cfg.ShutdownCharge)
+}
+
+// shutdownPending reports whether the shutdown last requested because of the
+// battery is still pending.
+func (c *Controller) shutdownPending() bool {
+	c.stateMux.RLock()
+	reason := c.shutdownReason
+	c.stateMux.RUnlock()
+
+	if len(reason) < 1 {
+		return false
+	}
+
+	p, ok := c.frontend.PendingPowerAction()
+	return ok && p.Action == pipanel.PowerActionShutdown && p.Reason == reason
+}
+
+// scheduleShutdown requests a delayed shutdown, unless the one requested
+// because of the battery is still pending. The shutdown is protected, so that
+// no other delayed power action replaces it; should it have been cancelled,
+// it is requested again.
+func (c *Controller) scheduleShutdown(ctx context.Context, r Reading) {
+	if c.shutdownPending() {
+		return
+	}
+
+	reason := "The battery is nearly exhausted" + chargeSuffix(r) + "."
+
+	c.log.WithFields(readingFields(r)).
+		Warnln("Battery is nearly exhausted; shutting down.")
+
+	err := c.frontend.DoProtectedPowerAction(ctx, pipanel.PowerEvent{
+		Action: pipanel.PowerActionShutdown,
+		Delay:  time.Duration(c.cfg.ShutdownDelay),
+		Reason: reason,
+	})
+	if err != nil {
+		logfmt.WithError(c.log, err).Errorln("Problem when requesting shutdown.")
+		return
+	}
+
+	c.stateMux.Lock()
+	c.shutdownReason = reason
+	c.stateMux.Unlock()
+}
+
+// cancelShutdown cancels the shutdown requested because of the battery, if it
+// is still pending.
+func (c *Controller) cancelShutdown(ctx context.Context) {
+	pending := c.shutdownPending()
+
+	c.stateMux.Lock()
+	c.shutdownReason = ""
+	c.stateMux.Unlock()
+
+	if !pending {
+		return
+	}
+
+	if _, err := c.frontend.CancelPowerAction(ctx); err != nil {
+		logfmt.WithError(c.log, err).Errorln("Problem when cancelling shutdown.")
+		return
+	}
+
+	c.log.Println("Cancelled shutdown since external power was restored.")
+}
+
+// alert shows an alert, playing the warning sound along with it if asked.
+func (c *Controller) alert(ctx context.Context, msg, icon string,
+	priority pipanel.AlertPriority, sound bool) {
+
+	err := c.frontend.ShowAlert(ctx, pipanel.AlertEvent{
+		Message:  msg,
+		Icon:     icon,
+		Timeout:  time.Duration(c.cfg.AlertTimeout),
+		Priority: priority,
+	})
+	if err != nil {
+		logfmt.WithError(c.log, err).Errorln("Problem when showing alert.")
+	}
+
+	if !sound {
+		return
+	}
+
+	err = c.frontend.PlaySound(ctx, pipanel.SoundEvent{Sound: c.cfg.Sound})
+	if err != nil {
+		logfmt.WithError(c.log, err).Errorln("Problem when playing warning sound.")
+	}
+}
+
+// chargeSuffix describes the charge of the battery, if known, for appending
+// to a message.
+func chargeSuffix(r Reading) string {
+	if r.Charge == nil {
+		return ""
+	}
+
+	return fmt.Sprintf(" (%.0f%%)", *r.Charge)
+}
+
+// readingFields returns the log fields describing a reading.
+func readingFields(r Reading) logrus.Fields {
+	f := logrus.Fields{
+		"on_battery": r.OnBattery,
+		"low":        r.Low,
+	}
+
+	if r.Charge != nil {
+		f["charge"] = *r.Charge
+	}
+	if r.Runtime != nil {
+		f["runtime"] = time.Duration(*r.Runtime)
+	}
+
+	return f
+}
+
+// Routes serves the State at "/state".
+func (c *Controller) Routes() map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{"/state": c.handleState}
+}
+
+func (c *Controller) handleState(w http.ResponseWriter, r *http.Request) {
+	c.stateMux.RLock()
+	s := c.state
+	c.stateMux.RUnlock()
+
+	s.ShutdownScheduled = c.shutdownPending()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s); err != nil {
+		logfmt.WithError(c.log, err).WithContext(r.Context()).
+			Errorln("Problem when writing power source state.")
+	}
+}
+
+// decodeConfig decodes and validates the raw JSON configuration, filling in
+// defaults.
+func decodeConfig(rawCfg json.RawMessage) (Config, error) {
+	var cfg Config
+
+	if len(rawCfg) > 0 {
+		d := json.NewDecoder(bytes.NewReader(rawCfg))
+		d.DisallowUnknownFields()
+
+		if err := d.Decode(&cfg); err != nil {
+			return cfg, errors.Wrap(err, "malformed JSON for ups configuration")
+		}
+	}
+
+	cfg.fillDefaults()
+
+	return cfg, cfg.validate()
+}
+
+// ConfigTemplate returns a pointer to a zero Config.
+func (c *Controller) ConfigTemplate() interface{} { return &Config{} }
+
+// ValidateConfig decodes and validates the raw JSON configuration without
+// applying it.
+func (c *Controller) ValidateConfig(rawCfg json.RawMessage) error {
+	_, err := decodeConfig(rawCfg)
+	return err
+}
+
+// Init starts this Controller.
+func (c *Controller) Init(log *logrus.Entry, rawCfg json.RawMessage) error {
+	c.log = log
+
+	cfg, err := decodeConfig(rawCfg)
+	if err != nil {
+		return err
+	}
+
+	c.cfg, c.source = cfg, cfg.source()
+
+	c.stateMux.Lock()
+	c.state = State{Source: cfg.Source}
+	c.stateMux.Unlock()
+
+	ctx, stop := context.WithCancel(context.Background())
+	c.stop, c.done = stop, make(chan struct{})
+
+	go func(done chan struct{}) {
+		defer close(done)
+		c.run(ctx)
+	}(c.done)
+
+	return nil
+}
+
+// Cleanup stops this Controller.
+func (c *Controller) Cleanup() error {
+	if c.stop != nil {
+		c.stop()
+		<-c.done
+		c.stop, c.done = nil, nil
+	}
+
+	return nil
+}
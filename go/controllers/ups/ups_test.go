@@ -0,0 +1,97 @@
+package ups
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+)
+
+// quiet is an Alerter, AudioPlayer and PowerManager that does nothing.
+type quiet struct{}
+
+func (quiet) Init(*logrus.Entry, json.RawMessage) error                     { return nil }
+func (quiet) Cleanup() error                                                { return nil }
+func (quiet) ShowAlert(ctx context.Context, e pipanel.AlertEvent) error     { return nil }
+func (quiet) PlaySound(ctx context.Context, e pipanel.SoundEvent) error     { return nil }
+func (quiet) DoPowerAction(ctx context.Context, e pipanel.PowerEvent) error { return nil }
+
+func TestShutdownFollowsPendingAction(t *testing.T) {
+	log := logrus.New()
+	log.Out = ioutil.Discard
+
+	f := &pipanel.Frontend{Alerter: quiet{}, AudioPlayer: quiet{}, PowerManager: quiet{}}
+	if err := f.Init(logrus.NewEntry(log), &pipanel.FrontendConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Cleanup()
+
+	cfg, err := decodeConfig([]byte(`{"shutdown_charge": 10, "shutdown_delay": "1h"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(f)
+	c.log, c.cfg = logrus.NewEntry(log), cfg
+
+	charge := func(percent float64) *float64 { return &percent }
+	low := Reading{OnBattery: true, Charge: charge(5)}
+	mains := Reading{OnBattery: false, Charge: charge(5)}
+
+	ctx := context.Background()
+	other := pipanel.PowerEvent{Action: pipanel.PowerActionReboot, Delay: time.Hour}
+
+	steps := []struct {
+		desc          string
+		do            func() error
+		wantErr       error
+		wantScheduled bool
+	}{
+		{
+			desc:          "battery nearly exhausted",
+			do:            func() error { c.update(ctx, low, true); return nil },
+			wantScheduled: true,
+		},
+		{
+			desc:          "another action requested",
+			do:            func() error { return f.DoPowerAction(ctx, other) },
+			wantErr:       pipanel.ErrPowerActionPending,
+			wantScheduled: true,
+		},
+		{
+			desc: "shutdown cancelled",
+			do: func() error {
+				_, err := f.CancelPowerAction(ctx)
+				return err
+			},
+		},
+		{
+			desc:          "battery still nearly exhausted",
+			do:            func() error { c.update(ctx, low, false); return nil },
+			wantScheduled: true,
+		},
+		{
+			desc: "external power restored",
+			do:   func() error { c.update(ctx, mains, false); return nil },
+		},
+	}
+
+	for _, s := range steps {
+		if err := s.do(); errors.Cause(err) != s.wantErr {
+			t.Fatalf("%s: got error %v, want %v", s.desc, err, s.wantErr)
+		}
+
+		p, pending := f.PendingPowerAction()
+		scheduled := pending && p.Action == pipanel.PowerActionShutdown
+		if scheduled != s.wantScheduled || c.shutdownPending() != s.wantScheduled {
+			t.Fatalf("%s: shutdown pending %t (%v), want %t",
+				s.desc, scheduled, p, s.wantScheduled)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package pipanel
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// Easing names a curve that a brightness fade follows over time.
+type Easing string
+
+const (
+	// EaseLinear changes the brightness at a constant rate.
+	EaseLinear Easing = "linear"
+	// EaseIn starts slowly and speeds up towards the end of the fade.
+	EaseIn Easing = "ease-in"
+	// EaseOut starts quickly and slows down towards the end of the fade.
+	EaseOut Easing = "ease-out"
+	// EaseInOut starts and ends slowly, and is quickest in the middle.
+	EaseInOut Easing = "ease-in-out"
+)
+
+// easingCurves maps each easing to a function that maps the fraction of the
+// fade duration elapsed to the fraction of the change in brightness applied,
+// both on the range [0,1].
+var easingCurves = map[Easing]func(t float64) float64{
+	EaseLinear: func(t float64) float64 { return t },
+	EaseIn:     func(t float64) float64 { return t * t },
+	EaseOut:    func(t float64) float64 { return t * (2 - t) },
+	EaseInOut: func(t float64) float64 {
+		if t < 0.5 {
+			return 2 * t * t
+		}
+		return -1 + (4-2*t)*t
+	},
+}
+
+// Apply maps the fraction of the fade duration elapsed to the fraction of the
+// change in brightness that should have been applied. An empty or unknown
+// Easing is treated as EaseLinear.
+func (e Easing) Apply(t float64) float64 {
+	curve, ok := easingCurves[e]
+	if !ok {
+		curve = easingCurves[EaseLinear]
+	}
+
+	return curve(t)
+}
+
+// UnmarshalJSON decodes an Easing, rejecting unknown curves.
+func (e *Easing) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errors.Wrap(err, "easing must be a string")
+	}
+
+	if _, ok := easingCurves[Easing(s)]; !ok && len(s) > 0 {
+		return errors.Errorf("unknown easing '%s'", s)
+	}
+
+	*e = Easing(s)
+	return nil
+}
@@ -0,0 +1,80 @@
+package pipanel
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestEasingApply(t *testing.T) {
+	tests := []struct {
+		easing Easing
+		t      float64
+		want   float64
+	}{
+		{EaseLinear, 0, 0},
+		{EaseLinear, 0.25, 0.25},
+		{EaseLinear, 1, 1},
+		{EaseIn, 0, 0},
+		{EaseIn, 0.5, 0.25},
+		{EaseIn, 1, 1},
+		{EaseOut, 0, 0},
+		{EaseOut, 0.5, 0.75},
+		{EaseOut, 1, 1},
+		{EaseInOut, 0, 0},
+		{EaseInOut, 0.25, 0.125},
+		{EaseInOut, 0.5, 0.5},
+		{EaseInOut, 0.75, 0.875},
+		{EaseInOut, 1, 1},
+		{"", 0.25, 0.25},
+		{"bounce", 0.25, 0.25},
+	}
+
+	for _, tt := range tests {
+		if got := tt.easing.Apply(tt.t); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%q.Apply(%v) = %v, want %v", tt.easing, tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestEasingMonotonic(t *testing.T) {
+	for easing := range easingCurves {
+		prev := easing.Apply(0)
+		for i := 1; i <= 100; i++ {
+			v := easing.Apply(float64(i) / 100)
+			if v < prev {
+				t.Errorf("%s decreases at %v", easing, float64(i)/100)
+			}
+			prev = v
+		}
+	}
+}
+
+func TestEasingUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Easing
+		wantErr bool
+	}{
+		{in: `"linear"`, want: EaseLinear},
+		{in: `"ease-in-out"`, want: EaseInOut},
+		{in: `""`, want: ""},
+		{in: `"bounce"`, wantErr: true},
+		{in: `1`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		var e Easing
+		err := json.Unmarshal([]byte(tt.in), &e)
+
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %q", tt.in, e)
+			}
+		} else if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.in, err)
+		} else if e != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.in, e, tt.want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package pipanel
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrorList collects the errors of several independent operations, such as
+// cleaning up each of the components of a Frontend.
+type ErrorList []error
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, err := range l {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Err returns nil if the list is empty, or else the list itself.
+func (l ErrorList) Err() error {
+	if len(l) < 1 {
+		return nil
+	}
+
+	return l
+}
+
+// UnsupportedError is returned by a component that is asked to do something it
+// does not support, such as a PowerManager asked to hibernate a system that
+// cannot.
+type UnsupportedError struct {
+	// Feature describes what is not supported.
+	Feature string
+}
+
+func (e *UnsupportedError) Error() string {
+	return e.Feature + " is not supported"
+}
+
+// Unsupported returns an UnsupportedError for the feature, described by the
+// format and arguments as understood by fmt.Sprintf.
+func Unsupported(format string, args ...interface{}) error {
+	return errors.WithStack(&UnsupportedError{
+		Feature: fmt.Sprintf(format, args...),
+	})
+}
+
+// IsUnsupported reports whether the cause of err is an UnsupportedError.
+func IsUnsupported(err error) bool {
+	_, ok := errors.Cause(err).(*UnsupportedError)
+	return ok
+}
@@ -1,6 +1,11 @@
 package pipanel
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
 
 // An AlertEvent contains information about an alert display request.
 type AlertEvent struct {
@@ -15,6 +20,78 @@ type AlertEvent struct {
 	// Icon is the name of a gtk icon that should be displayed on-screen.
 	// If this is blank, a default icon is used.
 	Icon string `json:"icon"`
+	// Actions are the labels of buttons offered to the user in addition to
+	// acknowledging the alert. When present, the alert is interactive: the
+	// server waits for the alert to be dismissed and responds with an
+	// AlertDismissal.
+	Actions []string `json:"actions,omitempty"`
+	// Priority is the importance of the alert, which may decide how far the
+	// display is brightened to show it. Defaults to PriorityNormal.
+	Priority AlertPriority `json:"priority,omitempty"`
+}
+
+// AlertPriority describes the importance of an alert.
+type AlertPriority string
+
+const (
+	// PriorityLow is for alerts that can wait to be noticed.
+	PriorityLow AlertPriority = "low"
+	// PriorityNormal is for most alerts.
+	PriorityNormal AlertPriority = "normal"
+	// PriorityHigh is for alerts that should be noticed quickly.
+	PriorityHigh AlertPriority = "high"
+	// PriorityCritical is for alerts that must be noticed immediately.
+	PriorityCritical AlertPriority = "critical"
+)
+
+// Valid reports whether the priority is known. The empty priority is valid,
+// and means PriorityNormal.
+func (p AlertPriority) Valid() bool {
+	switch p {
+	case "", PriorityLow, PriorityNormal, PriorityHigh, PriorityCritical:
+		return true
+	}
+
+	return false
+}
+
+// UnmarshalJSON decodes an AlertPriority, rejecting unknown priorities.
+func (p *AlertPriority) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errors.Wrap(err, "alert priority must be a string")
+	}
+
+	if !AlertPriority(s).Valid() {
+		return errors.Errorf("unknown alert priority '%s'", s)
+	}
+
+	*p = AlertPriority(s)
+	return nil
+}
+
+// DismissReason describes why an alert was dismissed.
+type DismissReason string
+
+const (
+	// DismissAcknowledged indicates that the user acknowledged the alert.
+	DismissAcknowledged DismissReason = "acknowledged"
+	// DismissAction indicates that the user chose one of the alert's actions.
+	DismissAction DismissReason = "action"
+	// DismissTimeout indicates that the alert's timeout elapsed.
+	DismissTimeout DismissReason = "timeout"
+	// DismissClosed indicates that the alert was closed by the panel, for
+	// example because the panel is shutting down.
+	DismissClosed DismissReason = "closed"
+)
+
+// An AlertDismissal describes how an interactive alert was dismissed.
+type AlertDismissal struct {
+	// Reason is why the alert was dismissed.
+	Reason DismissReason `json:"reason"`
+	// Action is the action chosen by the user. Empty unless Reason is
+	// DismissAction.
+	Action string `json:"action,omitempty"`
 }
 
 // A SoundEvent contains information about a tone that will be played on the panel.
@@ -23,8 +100,125 @@ type SoundEvent struct {
 	// the sound folder configured in the preference file. Empty string will
 	// result in no sound being played.
 	Sound string `json:"sound"`
+	// Tone is a synthesized tone to play instead of a sound file: either the
+	// name of a tone, such as "beep", "chime" or "siren", or a ringtone in
+	// RTTTL, such as "ding:d=4,o=5,b=120:e6,c6".
+	Tone string `json:"tone,omitempty"`
+	// Volume is the volume at which the sound is played, as a percentage of
+	// the global volume on the range [0,100]. Defaults to 100.
+	Volume *uint8 `json:"volume,omitempty"`
+	// Mode decides how the sound is played alongside sounds that are already
+	// playing. Defaults to the mode configured for the AudioPlayer.
+	Mode PlaybackMode `json:"mode,omitempty"`
+	// Wait makes a sound request return only once the sound has finished
+	// playing, or has been stopped. It is ignored for sounds embedded in
+	// alerts.
+	Wait bool `json:"wait,omitempty"`
+	// Repeat is the number of times the sound is played, or
+	// RepeatUntilAcknowledged. Zero plays the sound once. When the sound is
+	// embedded in an alert, it stops repeating once the alert is dismissed.
+	Repeat SoundRepeat `json:"repeat,omitempty"`
+	// Interval is the number of milliseconds of silence between repeats of
+	// the sound.
+	Interval time.Duration `json:"interval,omitempty"`
+}
+
+// Empty reports whether the event names neither a sound nor a tone, in which
+// case nothing is played.
+func (e SoundEvent) Empty() bool {
+	return len(e.Sound) < 1 && len(e.Tone) < 1
+}
+
+// Repeats reports whether the sound is played more than once.
+func (e SoundEvent) Repeats() bool {
+	return e.Repeat > 1 || e.Repeat == RepeatUntilAcknowledged
+}
+
+// SoundRepeat is the number of times a sound is played. In JSON, it is either
+// a count or "until-acknowledged".
+type SoundRepeat int
+
+// RepeatUntilAcknowledged repeats a sound embedded in an alert until the alert
+// is dismissed. It is only allowed for sounds embedded in alerts.
+const RepeatUntilAcknowledged SoundRepeat = -1
+
+// repeatUntilAcknowledged is the JSON encoding of RepeatUntilAcknowledged.
+const repeatUntilAcknowledged = "until-acknowledged"
+
+// UnmarshalJSON decodes a SoundRepeat from a count or "until-acknowledged".
+func (r *SoundRepeat) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		if s != repeatUntilAcknowledged {
+			return errors.Errorf("unknown repeat '%s'", s)
+		}
+
+		*r = RepeatUntilAcknowledged
+		return nil
+	}
+
+	var n int
+	if err := json.Unmarshal(b, &n); err != nil {
+		return errors.Errorf("repeat must be a count or '%s'",
+			repeatUntilAcknowledged)
+	} else if n < 0 {
+		return errors.Errorf("repeat count %d cannot be negative", n)
+	}
+
+	*r = SoundRepeat(n)
+	return nil
 }
 
+// MarshalJSON encodes a SoundRepeat as a count or "until-acknowledged".
+func (r SoundRepeat) MarshalJSON() ([]byte, error) {
+	if r == RepeatUntilAcknowledged {
+		return json.Marshal(repeatUntilAcknowledged)
+	}
+
+	return json.Marshal(int(r))
+}
+
+// PlaybackMode describes how a sound is played alongside sounds that are
+// already playing.
+type PlaybackMode string
+
+const (
+	// PlaybackMix plays the sound immediately, on top of any others.
+	PlaybackMix PlaybackMode = "mix"
+	// PlaybackQueue plays the sound once all others have finished.
+	PlaybackQueue PlaybackMode = "queue"
+	// PlaybackReplace stops all other sounds and plays the sound immediately.
+	PlaybackReplace PlaybackMode = "replace"
+)
+
+// UnmarshalJSON decodes a PlaybackMode, rejecting unknown modes.
+func (m *PlaybackMode) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errors.Wrap(err, "playback mode must be a string")
+	}
+
+	switch PlaybackMode(s) {
+	case PlaybackMix, PlaybackQueue, PlaybackReplace:
+	default:
+		return errors.Errorf("unknown playback mode '%s'", s)
+	}
+
+	*m = PlaybackMode(s)
+	return nil
+}
+
+// PlaybackEnd describes how playback of a sound ended.
+type PlaybackEnd string
+
+const (
+	// PlaybackFinished indicates that the sound played through.
+	PlaybackFinished PlaybackEnd = "finished"
+	// PlaybackStopped indicates that the sound was stopped, or replaced by
+	// another, before it finished.
+	PlaybackStopped PlaybackEnd = "stopped"
+)
+
 // PowerAction describes a system power action to be taken by the panel.
 type PowerAction string
 
@@ -33,14 +227,39 @@ const (
 	PowerActionShutdown PowerAction = "shutdown"
 	// PowerActionReboot instructs the panel to reboot.
 	PowerActionReboot PowerAction = "reboot"
-	// PowerActionDisplayOff instructs the panel to blank the display.
+	// PowerActionSuspend instructs the panel to suspend to RAM.
+	PowerActionSuspend PowerAction = "suspend"
+	// PowerActionHibernate instructs the panel to hibernate to disk.
+	PowerActionHibernate PowerAction = "hibernate"
+	// PowerActionDisplayOff instructs the panel to blank the display. It is an
+	// alias for a DisplayPowerEvent with DisplayPowerOff.
 	PowerActionDisplayOff PowerAction = "displayOff"
+	// PowerActionDisplayOn instructs the panel to wake the display. It is an
+	// alias for a DisplayPowerEvent with DisplayPowerOn.
+	PowerActionDisplayOn PowerAction = "displayOn"
+	// PowerActionRestartService instructs the panel to restart the PiPanel
+	// service under its supervisor.
+	PowerActionRestartService PowerAction = "restartService"
+	// PowerActionReloadConfig instructs the panel to reload the configuration
+	// file of the PiPanel service.
+	PowerActionReloadConfig PowerAction = "reloadConfig"
+	// PowerActionCancel instructs the panel to cancel the delayed power action
+	// that is pending.
+	PowerActionCancel PowerAction = "cancel"
 )
 
 // A PowerEvent contains information about a system power request.
 type PowerEvent struct {
 	// Action is the power action that should be performed by the panel.
 	Action PowerAction `json:"action"`
+	// Delay is the number of milliseconds to wait before performing the
+	// action. While the action is pending, a countdown alert is shown that
+	// allows the user to cancel it. When zero, the action is performed
+	// immediately.
+	Delay time.Duration `json:"delay,omitempty"`
+	// Reason explains why the action was requested. It is shown to the user
+	// alongside the countdown for a delayed action.
+	Reason string `json:"reason,omitempty"`
 }
 
 // A BrightnessEvent contains information about a brightness change request.
@@ -48,4 +267,62 @@ type BrightnessEvent struct {
 	// Level is the level that the brightness of the panel should be set to.
 	// This must be on the range [0,255].
 	Level uint8 `json:"level"`
+	// Transition is the number of milliseconds over which the brightness
+	// should fade from its current level to Level. When zero, the brightness
+	// changes immediately.
+	Transition time.Duration `json:"transition,omitempty"`
+	// Easing is the curve followed by the fade. Defaults to EaseLinear.
+	Easing Easing `json:"easing,omitempty"`
+}
+
+// A VolumeEvent contains information about a volume change request.
+type VolumeEvent struct {
+	// Level is the global volume of the panel, as a percentage of full volume.
+	// This must be on the range [0,100].
+	Level uint8 `json:"level"`
+}
+
+// DisplayPower describes a power state of the display.
+type DisplayPower string
+
+const (
+	// DisplayPowerOn indicates that the display is lit.
+	DisplayPowerOn DisplayPower = "on"
+	// DisplayPowerStandby indicates that the display is blanked, but can wake
+	// quickly.
+	DisplayPowerStandby DisplayPower = "standby"
+	// DisplayPowerOff indicates that the display is powered down.
+	DisplayPowerOff DisplayPower = "off"
+)
+
+// UnmarshalJSON decodes a DisplayPower, rejecting unknown states.
+func (p *DisplayPower) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errors.Wrap(err, "display power state must be a string")
+	}
+
+	switch DisplayPower(s) {
+	case DisplayPowerOn, DisplayPowerStandby, DisplayPowerOff:
+	default:
+		return errors.Errorf("unknown display power state '%s'", s)
+	}
+
+	*p = DisplayPower(s)
+	return nil
+}
+
+// A DisplayPowerEvent contains information about a display power request.
+type DisplayPowerEvent struct {
+	// State is the power state that the display should be put into.
+	State DisplayPower `json:"state"`
+}
+
+// A ColorTemperatureEvent contains information about a color temperature
+// change request.
+type ColorTemperatureEvent struct {
+	// Kelvin is the color temperature of the white point of the display, such
+	// as 3400 for a warm "night shift". When zero, the display is returned to
+	// its neutral colors.
+	Kelvin uint16 `json:"kelvin"`
 }
@@ -0,0 +1,184 @@
+package pipanel
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// fadeStepInterval is the time between brightness changes during a fade.
+const fadeStepInterval = 25 * time.Millisecond
+
+// levelSetter sets the brightness of the panel to a level immediately.
+type levelSetter func(ctx context.Context, level uint8) error
+
+// fader steps the brightness of a DisplayManager over time, so that any
+// DisplayManager supports transitions without implementing them itself.
+//
+// The levels are set through a levelSetter rather than by holding the lock of
+// the Frontend for the whole fade, so that the Frontend may be reconfigured
+// while fading.
+type fader struct {
+	mux sync.Mutex
+	// stop cancels the fade in progress, if any.
+	stop context.CancelFunc
+	// done is closed once the fade in progress has stopped.
+	done chan struct{}
+	// target is the level most recently requested, which is the final level
+	// of any fade in progress. Only valid when hasTarget is true.
+	target    uint8
+	hasTarget bool
+	// current is the level most recently set. Only valid when hasCurrent is
+	// true. Written by the fade in progress, so it may only be read once the
+	// fade has stopped.
+	current    uint8
+	hasCurrent bool
+}
+
+// cancel stops the fade in progress, if any, and waits for it to stop.
+// The caller must hold fd.mux.
+func (fd *fader) cancel() {
+	if fd.stop == nil {
+		return
+	}
+
+	fd.stop()
+	<-fd.done
+	fd.stop, fd.done = nil, nil
+}
+
+// Stop cancels the fade in progress, if any, and waits for it to stop.
+func (fd *fader) Stop() {
+	fd.mux.Lock()
+	defer fd.mux.Unlock()
+
+	fd.cancel()
+}
+
+// Fading returns the final level of the fade in progress, and whether there is
+// a fade in progress.
+func (fd *fader) Fading() (uint8, bool) {
+	fd.mux.Lock()
+	defer fd.mux.Unlock()
+
+	if fd.done == nil {
+		return 0, false
+	}
+
+	select {
+	case <-fd.done:
+		return 0, false
+	default:
+		return fd.target, true
+	}
+}
+
+// Target returns the level most recently requested, and whether any level has
+// been requested yet.
+func (fd *fader) Target() (uint8, bool) {
+	fd.mux.Lock()
+	defer fd.mux.Unlock()
+
+	return fd.target, fd.hasTarget
+}
+
+// Set cancels the fade in progress, if any, and sets the level immediately.
+func (fd *fader) Set(ctx context.Context, level uint8, set levelSetter) error {
+	fd.mux.Lock()
+	defer fd.mux.Unlock()
+
+	fd.cancel()
+	fd.target, fd.hasTarget = level, true
+
+	if err := set(ctx, level); err != nil {
+		return err
+	}
+
+	fd.current, fd.hasCurrent = level, true
+	return nil
+}
+
+// Fade cancels the fade in progress, if any, and starts fading from the current
+// level to the level of the event over its transition. The fade continues in
+// the background after Fade returns; problems during the fade are logged and
+// stop it early.
+//
+// The current level is the last level set, or else is found using read, which
+// may be nil. When the current level is unknown, the level is set immediately.
+func (fd *fader) Fade(ctx context.Context, log *logrus.Entry, e BrightnessEvent,
+	set levelSetter, read func(ctx context.Context) (uint8, error)) error {
+
+	fd.mux.Lock()
+	defer fd.mux.Unlock()
+
+	fd.cancel()
+	fd.target, fd.hasTarget = e.Level, true
+
+	if !fd.hasCurrent && read != nil {
+		level, err := read(ctx)
+		if err != nil {
+			return errors.Wrap(err, "could not read brightness to fade from")
+		}
+		fd.current, fd.hasCurrent = level, true
+	}
+
+	if !fd.hasCurrent {
+		log.WithContext(ctx).
+			Println("Current brightness unknown; skipping transition.")
+
+		if err := set(ctx, e.Level); err != nil {
+			return err
+		}
+
+		fd.current, fd.hasCurrent = e.Level, true
+		return nil
+	}
+
+	// The fade outlives the request that started it, so it must not inherit
+	// its cancellation; the request ID is kept for logging.
+	fadeCtx, stop := context.WithCancel(context.WithValue(
+		context.Background(), RequestIDKey, ctx.Value(RequestIDKey)))
+	done := make(chan struct{})
+	fd.stop, fd.done = stop, done
+
+	from := fd.current
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(fadeStepInterval)
+		defer ticker.Stop()
+
+		start := time.Now()
+
+		for {
+			select {
+			case <-fadeCtx.Done():
+				return
+			case now := <-ticker.C:
+				t := math.Min(float64(now.Sub(start))/float64(e.Transition), 1)
+				delta := float64(int(e.Level)-int(from)) * e.Easing.Apply(t)
+				level := uint8(math.Round(float64(from) + delta))
+
+				if level != fd.current {
+					if err := set(fadeCtx, level); err != nil {
+						log.WithError(err).WithContext(fadeCtx).
+							Errorln("Brightness transition stopped early.")
+						return
+					}
+					fd.current = level
+				}
+
+				if t == 1 {
+					return
+				}
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,227 @@
+package pipanel
+
+import (
+	"context"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// levelRecorder is a levelSetter that records every level set.
+type levelRecorder struct {
+	mux    sync.Mutex
+	levels []uint8
+}
+
+func (r *levelRecorder) set(ctx context.Context, level uint8) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.levels = append(r.levels, level)
+	return nil
+}
+
+func (r *levelRecorder) Levels() []uint8 {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	return append([]uint8(nil), r.levels...)
+}
+
+func quietLog() *logrus.Entry {
+	log := logrus.New()
+	log.Out = ioutil.Discard
+	return logrus.NewEntry(log)
+}
+
+// waitForFade waits until the fade in progress has finished.
+func waitForFade(t *testing.T, fd *fader) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, fading := fd.Fading(); !fading {
+			return
+		} else if time.Now().After(deadline) {
+			t.Fatal("fade did not finish")
+		}
+		time.Sleep(fadeStepInterval)
+	}
+}
+
+func TestFaderSteps(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to uint8
+		easing   Easing
+	}{
+		{name: "up linear", from: 0, to: 255, easing: EaseLinear},
+		{name: "down linear", from: 200, to: 10, easing: EaseLinear},
+		{name: "up ease-in", from: 50, to: 150, easing: EaseIn},
+		{name: "down ease-in-out", from: 255, to: 0, easing: EaseInOut},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fd fader
+			var r levelRecorder
+
+			ctx := context.Background()
+			if err := fd.Set(ctx, tt.from, r.set); err != nil {
+				t.Fatal(err)
+			}
+
+			err := fd.Fade(ctx, quietLog(), BrightnessEvent{
+				Level:      tt.to,
+				Transition: 10 * fadeStepInterval,
+				Easing:     tt.easing,
+			}, r.set, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if target, fading := fd.Fading(); !fading || target != tt.to {
+				t.Errorf("Fading() = %d, %v; want %d, true", target, fading, tt.to)
+			}
+
+			waitForFade(t, &fd)
+
+			levels := r.Levels()
+			if len(levels) < 3 {
+				t.Fatalf("expected several steps, got %v", levels)
+			} else if levels[0] != tt.from {
+				t.Errorf("first level %d, want %d", levels[0], tt.from)
+			} else if last := levels[len(levels)-1]; last != tt.to {
+				t.Errorf("last level %d, want %d", last, tt.to)
+			}
+
+			for i := 1; i < len(levels); i++ {
+				if levels[i] == levels[i-1] {
+					t.Errorf("level %d set twice in a row: %v", levels[i], levels)
+				}
+				if (tt.to > tt.from) != (levels[i] > levels[i-1]) {
+					t.Errorf("fade changed direction: %v", levels)
+				}
+			}
+		})
+	}
+}
+
+func TestFaderSetCancelsFade(t *testing.T) {
+	var fd fader
+	var r levelRecorder
+
+	ctx := context.Background()
+	if err := fd.Set(ctx, 0, r.set); err != nil {
+		t.Fatal(err)
+	}
+
+	err := fd.Fade(ctx, quietLog(), BrightnessEvent{
+		Level:      255,
+		Transition: time.Hour,
+	}, r.set, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err = fd.Set(ctx, 42, r.set); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, fading := fd.Fading(); fading {
+		t.Error("fade still in progress after Set")
+	}
+
+	time.Sleep(3 * fadeStepInterval)
+
+	levels := r.Levels()
+	if last := levels[len(levels)-1]; last != 42 {
+		t.Errorf("level changed to %d after Set: %v", last, levels)
+	}
+
+	if target, ok := fd.Target(); !ok || target != 42 {
+		t.Errorf("Target() = %d, %v; want 42, true", target, ok)
+	}
+}
+
+func TestFaderUnknownCurrentLevel(t *testing.T) {
+	tests := []struct {
+		name       string
+		read       func(ctx context.Context) (uint8, error)
+		wantLevels []uint8
+		wantFade   bool
+		wantErr    bool
+	}{
+		{
+			name:       "no reader sets immediately",
+			wantLevels: []uint8{200},
+		},
+		{
+			name: "reader gives the level to fade from",
+			read: func(ctx context.Context) (uint8, error) {
+				return 100, nil
+			},
+			wantFade: true,
+		},
+		{
+			name: "reader failure",
+			read: func(ctx context.Context) (uint8, error) {
+				return 0, errors.New("no brightness")
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fd fader
+			var r levelRecorder
+
+			err := fd.Fade(context.Background(), quietLog(), BrightnessEvent{
+				Level:      200,
+				Transition: 4 * fadeStepInterval,
+			}, r.set, tt.read)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			_, fading := fd.Fading()
+			if fading != tt.wantFade {
+				t.Errorf("fading %v, want %v", fading, tt.wantFade)
+			}
+
+			waitForFade(t, &fd)
+
+			levels := r.Levels()
+			if tt.wantLevels != nil && !equalLevels(levels, tt.wantLevels) {
+				t.Errorf("got levels %v, want %v", levels, tt.wantLevels)
+			} else if levels[len(levels)-1] != 200 {
+				t.Errorf("got levels %v, want to end at 200", levels)
+			}
+		})
+	}
+}
+
+func equalLevels(a, b []uint8) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
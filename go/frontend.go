@@ -1,6 +1,12 @@
 package pipanel
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
@@ -11,12 +17,40 @@ type Frontend struct {
 	AudioPlayer
 	PowerManager
 	DisplayManager
+
+	// mux guards the components against being reconfigured while they are
+	// handling an event.
+	mux sync.RWMutex
+	log *logrus.Entry
+	cfg FrontendConfig
+	// fader performs brightness transitions on behalf of the DisplayManager.
+	fader fader
+	// manualMux guards manualBrightnessAt.
+	manualMux          sync.Mutex
+	manualBrightnessAt time.Time
+	// powerMux guards displayPower and powerGen. displayPower is the power
+	// state most recently set, and powerGen counts the changes requested by
+	// users rather than made by the Frontend itself.
+	powerMux     sync.Mutex
+	displayPower DisplayPower
+	powerGen     uint64
+	// waker wakes the display when alerts arrive.
+	waker alertWaker
+	// pendingMux guards pending, the delayed power action waiting to be
+	// performed, if any.
+	pendingMux sync.Mutex
+	pending    *pendingPower
+	// reload requests that the configuration be reloaded; guarded by mux.
+	reload func()
 }
 
 const componentLogKey = "component"
 
 // Init initializes all components of the Frontend.
 func (f *Frontend) Init(log *logrus.Entry, cfg *FrontendConfig) error {
+	f.log = log
+	f.cfg = *cfg
+
 	aLog := log.WithField(componentLogKey, "Alerter")
 	if f.Alerter != nil {
 		if err := f.Alerter.Init(aLog, cfg.AlerterConfig); err != nil {
@@ -45,37 +79,677 @@ func (f *Frontend) Init(log *logrus.Entry, cfg *FrontendConfig) error {
 		}
 	}
 
+	if err := f.shareVolume(); err != nil {
+		return errors.Wrap(err, "failed to share volume with Alerter")
+	}
+
 	return nil
 }
 
-// Cleanup tears down all components of the Frontend.
-func (f *Frontend) Cleanup() error {
-	// FIXME should probably continue trying to clean up other components  even
-	// if one above it returns with error
+// sameRawConfig reports whether two raw JSON configurations are equivalent,
+// ignoring insignificant whitespace.
+func sameRawConfig(a, b json.RawMessage) bool {
+	var ca, cb bytes.Buffer
 
-	if f.Alerter != nil {
-		if err := f.Alerter.Cleanup(); err != nil {
-			return errors.Wrap(err, "failed to cleanup Alerter")
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return bytes.Equal(a, b)
+	}
+
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
+
+// reconfigureComponent applies a new raw configuration to a single component.
+// Components implementing Reconfigurer are asked to reconfigure themselves;
+// all others are torn down and initialized again. Should initialization with
+// the new configuration fail, the old configuration is restored.
+func reconfigureComponent(log *logrus.Entry, c InitCleaner,
+	oldCfg, newCfg json.RawMessage) error {
+
+	if r, ok := c.(Reconfigurer); ok {
+		return r.Reconfigure(newCfg)
+	}
+
+	if err := c.Cleanup(); err != nil {
+		return errors.Wrap(err, "failed to clean up before reinitializing")
+	}
+
+	err := c.Init(log, newCfg)
+	if err == nil {
+		return nil
+	}
+
+	if restoreErr := c.Init(log, oldCfg); restoreErr != nil {
+		return errors.Wrapf(restoreErr,
+			"failed to restore previous configuration after error (%v)", err)
+	}
+
+	return errors.Wrap(err, "failed to reinitialize with new configuration")
+}
+
+// Reconfigure applies a new configuration to all components of the Frontend
+// whose raw configuration has changed since the last Init or Reconfigure.
+//
+// Components that fail to reconfigure keep their previous configuration. The
+// first such failure is returned, after all components have been attempted.
+// Changes to the frontend name cannot be applied and are ignored.
+func (f *Frontend) Reconfigure(cfg *FrontendConfig) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	var firstErr error
+	apply := func(name string, c InitCleaner, oldCfg *json.RawMessage,
+		newCfg json.RawMessage) {
+
+		if c == nil || sameRawConfig(*oldCfg, newCfg) {
+			return
 		}
+
+		log := f.log.WithField(componentLogKey, name)
+		log.Println("Configuration changed; reconfiguring.")
+
+		if err := reconfigureComponent(log, c, *oldCfg, newCfg); err != nil {
+			if firstErr == nil {
+				firstErr = errors.Wrapf(err, "failed to reconfigure %s", name)
+			}
+			return
+		}
+
+		*oldCfg = newCfg
 	}
 
-	if f.AudioPlayer != nil {
-		if err := f.AudioPlayer.Cleanup(); err != nil {
-			return errors.Wrap(err, "failed to cleanup AudioPlayer")
+	apply("Alerter", f.Alerter, &f.cfg.AlerterConfig, cfg.AlerterConfig)
+	apply("AudioPlayer", f.AudioPlayer, &f.cfg.AudioPlayerConfig,
+		cfg.AudioPlayerConfig)
+	apply("PowerManager", f.PowerManager, &f.cfg.PowerManagerConfig,
+		cfg.PowerManagerConfig)
+	apply("DisplayManager", f.DisplayManager, &f.cfg.DisplayManagerConfig,
+		cfg.DisplayManagerConfig)
+
+	// The AudioPlayer may have been given a new volume.
+	if err := f.shareVolume(); err != nil && firstErr == nil {
+		firstErr = errors.Wrap(err, "failed to share volume with Alerter")
+	}
+
+	// The alert wake policy is read for each alert, so it may always be
+	// applied.
+	f.cfg.AlertWake = cfg.AlertWake
+	f.cfg.PendingPower = cfg.PendingPower
+
+	return firstErr
+}
+
+// ShowAlert displays an alert using the Alerter, waking the display first
+// according to the alert wake policy, if any. With an Alerter that does not
+// report dismissals, the display stays awake until the alert times out, or
+// for the maximum hold of the policy if the alert is perpetual. A repeating sound embedded in the alert
+// is played too, and stopped once the alert is dismissed; other sounds must be
+// played with PlaySound.
+func (f *Frontend) ShowAlert(ctx context.Context, e AlertEvent) error {
+	policy := f.alertWakePolicy()
+	if policy == nil && !e.Repeats() {
+		f.mux.RLock()
+		defer f.mux.RUnlock()
+
+		return f.Alerter.ShowAlert(ctx, e)
+	}
+
+	done := func() {}
+	if policy != nil {
+		done = f.wakeForAlert(ctx, e, policy)
+	}
+
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+
+	stopSound, err := f.startAlertSound(ctx, e)
+	if err != nil {
+		done()
+		return err
+	}
+
+	if ia, ok := f.Alerter.(InteractiveAlerter); ok {
+		err := ia.ShowInteractiveAlert(ctx, e, func(AlertDismissal) {
+			stopSound()
+			done()
+		})
+		if err != nil {
+			stopSound()
+			done()
 		}
+		return err
 	}
 
-	if f.PowerManager != nil {
-		if err := f.PowerManager.Cleanup(); err != nil {
-			return errors.Wrap(err, "failed to cleanup PowerManager")
+	err = f.Alerter.ShowAlert(ctx, e)
+
+	// Without an InteractiveAlerter there is no telling when the alert is
+	// dismissed, so assume that it lasts until it times out. A perpetual
+	// alert never times out, so it is assumed to last for the maximum hold.
+	if err != nil {
+		done()
+		return err
+	}
+
+	hold := e.Timeout
+	if e.Perpetual {
+		hold = time.Duration(alertWakeMaxHoldDefault)
+		if policy != nil && policy.MaxHold > 0 {
+			hold = time.Duration(policy.MaxHold)
 		}
 	}
 
-	if f.DisplayManager != nil {
-		if err := f.DisplayManager.Cleanup(); err != nil {
-			return errors.Wrap(err, "failed to cleanup DisplayManager")
+	time.AfterFunc(hold, done)
+
+	return nil
+}
+
+// ShowInteractiveAlert displays an alert using the Alerter, returning a channel
+// that receives the AlertDismissal once the alert has been dismissed. The
+// display is woken first according to the alert wake policy, if any, and a
+// repeating sound embedded in the alert is played until it is dismissed.
+// Returns an error if the Alerter is not an InteractiveAlerter.
+func (f *Frontend) ShowInteractiveAlert(ctx context.Context,
+	e AlertEvent) (<-chan AlertDismissal, error) {
+
+	done := func() {}
+	if policy := f.alertWakePolicy(); policy != nil {
+		if _, ok := f.Alerter.(InteractiveAlerter); ok {
+			done = f.wakeForAlert(ctx, e, policy)
 		}
 	}
 
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+
+	ia, ok := f.Alerter.(InteractiveAlerter)
+	if !ok {
+		return nil, Unsupported("showing interactive alerts with this alerter")
+	}
+
+	stopSound, err := f.startAlertSound(ctx, e)
+	if err != nil {
+		done()
+		return nil, err
+	}
+
+	dismissed := make(chan AlertDismissal, 1)
+	err = ia.ShowInteractiveAlert(ctx, e, func(d AlertDismissal) {
+		stopSound()
+		dismissed <- d
+		done()
+	})
+
+	if err != nil {
+		stopSound()
+		done()
+		return nil, err
+	}
+
+	return dismissed, nil
+}
+
+// startAlertSound plays the sound embedded in the alert if it repeats,
+// returning a function that stops it once the alert has been dismissed.
+// Sounds that play once are left to PlaySound, and a function that does
+// nothing is returned for them. Requires a read lock on mux.
+func (f *Frontend) startAlertSound(ctx context.Context,
+	e AlertEvent) (func(), error) {
+
+	if e.Empty() || !e.Repeats() {
+		return func() {}, nil
+	}
+
+	// A sound can only be stopped upon dismissal if the dismissal is
+	// reported and the sound can be stopped. Otherwise, a counted sound
+	// plays through, while one repeating until acknowledged cannot be played.
+	_, interactive := f.Alerter.(InteractiveAlerter)
+	pc, tracked := f.AudioPlayer.(PlaybackController)
+
+	if !interactive || !tracked {
+		if e.Repeat == RepeatUntilAcknowledged {
+			return nil, Unsupported("repeating sounds until alerts are " +
+				"acknowledged with this panel")
+		}
+
+		return func() {}, f.AudioPlayer.PlaySound(ctx, e.SoundEvent)
+	}
+
+	p, err := pc.PlaySoundTracked(ctx, e.SoundEvent)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Stop, nil
+}
+
+// PlaySound plays a sound using the AudioPlayer.
+func (f *Frontend) PlaySound(ctx context.Context, e SoundEvent) error {
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+
+	return f.AudioPlayer.PlaySound(ctx, e)
+}
+
+// PlaySoundAndWait plays a sound using the AudioPlayer, returning once it has
+// finished playing or has been stopped, or once ctx is done. Returns an
+// UnsupportedError if the AudioPlayer is not a PlaybackController.
+func (f *Frontend) PlaySoundAndWait(ctx context.Context,
+	e SoundEvent) (PlaybackEnd, error) {
+
+	// The lock is only held to start playback, so that the AudioPlayer may be
+	// reconfigured while the sound plays.
+	f.mux.RLock()
+	pc, ok := f.AudioPlayer.(PlaybackController)
+	if !ok {
+		f.mux.RUnlock()
+		return "", Unsupported("waiting for sounds to finish")
+	}
+
+	p, err := pc.PlaySoundTracked(ctx, e)
+	f.mux.RUnlock()
+
+	if err != nil {
+		return "", err
+	}
+
+	select {
+	case end := <-p.Ended():
+		return end, nil
+	case <-ctx.Done():
+		return "", errors.Wrap(ctx.Err(), "stopped waiting for sound to finish")
+	}
+}
+
+// StopSounds stops every sound that is playing or waiting to be played by the
+// AudioPlayer. Returns an UnsupportedError if the AudioPlayer is not a
+// PlaybackController.
+func (f *Frontend) StopSounds(ctx context.Context) error {
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+
+	pc, ok := f.AudioPlayer.(PlaybackController)
+	if !ok {
+		return Unsupported("stopping sounds")
+	}
+
+	return pc.StopSounds(ctx)
+}
+
+// SetVolume sets the global volume of the panel on the AudioPlayer, and on the
+// Alerter should it also play audio. Returns an UnsupportedError if the
+// AudioPlayer is not a VolumeController.
+func (f *Frontend) SetVolume(ctx context.Context, e VolumeEvent) error {
+	if e.Level > 100 {
+		return errors.Errorf("volume %d is out of range [0,100]", e.Level)
+	}
+
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+
+	vc, ok := f.AudioPlayer.(VolumeController)
+	if !ok {
+		return Unsupported("setting the volume")
+	}
+
+	if err := vc.SetVolume(e.Level); err != nil {
+		return errors.Wrap(err, "failed to set AudioPlayer volume")
+	}
+
+	f.log.WithContext(ctx).WithField("level", e.Level).Println("Volume set.")
+
+	return f.shareVolume()
+}
+
+// Volume reports the global volume of the panel. Returns an UnsupportedError
+// if the AudioPlayer is not a VolumeController.
+func (f *Frontend) Volume(ctx context.Context) (uint8, error) {
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+
+	vc, ok := f.AudioPlayer.(VolumeController)
+	if !ok {
+		return 0, Unsupported("reading the volume")
+	}
+
+	return vc.Volume(), nil
+}
+
+// shareVolume sets the volume of the Alerter, if it is a VolumeController, to
+// that of the AudioPlayer, so that speech and sounds are equally loud.
+// Requires at least a read lock on mux, except during Init.
+func (f *Frontend) shareVolume() error {
+	from, ok := f.AudioPlayer.(VolumeController)
+	if !ok {
+		return nil
+	}
+
+	to, ok := f.Alerter.(VolumeController)
+	if !ok {
+		return nil
+	}
+
+	return to.SetVolume(from.Volume())
+}
+
+// DoPowerAction performs a power action using the PowerManager, or schedules
+// it if the event has a delay. The display actions are aliases for display
+// power events, and are performed using the DisplayManager instead, while the
+// cancel action cancels the pending delayed action. The reloadConfig action
+// is handled by the Frontend itself if a reloader has been set.
+func (f *Frontend) DoPowerAction(ctx context.Context, e PowerEvent) error {
+	if e.Action == PowerActionCancel {
+		_, err := f.CancelPowerAction(ctx)
+		return err
+	}
+
+	if e.Delay > 0 {
+		return f.schedulePowerAction(ctx, e, false)
+	}
+
+	switch e.Action {
+	case PowerActionDisplayOff:
+		return f.SetDisplayPower(ctx, DisplayPowerEvent{State: DisplayPowerOff})
+	case PowerActionDisplayOn:
+		return f.SetDisplayPower(ctx, DisplayPowerEvent{State: DisplayPowerOn})
+	}
+
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+
+	if e.Action == PowerActionReloadConfig && f.reload != nil {
+		f.log.WithContext(ctx).Println("Requesting configuration reload.")
+		f.reload()
+		return nil
+	}
+
+	return f.PowerManager.DoPowerAction(ctx, e)
+}
+
+// DoProtectedPowerAction performs a power action like DoPowerAction, except
+// that a delayed action is protected: until it is performed or cancelled, no
+// other delayed action may replace it, whatever the pending power policy.
+// Controllers use this for actions that must not be lost, such as shutting
+// down before the battery is exhausted.
+func (f *Frontend) DoProtectedPowerAction(ctx context.Context, e PowerEvent) error {
+	if e.Delay > 0 && e.Action != PowerActionCancel {
+		return f.schedulePowerAction(ctx, e, true)
+	}
+
+	return f.DoPowerAction(ctx, e)
+}
+
+// SetReloader sets the function that reloads the configuration, so that the
+// reloadConfig power action is handled by the Frontend rather than by asking
+// the PowerManager to reload the service. The function must not block.
+func (f *Frontend) SetReloader(reload func()) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	f.reload = reload
+}
+
+// PowerCapabilities returns the power actions that the panel supports: those
+// supported by the PowerManager, along with those the Frontend handles itself.
+func (f *Frontend) PowerCapabilities(ctx context.Context) ([]PowerAction, error) {
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+
+	var actions []PowerAction
+
+	if r, ok := f.PowerManager.(PowerCapabilityReporter); ok {
+		var err error
+		if actions, err = r.PowerCapabilities(ctx); err != nil {
+			return nil, err
+		}
+	} else {
+		actions = []PowerAction{
+			PowerActionShutdown,
+			PowerActionReboot,
+			PowerActionSuspend,
+			PowerActionHibernate,
+			PowerActionRestartService,
+			PowerActionReloadConfig,
+		}
+	}
+
+	if f.reload != nil && !hasPowerAction(actions, PowerActionReloadConfig) {
+		actions = append(actions, PowerActionReloadConfig)
+	}
+
+	return append(actions, PowerActionDisplayOff, PowerActionDisplayOn,
+		PowerActionCancel), nil
+}
+
+// hasPowerAction reports whether the action is among the actions.
+func hasPowerAction(actions []PowerAction, action PowerAction) bool {
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetDisplayPower changes the power state of the display using the
+// DisplayManager, as requested by a user or controller.
+func (f *Frontend) SetDisplayPower(ctx context.Context, e DisplayPowerEvent) error {
+	f.powerMux.Lock()
+	f.powerGen++
+	f.powerMux.Unlock()
+
+	return f.setDisplayPower(ctx, e.State)
+}
+
+// setDisplayPower changes the power state of the display using the
+// DisplayManager, keeping track of the state.
+func (f *Frontend) setDisplayPower(ctx context.Context, p DisplayPower) error {
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+
+	if err := f.DisplayManager.SetDisplayPower(ctx, DisplayPowerEvent{State: p}); err != nil {
+		return err
+	}
+
+	f.powerMux.Lock()
+	f.displayPower = p
+	f.powerMux.Unlock()
+
 	return nil
 }
+
+// SetColorTemperature shifts the colors of the display using the
+// DisplayManager. Returns an error if the DisplayManager is not a
+// ColorTemperatureSetter.
+func (f *Frontend) SetColorTemperature(ctx context.Context,
+	e ColorTemperatureEvent) error {
+
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+
+	cts, ok := f.DisplayManager.(ColorTemperatureSetter)
+	if !ok {
+		return Unsupported("setting the color temperature of this display")
+	}
+
+	return cts.SetColorTemperature(ctx, e)
+}
+
+// DisplayPowerState returns the power state most recently set, whether by a
+// user, a controller or the alert wake policy, assuming that the display
+// starts out on. It also returns the count of changes requested by users and
+// controllers.
+func (f *Frontend) DisplayPowerState() (DisplayPower, uint64) {
+	f.powerMux.Lock()
+	defer f.powerMux.Unlock()
+
+	if len(f.displayPower) < 1 {
+		return DisplayPowerOn, f.powerGen
+	}
+
+	return f.displayPower, f.powerGen
+}
+
+// setLevel sets the brightness of the panel immediately using the
+// DisplayManager.
+func (f *Frontend) setLevel(ctx context.Context, level uint8) error {
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+
+	return f.DisplayManager.SetBrightness(ctx, BrightnessEvent{Level: level})
+}
+
+// readLevel reads the brightness of the panel using the DisplayManager.
+// Returns an error if the DisplayManager is not a BrightnessReader.
+func (f *Frontend) readLevel(ctx context.Context) (uint8, error) {
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+
+	br, ok := f.DisplayManager.(BrightnessReader)
+	if !ok {
+		return 0, errors.New("display manager cannot read brightness")
+	}
+
+	return br.Brightness(ctx)
+}
+
+// SetBrightness alters the brightness of the panel using the DisplayManager,
+// as requested by the user. Controllers should use AdjustBrightness instead, so
+// that they can tell when the user has chosen a level.
+func (f *Frontend) SetBrightness(ctx context.Context, e BrightnessEvent) error {
+	f.manualMux.Lock()
+	f.manualBrightnessAt = time.Now()
+	f.manualMux.Unlock()
+
+	return f.AdjustBrightness(ctx, e)
+}
+
+// ManualBrightnessAt returns the time at which the brightness was last set by
+// the user, or the zero time if it never was.
+func (f *Frontend) ManualBrightnessAt() time.Time {
+	f.manualMux.Lock()
+	defer f.manualMux.Unlock()
+
+	return f.manualBrightnessAt
+}
+
+// AdjustBrightness alters the brightness of the panel using the
+// DisplayManager. Any transition in progress is cancelled. When the event has
+// a transition, the brightness fades in the background and AdjustBrightness
+// returns once the fade has started.
+func (f *Frontend) AdjustBrightness(ctx context.Context, e BrightnessEvent) error {
+	// The fader takes the read lock for each step itself; holding it here
+	// while cancelling a fade could deadlock against a pending Reconfigure.
+	if e.Transition <= 0 {
+		return f.fader.Set(ctx, e.Level, f.setLevel)
+	}
+
+	var read func(ctx context.Context) (uint8, error)
+	if _, ok := f.DisplayManager.(BrightnessReader); ok {
+		read = f.readLevel
+	}
+
+	return f.fader.Fade(ctx, f.log.WithField(componentLogKey, "DisplayManager"),
+		e, f.setLevel, read)
+}
+
+// Brightness reports the brightness of the panel. While a transition is in
+// progress, its final level is reported. Otherwise the level is read using the
+// DisplayManager if it is a BrightnessReader, or else the level most recently
+// set is reported.
+func (f *Frontend) Brightness(ctx context.Context) (uint8, error) {
+	if level, ok := f.fader.Fading(); ok {
+		return level, nil
+	}
+
+	level, err := f.readLevel(ctx)
+	if err == nil {
+		return level, nil
+	}
+
+	if _, ok := f.DisplayManager.(BrightnessReader); !ok {
+		if level, ok := f.fader.Target(); ok {
+			return level, nil
+		}
+		return 0, errors.New("brightness has not been set yet")
+	}
+
+	return 0, err
+}
+
+// components lists the components of the Frontend in the order they are
+// initialized, along with their names.
+func (f *Frontend) components() ([]string, []InitCleaner) {
+	return []string{"Alerter", "AudioPlayer", "PowerManager", "DisplayManager"},
+		[]InitCleaner{f.Alerter, f.AudioPlayer, f.PowerManager, f.DisplayManager}
+}
+
+// Drain waits for the work in progress of every component implementing
+// Drainer to finish, or until ctx is done. The errors of all components that
+// could not be drained are returned together as an ErrorList.
+func (f *Frontend) Drain(ctx context.Context) error {
+	var errs ErrorList
+
+	names, components := f.components()
+	for i, c := range components {
+		if d, ok := c.(Drainer); ok {
+			if err := d.Drain(ctx); err != nil {
+				errs = append(errs, errors.Wrapf(err, "failed to drain %s", names[i]))
+			}
+		}
+	}
+
+	return errs.Err()
+}
+
+// HealthCheck runs the health check of every component that is a
+// HealthChecker. The errors of all failing components are returned together as
+// an ErrorList.
+func (f *Frontend) HealthCheck(ctx context.Context) error {
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+
+	var errs ErrorList
+
+	names, components := f.components()
+	for i, c := range components {
+		hc, ok := c.(HealthChecker)
+		if !ok {
+			continue
+		}
+
+		if err := hc.HealthCheck(ctx); err != nil {
+			errs = append(errs, errors.Wrapf(err, "%s is unhealthy", names[i]))
+		}
+	}
+
+	return errs.Err()
+}
+
+// Cleanup tears down all components of the Frontend, in the reverse of the
+// order in which they were initialized. Every component is cleaned up, even if
+// another fails; the errors of all failing components are returned together as
+// an ErrorList.
+func (f *Frontend) Cleanup() error {
+	// Stop fading first, since each step of a fade takes the read lock.
+	f.fader.Stop()
+	f.stopWaker()
+	f.stopPendingPower()
+
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	var errs ErrorList
+
+	names, components := f.components()
+	for i := len(components) - 1; i > -1; i-- {
+		if components[i] == nil {
+			continue
+		}
+
+		if err := components[i].Cleanup(); err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to cleanup %s", names[i]))
+		}
+	}
+
+	return errs.Err()
+}
@@ -3,6 +3,7 @@ package pipanel
 import (
 	"context"
 	"encoding/json"
+	"io"
 
 	"github.com/sirupsen/logrus"
 )
@@ -29,6 +30,17 @@ type Alerter interface {
 	ShowAlert(ctx context.Context, e AlertEvent) error
 }
 
+// An InteractiveAlerter is an Alerter that can report how its alerts are
+// dismissed, offering the actions of the AlertEvent to the user.
+type InteractiveAlerter interface {
+	Alerter
+	// ShowInteractiveAlert displays an alert on the screen, like ShowAlert.
+	// Once the alert has been dismissed, dismissed is called exactly once,
+	// unless an error is returned.
+	ShowInteractiveAlert(ctx context.Context, e AlertEvent,
+		dismissed func(AlertDismissal)) error
+}
+
 // An AudioPlayer plays audio clips stored on the system.
 type AudioPlayer interface {
 	InitCleaner
@@ -36,6 +48,56 @@ type AudioPlayer interface {
 	PlaySound(ctx context.Context, e SoundEvent) error
 }
 
+// A VolumeController is a PiPanel component that plays audio at an adjustable
+// volume. The global volume is set on the AudioPlayer, and shared with the
+// Alerter should it also play audio.
+//
+// Implementing this interface is optional.
+type VolumeController interface {
+	// Volume returns the volume, as a percentage of full volume.
+	Volume() uint8
+	// SetVolume sets the volume, as a percentage of full volume on the range
+	// [0,100].
+	SetVolume(level uint8) error
+}
+
+// A PlaybackController is an AudioPlayer that can tell when a sound has
+// finished playing, and can stop the sounds that are playing.
+//
+// Implementing this interface is optional.
+type PlaybackController interface {
+	// PlaySoundTracked plays a sound like PlaySound, returning a Playback
+	// that tracks it.
+	PlaySoundTracked(ctx context.Context, e SoundEvent) (Playback, error)
+	// StopSounds stops every sound that is playing or waiting to be played.
+	StopSounds(ctx context.Context) error
+}
+
+// A Playback is a sound that a PlaybackController is playing, or is waiting to
+// play.
+type Playback interface {
+	// Ended returns a channel that receives how playback ended, once it has.
+	Ended() <-chan PlaybackEnd
+	// Stop stops the sound, unless it has already ended.
+	Stop()
+}
+
+// A SoundLibrary is an AudioPlayer whose sounds can be listed, added and
+// removed while it is running.
+//
+// Implementing this interface is optional.
+type SoundLibrary interface {
+	// Sounds lists the sounds in the library, sorted by name.
+	Sounds(ctx context.Context) ([]SoundInfo, error)
+	// AddSound adds the sound file read from r under the given name,
+	// replacing any sound of that name. Returns an InvalidSoundError if the
+	// name or the file is rejected, or ErrSoundTooLarge.
+	AddSound(ctx context.Context, name string, r io.Reader) (SoundInfo, error)
+	// DeleteSound removes the sound with the given name. Returns
+	// ErrNoSuchSound if there is none.
+	DeleteSound(ctx context.Context, name string) error
+}
+
 // A PowerManager controls system power functions.
 type PowerManager interface {
 	InitCleaner
@@ -43,9 +105,88 @@ type PowerManager interface {
 	DoPowerAction(ctx context.Context, e PowerEvent) error
 }
 
+// A PowerCapabilityReporter is a PowerManager that can report which power
+// actions it supports.
+//
+// Implementing this interface is optional. PowerManagers that do not implement
+// it are assumed to support every power action.
+type PowerCapabilityReporter interface {
+	// PowerCapabilities returns the power actions that are supported.
+	PowerCapabilities(ctx context.Context) ([]PowerAction, error)
+}
+
 // A DisplayManager controls properties of the display.
 type DisplayManager interface {
 	InitCleaner
 	// SetBrightness alters the brightness of the panel.
 	SetBrightness(ctx context.Context, e BrightnessEvent) error
+	// SetDisplayPower turns the display on or off, or puts it into standby.
+	SetDisplayPower(ctx context.Context, e DisplayPowerEvent) error
+}
+
+// A ColorTemperatureSetter is a DisplayManager that can shift the colors of the
+// display towards a color temperature.
+//
+// Implementing this interface is optional.
+type ColorTemperatureSetter interface {
+	// SetColorTemperature shifts the white point of the display.
+	SetColorTemperature(ctx context.Context, e ColorTemperatureEvent) error
+}
+
+// A BrightnessReader is a DisplayManager that can report the brightness that
+// the panel is actually set to.
+//
+// Implementing this interface is optional.
+type BrightnessReader interface {
+	// Brightness returns the current brightness level of the panel, on the
+	// range [0,255].
+	Brightness(ctx context.Context) (uint8, error)
+}
+
+// A Drainer is a PiPanel component that may have work in progress, such as an
+// alert on-screen or a message being read out loud, which it can wait for
+// before being torn down.
+//
+// Implementing this interface is optional.
+type Drainer interface {
+	// Drain blocks until all work in progress has finished, or until ctx is
+	// done, in which case an error is returned.
+	Drain(ctx context.Context) error
+}
+
+// A HealthChecker is a PiPanel component that can check that it is still
+// working, such as that the event loop it depends on is responsive.
+//
+// Implementing this interface is optional.
+type HealthChecker interface {
+	// HealthCheck returns an error if the component is not working, or if it
+	// cannot tell before ctx is done.
+	HealthCheck(ctx context.Context) error
+}
+
+// A Reconfigurer is a PiPanel component that can apply a new configuration
+// while running, without being torn down and initialized again.
+//
+// Implementing this interface is optional. Components that do not implement it
+// will be reconfigured by calling Cleanup followed by Init.
+type Reconfigurer interface {
+	// Reconfigure validates and applies the new raw JSON configuration for
+	// this component. If the new configuration is invalid, an error must be
+	// returned and the previous configuration must remain in effect.
+	Reconfigure(cfg json.RawMessage) error
+}
+
+// A ConfigDescriber is a PiPanel component that can describe and validate its
+// configuration without being initialized.
+//
+// Implementing this interface is optional. It allows configuration files to
+// be checked, and a schema for them to be generated, without starting any of
+// the components.
+type ConfigDescriber interface {
+	// ConfigTemplate returns a pointer to a zero value of the structure that
+	// the raw JSON configuration for this component is decoded into.
+	ConfigTemplate() interface{}
+	// ValidateConfig decodes and validates the raw JSON configuration for this
+	// component, without applying it.
+	ValidateConfig(cfg json.RawMessage) error
 }
@@ -0,0 +1,104 @@
+package pipanel
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// recordingPowerManager is a PowerManager that records the actions it is
+// asked to perform and reports the given capabilities.
+type recordingPowerManager struct {
+	capabilities []PowerAction
+	performed    []PowerAction
+}
+
+func (*recordingPowerManager) Init(*logrus.Entry, json.RawMessage) error { return nil }
+func (*recordingPowerManager) Cleanup() error                            { return nil }
+
+func (pm *recordingPowerManager) DoPowerAction(ctx context.Context, e PowerEvent) error {
+	pm.performed = append(pm.performed, e.Action)
+	return nil
+}
+
+func (pm *recordingPowerManager) PowerCapabilities(ctx context.Context) ([]PowerAction, error) {
+	return pm.capabilities, nil
+}
+
+func TestReloadConfigHandledByFrontend(t *testing.T) {
+	tests := []struct {
+		name          string
+		reloader      bool
+		capabilities  []PowerAction
+		wantReloads   int
+		wantPerformed []PowerAction
+		wantCaps      []PowerAction
+	}{
+		{
+			name:          "without a reloader",
+			capabilities:  []PowerAction{PowerActionReloadConfig},
+			wantPerformed: []PowerAction{PowerActionReloadConfig},
+			wantCaps: []PowerAction{PowerActionReloadConfig,
+				PowerActionDisplayOff, PowerActionDisplayOn, PowerActionCancel},
+		},
+		{
+			name:         "with a reloader",
+			reloader:     true,
+			capabilities: []PowerAction{PowerActionShutdown},
+			wantReloads:  1,
+			wantCaps: []PowerAction{PowerActionShutdown, PowerActionReloadConfig,
+				PowerActionDisplayOff, PowerActionDisplayOn, PowerActionCancel},
+		},
+		{
+			name:         "with a reloader and a PowerManager that can reload",
+			reloader:     true,
+			capabilities: []PowerAction{PowerActionReloadConfig},
+			wantReloads:  1,
+			wantCaps: []PowerAction{PowerActionReloadConfig,
+				PowerActionDisplayOff, PowerActionDisplayOn, PowerActionCancel},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pm := &recordingPowerManager{capabilities: tt.capabilities}
+			f := &Frontend{PowerManager: pm}
+
+			if err := f.Init(quietLog(), &FrontendConfig{}); err != nil {
+				t.Fatal(err)
+			}
+
+			var reloads int
+			if tt.reloader {
+				f.SetReloader(func() { reloads++ })
+			}
+
+			ctx := context.Background()
+			err := f.DoPowerAction(ctx, PowerEvent{Action: PowerActionReloadConfig})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if reloads != tt.wantReloads {
+				t.Errorf("reloaded %d times, want %d", reloads, tt.wantReloads)
+			}
+
+			if !reflect.DeepEqual(pm.performed, tt.wantPerformed) {
+				t.Errorf("PowerManager performed %v, want %v",
+					pm.performed, tt.wantPerformed)
+			}
+
+			caps, err := f.PowerCapabilities(ctx)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(caps, tt.wantCaps) {
+				t.Errorf("got capabilities %v, want %v", caps, tt.wantCaps)
+			}
+		})
+	}
+}
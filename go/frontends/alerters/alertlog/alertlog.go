@@ -3,6 +3,7 @@ package alertlog
 import (
 	"context"
 	"encoding/json"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
@@ -10,6 +11,7 @@ import (
 )
 
 var _ pipanel.Alerter = (*AlertLog)(nil)
+var _ pipanel.InteractiveAlerter = (*AlertLog)(nil)
 
 // AlertLog implements pipanel.Alerter and handles alert events by writing the
 // details to the console. Useful for testing purposes.
@@ -27,11 +29,36 @@ func (a *AlertLog) ShowAlert(ctx context.Context, e pipanel.AlertEvent) error {
 		"timeout":   e.Timeout,
 		"perpetual": e.Perpetual,
 		"icon":      e.Icon,
+		"actions":   e.Actions,
 	}).Println("Received alert event.")
 
 	return nil
 }
 
+// ShowInteractiveAlert handles alert events by writing the details to the
+// console. Since nobody can choose an action, the alert is reported as timed
+// out once its timeout elapses. Perpetual alerts would never be dismissed, so
+// they are reported as closed immediately.
+func (a *AlertLog) ShowInteractiveAlert(ctx context.Context,
+	e pipanel.AlertEvent, dismissed func(pipanel.AlertDismissal)) error {
+
+	if err := a.ShowAlert(ctx, e); err != nil {
+		return err
+	}
+
+	if e.Perpetual {
+		dismissed(pipanel.AlertDismissal{Reason: pipanel.DismissClosed})
+		return nil
+	}
+
+	time.AfterFunc(e.Timeout, func() {
+		a.log.WithContext(ctx).Println("Alert timed out.")
+		dismissed(pipanel.AlertDismissal{Reason: pipanel.DismissTimeout})
+	})
+
+	return nil
+}
+
 // Init initializes this AlertLog by setting the logger.
 func (a *AlertLog) Init(log *logrus.Entry, _ json.RawMessage) error {
 	a.log = log
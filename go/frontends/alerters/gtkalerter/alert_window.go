@@ -22,19 +22,22 @@ type alertWindow struct {
 	topLayout    *gtk.Box
 	boxLayout    *gtk.Box
 	dismissBtn   *gtk.Button
+	actionBtns   []*gtk.Button
 	progress     *gtk.ProgressBar
 	label        *gtk.Label
 	icon         *gtk.Image
 	timestamp    time.Time
 	inactive     bool
 	afterCleanup func()
+	dismissal    pipanel.AlertDismissal
+	dismissed    func(pipanel.AlertDismissal)
 }
 
 // newAlertWindow creates a new alert window instance. Since Glade is not used
 // for layout, this function is long as it must set up each UI element manually.
 // nolint: gocyclo
 func newAlertWindow(ctx context.Context, cfg *Config, a pipanel.AlertEvent,
-	afterCleanup func()) (*alertWindow, error) {
+	afterCleanup func(), dismissed func(pipanel.AlertDismissal)) (*alertWindow, error) {
 
 	var w alertWindow
 	var err error
@@ -42,6 +45,10 @@ func newAlertWindow(ctx context.Context, cfg *Config, a pipanel.AlertEvent,
 	w.ctx = ctx
 	w.timestamp = time.Now()
 	w.afterCleanup = afterCleanup
+	w.dismissed = dismissed
+
+	// Unless the user or the timeout dismisses the window, it was closed.
+	w.dismissal.Reason = pipanel.DismissClosed
 
 	// Create the window.
 	if w.window, err = gtk.WindowNew(gtk.WINDOW_TOPLEVEL); err != nil {
@@ -99,6 +106,16 @@ func newAlertWindow(ctx context.Context, cfg *Config, a pipanel.AlertEvent,
 	w.dismissBtn.SetImage(ackIcon)
 	w.dismissBtn.SetAlwaysShowImage(true)
 
+	// Create a button for each of the actions.
+	for _, action := range a.Actions {
+		var btn *gtk.Button
+		if btn, err = gtk.ButtonNewWithLabel(action); err != nil {
+			return nil, errors.Wrap(err, "failed to create gtk button for action")
+		}
+
+		w.actionBtns = append(w.actionBtns, btn)
+	}
+
 	// Create the layouts.
 	if w.boxLayout, err = gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 5); err != nil {
 		return nil, errors.Wrap(err, "failed to create gtk box layout for alert content")
@@ -114,8 +131,11 @@ func newAlertWindow(ctx context.Context, cfg *Config, a pipanel.AlertEvent,
 
 	w.topLayout.SetHomogeneous(false)
 
-	// Add dismiss button to headerbar.
+	// Add dismiss and action buttons to headerbar.
 	w.headerBar.PackStart(w.dismissBtn)
+	for i := len(w.actionBtns) - 1; i > -1; i-- {
+		w.headerBar.PackEnd(w.actionBtns[i])
+	}
 
 	// Add widgets to the box layout.
 	w.boxLayout.PackStart(w.icon, false, true, 24)
@@ -160,9 +180,22 @@ func newAlertWindow(ctx context.Context, cfg *Config, a pipanel.AlertEvent,
 	if _, err = w.window.Connect("delete-event", w.Deactivate); err != nil {
 		return nil, errors.Wrap(err, "failed to bind deletion signal to window deactivation")
 	}
-	if _, err = w.dismissBtn.Connect("clicked", w.Destroy); err != nil {
+	if _, err = w.dismissBtn.Connect("clicked", func() {
+		w.dismiss(pipanel.AlertDismissal{Reason: pipanel.DismissAcknowledged})
+	}); err != nil {
 		return nil, errors.Wrap(err, "failed to bind dismiss button to window destruction")
 	}
+	for i, btn := range w.actionBtns {
+		action := a.Actions[i]
+		if _, err = btn.Connect("clicked", func() {
+			w.dismiss(pipanel.AlertDismissal{
+				Reason: pipanel.DismissAction,
+				Action: action,
+			})
+		}); err != nil {
+			return nil, errors.Wrap(err, "failed to bind action button to window destruction")
+		}
+	}
 
 	return &w, nil
 }
@@ -190,6 +223,14 @@ func (w *alertWindow) Destroy() {
 	}
 }
 
+// dismiss records how the window was dismissed, then destroys it.
+func (w *alertWindow) dismiss(d pipanel.AlertDismissal) {
+	if !w.inactive {
+		w.dismissal = d
+		w.Destroy()
+	}
+}
+
 func (w *alertWindow) Cleanup() {
 	// Clear pointers to components so the garbage collector will pick them
 	// up and deallocate the (now unreferenced) objects.
@@ -198,12 +239,18 @@ func (w *alertWindow) Cleanup() {
 	w.topLayout = nil
 	w.boxLayout = nil
 	w.dismissBtn = nil
+	w.actionBtns = nil
 	w.progress = nil
 	w.label = nil
 	w.icon = nil
 
 	// Call the afterCleanup handler.
 	w.afterCleanup()
+
+	// Report the dismissal, if anyone is interested.
+	if w.dismissed != nil {
+		w.dismissed(w.dismissal)
+	}
 }
 
 func (w *alertWindow) setText(text string, fontSize int) {
@@ -220,7 +267,7 @@ func (w *alertWindow) setTimeout(d time.Duration) error {
 		}
 
 		if time.Now().After(expiryTime) {
-			w.Destroy()
+			w.dismiss(pipanel.AlertDismissal{Reason: pipanel.DismissTimeout})
 			return false
 		}
 
@@ -17,6 +17,19 @@ import (
 )
 
 var _ pipanel.Alerter = (*GUI)(nil)
+var _ pipanel.InteractiveAlerter = (*GUI)(nil)
+var _ pipanel.Reconfigurer = (*GUI)(nil)
+var _ pipanel.ConfigDescriber = (*GUI)(nil)
+var _ pipanel.Drainer = (*GUI)(nil)
+var _ pipanel.HealthChecker = (*GUI)(nil)
+
+const (
+	// drainPollInterval is how often Drain checks for open windows.
+	drainPollInterval = 100 * time.Millisecond
+	// cleanupTimeout bounds how long Cleanup waits for the GTK main event
+	// loop to destroy the windows.
+	cleanupTimeout = 5 * time.Second
+)
 
 // Config specifies the options that modify the behavior of GTKAlerter.
 type Config struct {
@@ -103,6 +116,7 @@ type GUI struct {
 	log        *logrus.Entry
 	windowsMux sync.Mutex
 	windows    []*alertWindow
+	cfgMux     sync.RWMutex
 	cfg        Config
 }
 
@@ -129,7 +143,22 @@ func sanitizeAlert(cfg *Config, e *pipanel.AlertEvent) {
 
 // ShowAlert handles alert events by displaying a window to alert the user.
 func (g *GUI) ShowAlert(ctx context.Context, e pipanel.AlertEvent) error {
-	sanitizeAlert(&g.cfg, &e)
+	return g.ShowInteractiveAlert(ctx, e, nil)
+}
+
+// ShowInteractiveAlert handles alert events by displaying a window to alert
+// the user, with a button for each of the event's actions. The dismissed
+// function, if not nil, is called once the window has been destroyed.
+func (g *GUI) ShowInteractiveAlert(ctx context.Context, e pipanel.AlertEvent,
+	dismissed func(pipanel.AlertDismissal)) error {
+
+	// Take a copy of the config, so that a concurrent Reconfigure cannot alter
+	// it while the window is being created.
+	g.cfgMux.RLock()
+	cfg := g.cfg
+	g.cfgMux.RUnlock()
+
+	sanitizeAlert(&cfg, &e)
 
 	_, err := glib.IdleAdd(func() {
 		g.log.WithContext(ctx).
@@ -140,12 +169,17 @@ func (g *GUI) ShowAlert(ctx context.Context, e pipanel.AlertEvent) error {
 
 		g.log.WithContext(ctx).Println("Lock acquired.")
 
-		w, err := newAlertWindow(ctx, &g.cfg, e, g.removeInactiveWindows)
+		w, err := newAlertWindow(ctx, &cfg, e, g.removeInactiveWindows, dismissed)
 
 		if err != nil {
 			err = errors.Wrap(err, "failed to create alert window")
 			logfmt.WithError(g.log, err).WithContext(ctx).
 				Errorln("Problem when creating alert window.")
+
+			// The window never appeared, so it will never be dismissed.
+			if dismissed != nil {
+				dismissed(pipanel.AlertDismissal{Reason: pipanel.DismissClosed})
+			}
 			return
 		}
 
@@ -158,22 +192,46 @@ func (g *GUI) ShowAlert(ctx context.Context, e pipanel.AlertEvent) error {
 	return errors.Wrap(err, "failed to request creating alert window at next idle")
 }
 
+// decodeConfig decodes and validates the raw JSON configuration.
+func decodeConfig(rawCfg json.RawMessage) (Config, error) {
+	var cfg Config
+
+	d := json.NewDecoder(bytes.NewReader(rawCfg))
+	d.DisallowUnknownFields()
+
+	if err := d.Decode(&cfg); err != nil {
+		return cfg, errors.Wrap(err, "malformed JSON for GTKAlerter configuration")
+	}
+
+	if err := validateConfig(&cfg); err != nil {
+		return cfg, errors.Wrap(err, "invalid configuration")
+	}
+
+	return cfg, nil
+}
+
+// ConfigTemplate returns a pointer to a zero Config.
+func (g *GUI) ConfigTemplate() interface{} { return &Config{} }
+
+// ValidateConfig decodes and validates the raw JSON configuration without
+// applying it.
+func (g *GUI) ValidateConfig(rawCfg json.RawMessage) error {
+	_, err := decodeConfig(rawCfg)
+	return err
+}
+
 // Init initializes this GUI instance, setting the logger and starting the GTK
 // main event loop in a separate goroutine.
 func (g *GUI) Init(log *logrus.Entry, rawCfg json.RawMessage) error {
 	g.log = log
 
 	// Decode the config.
-	d := json.NewDecoder(bytes.NewReader(rawCfg))
-	d.DisallowUnknownFields()
-
-	if err := d.Decode(&g.cfg); err != nil {
-		return errors.Wrap(err, "malformed JSON for GTKAlerter configuration")
+	cfg, err := decodeConfig(rawCfg)
+	if err != nil {
+		return err
 	}
 
-	if err := validateConfig(&g.cfg); err != nil {
-		return errors.Wrap(err, "invalid configuration")
-	}
+	g.cfg = cfg
 
 	// Start the GTK main event loop.
 	gtk.Init(nil)
@@ -182,6 +240,24 @@ func (g *GUI) Init(log *logrus.Entry, rawCfg json.RawMessage) error {
 	return nil
 }
 
+// Reconfigure applies a new configuration to this GUI instance without
+// restarting the GTK main event loop. Windows that are already on-screen keep
+// the configuration they were created with.
+func (g *GUI) Reconfigure(rawCfg json.RawMessage) error {
+	cfg, err := decodeConfig(rawCfg)
+	if err != nil {
+		return err
+	}
+
+	g.cfgMux.Lock()
+	g.cfg = cfg
+	g.cfgMux.Unlock()
+
+	g.log.Println("GTKAlerter configuration reloaded.")
+
+	return nil
+}
+
 func (g *GUI) removeInactiveWindows() {
 	g.log.Println("Waiting for exclusive lock on window list...")
 
@@ -206,30 +282,82 @@ func (g *GUI) removeInactiveWindows() {
 	g.log.Printf("Cleared all inactive windows: %d total.\n", count)
 }
 
+// Drain waits until every alert window has been dismissed, or until ctx is
+// done.
+func (g *GUI) Drain(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for {
+		g.windowsMux.Lock()
+		open := len(g.windows)
+		g.windowsMux.Unlock()
+
+		if open < 1 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "%d alert window(s) still open", open)
+		case <-ticker.C:
+		}
+	}
+}
+
+// HealthCheck checks that the GTK main event loop is still responsive, by
+// waiting for it to run an idle callback until ctx is done.
+func (g *GUI) HealthCheck(ctx context.Context) error {
+	ran := make(chan struct{})
+	if _, err := glib.IdleAdd(func() { close(ran) }); err != nil {
+		return errors.Wrap(err, "failed to request callback at next idle")
+	}
+
+	select {
+	case <-ran:
+		return nil
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "GTK main event loop is not responding")
+	}
+}
+
 // Cleanup tears down this GUI instance, destroying all windows and halting the
 // GTK main event loop.
 func (g *GUI) Cleanup() error {
 	g.log.Println("Shutting down GUI...")
 
-	g.log.Println("Waiting for exclusive lock on window list...")
-
-	g.windowsMux.Lock()
-	defer g.windowsMux.Unlock()
+	// Windows must be destroyed by the GTK main event loop. Destroying a
+	// window removes it from the window list, so the lock on the list must
+	// not be held while doing so.
+	destroyed := make(chan struct{})
+	_, err := glib.IdleAdd(func() {
+		g.windowsMux.Lock()
+		windows := append([]*alertWindow(nil), g.windows...)
+		g.windowsMux.Unlock()
 
-	g.log.Println("Lock acquired.")
-	g.log.Println("Destroying all windows... ")
+		g.log.Println("Destroying all windows... ")
 
-	for _, w := range g.windows {
-		w.Destroy()
-	}
+		for _, w := range windows {
+			w.Destroy()
+		}
 
-	g.windows = nil
+		g.log.Println("All windows destroyed.")
+		close(destroyed)
+	})
 
-	g.log.Println("All windows destroyed.")
+	if err != nil {
+		err = errors.Wrap(err, "failed to request destroying windows at next idle")
+	} else {
+		select {
+		case <-destroyed:
+		case <-time.After(cleanupTimeout):
+			err = errors.New("timed out waiting for windows to be destroyed")
+		}
+	}
 
 	g.log.Println("Shutting down GTK main event loop...")
 	gtk.MainQuit()
 	g.log.Println("GTK main event loop halted.")
 
-	return nil
+	return err
 }
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"strings"
+	"sync"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -15,6 +16,11 @@ import (
 )
 
 var _ pipanel.Alerter = (*GTKTTSAlerter)(nil)
+var _ pipanel.InteractiveAlerter = (*GTKTTSAlerter)(nil)
+var _ pipanel.Reconfigurer = (*GTKTTSAlerter)(nil)
+var _ pipanel.ConfigDescriber = (*GTKTTSAlerter)(nil)
+var _ pipanel.Drainer = (*GTKTTSAlerter)(nil)
+var _ pipanel.VolumeController = (*GTKTTSAlerter)(nil)
 
 // Config specifies the options that modify the behavior of GTKAlerter,
 // TTSAlerter, and GTKTTSAlerter.
@@ -35,6 +41,7 @@ type GTKTTSAlerter struct {
 	*gtkalerter.GUI
 	*ttsalerter.TTSAlerter
 	log         *logrus.Entry
+	cfgMux      sync.RWMutex
 	cfg         Config
 	checkPrefix bool
 }
@@ -47,21 +54,55 @@ func New() *GTKTTSAlerter {
 	}
 }
 
+// decodeConfig decodes the raw JSON configuration so it can be separated.
+func decodeConfig(rawCfg json.RawMessage) (Config, error) {
+	var cfg Config
+
+	d := json.NewDecoder(bytes.NewReader(rawCfg))
+	d.DisallowUnknownFields()
+
+	err := d.Decode(&cfg)
+	return cfg, errors.Wrap(err, "malformed JSON for GTKTTSAlerter configuration")
+}
+
+// ConfigTemplate returns a pointer to a zero value of a structure equivalent
+// to Config, but with the GTKAlerter and TTSAlerter configurations typed.
+func (g *GTKTTSAlerter) ConfigTemplate() interface{} {
+	return &struct {
+		TTSAlerterCfg ttsalerter.Config `json:"tts_alerter"`
+		GTKAlerterCfg gtkalerter.Config `json:"gtk_alerter"`
+		NoTTSPrefix   string            `json:"no_tts_prefix"`
+	}{}
+}
+
+// ValidateConfig decodes and validates the raw JSON configuration, including
+// the GTKAlerter and TTSAlerter configurations, without applying it.
+func (g *GTKTTSAlerter) ValidateConfig(rawCfg json.RawMessage) error {
+	cfg, err := decodeConfig(rawCfg)
+	if err != nil {
+		return err
+	}
+
+	if err = g.GUI.ValidateConfig(cfg.GTKAlerterCfg); err != nil {
+		return errors.Wrap(err, "invalid GTKAlerter configuration")
+	}
+
+	err = g.TTSAlerter.ValidateConfig(cfg.TTSAlerterCfg)
+	return errors.Wrap(err, "invalid TTSAlerter configuration")
+}
+
 // Init initializes this GTKTTSAlerter, parsing the configuration and
 // initializing both GTKAlerter and TTSAlerter.
 func (g *GTKTTSAlerter) Init(log *logrus.Entry, rawCfg json.RawMessage) error {
 	g.log = log
 
 	// Load config so it can be separated.
-	d := json.NewDecoder(bytes.NewReader(rawCfg))
-	d.DisallowUnknownFields()
-
-	if err := d.Decode(&(g.cfg)); err != nil {
-		return errors.Wrap(err, "malformed JSON for GTKTTSAlerter configuration")
+	cfg, err := decodeConfig(rawCfg)
+	if err != nil {
+		return err
 	}
 
-	// Enable the checkPrefix feature if a prefix is specified.
-	g.checkPrefix = len(g.cfg.NoTTSPrefix) > 0
+	g.setConfig(cfg)
 
 	// Initialize GTKAlerter and TTSAlerter with their respective configs.
 	if err := g.GUI.Init(log, g.cfg.GTKAlerterCfg); err != nil {
@@ -75,33 +116,105 @@ func (g *GTKTTSAlerter) Init(log *logrus.Entry, rawCfg json.RawMessage) error {
 	return nil
 }
 
+// setConfig stores the configuration, enabling the checkPrefix feature if a
+// prefix is specified.
+func (g *GTKTTSAlerter) setConfig(cfg Config) {
+	g.cfgMux.Lock()
+	defer g.cfgMux.Unlock()
+
+	g.cfg = cfg
+	g.checkPrefix = len(g.cfg.NoTTSPrefix) > 0
+}
+
+// Reconfigure applies a new configuration to this GTKTTSAlerter, passing the
+// respective parts on to GTKAlerter and TTSAlerter. Both parts are validated
+// before either is applied, so that a bad configuration for one does not leave
+// the other reconfigured.
+func (g *GTKTTSAlerter) Reconfigure(rawCfg json.RawMessage) error {
+	cfg, err := decodeConfig(rawCfg)
+	if err != nil {
+		return err
+	}
+
+	if err = g.GUI.ValidateConfig(cfg.GTKAlerterCfg); err != nil {
+		return errors.Wrap(err, "invalid GTKAlerter configuration")
+	}
+
+	if err = g.TTSAlerter.ValidateConfig(cfg.TTSAlerterCfg); err != nil {
+		return errors.Wrap(err, "invalid TTSAlerter configuration")
+	}
+
+	if err = g.GUI.Reconfigure(cfg.GTKAlerterCfg); err != nil {
+		return errors.Wrap(err, "failed to reconfigure GTKAlerter")
+	}
+
+	if err = g.TTSAlerter.Reconfigure(cfg.TTSAlerterCfg); err != nil {
+		return errors.Wrap(err, "failed to reconfigure TTSAlerter")
+	}
+
+	g.setConfig(cfg)
+
+	return nil
+}
+
+// Drain waits until all alert windows have been dismissed and all messages
+// have been read out loud, or until ctx is done.
+func (g *GTKTTSAlerter) Drain(ctx context.Context) error {
+	var errs pipanel.ErrorList
+
+	if err := g.GUI.Drain(ctx); err != nil {
+		errs = append(errs, errors.Wrap(err, "failed to drain GTKAlerter"))
+	}
+
+	if err := g.TTSAlerter.Drain(ctx); err != nil {
+		errs = append(errs, errors.Wrap(err, "failed to drain TTSAlerter"))
+	}
+
+	return errs.Err()
+}
+
 // Cleanup tears down this GTKTTSAlerter instance, triggering cleanup of
-// the GTKAlerter and TTSAlerter.
+// the GTKAlerter and TTSAlerter. Both are cleaned up even if one fails.
 func (g *GTKTTSAlerter) Cleanup() error {
+	var errs pipanel.ErrorList
+
 	if err := g.GUI.Cleanup(); err != nil {
-		return errors.Wrap(err, "failed to clean up GTKAlerter")
+		errs = append(errs, errors.Wrap(err, "failed to clean up GTKAlerter"))
 	}
 
 	if err := g.TTSAlerter.Cleanup(); err != nil {
-		return errors.Wrap(err, "failed to clean up TTSAlerter")
+		errs = append(errs, errors.Wrap(err, "failed to clean up TTSAlerter"))
 	}
 
-	return nil
+	return errs.Err()
 }
 
 // ShowAlert displays the alert on the screen using gtkalerter.GUI and
 // (provided No TTS prefix is not present) reads the message out loud.
 func (g *GTKTTSAlerter) ShowAlert(ctx context.Context, e pipanel.AlertEvent) error {
+	return g.ShowInteractiveAlert(ctx, e, nil)
+}
+
+// ShowInteractiveAlert displays the alert on the screen using gtkalerter.GUI,
+// reporting its dismissal, and (provided No TTS prefix is not present) reads
+// the message out loud.
+func (g *GTKTTSAlerter) ShowInteractiveAlert(ctx context.Context,
+	e pipanel.AlertEvent, dismissed func(pipanel.AlertDismissal)) error {
+
 	// If a message has the no TTS prefix, it should not be read out loud.
+	g.cfgMux.RLock()
+	checkPrefix, prefix := g.checkPrefix, g.cfg.NoTTSPrefix
+	g.cfgMux.RUnlock()
+
 	shouldReadMsg := true
-	if g.checkPrefix && strings.HasPrefix(e.Message, g.cfg.NoTTSPrefix) {
+	if checkPrefix && strings.HasPrefix(e.Message, prefix) {
 		shouldReadMsg = false
-		e.Message = e.Message[len(g.cfg.NoTTSPrefix):]
+		e.Message = e.Message[len(prefix):]
 		g.log.WithContext(ctx).
 			Println("Detected No TTS prefix; skipping alert read-out.")
 	}
 
-	err := g.GUI.ShowAlert(ctx, e)
+	err := g.GUI.ShowInteractiveAlert(ctx, e, dismissed)
 
 	if err != nil {
 		return errors.Wrap(err, "failed to show alert via GTKAlerter")
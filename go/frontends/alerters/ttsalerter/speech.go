@@ -0,0 +1,111 @@
+package ttsalerter
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ttsURL is the Google Translate text-to-speech endpoint.
+const ttsURL = "http://translate.google.com/translate_tts"
+
+// clipPath returns the path at which the speech clip for the given text is
+// cached. The name is derived from a hash, so that any text is a safe name.
+func clipPath(cfg Config, text string) string {
+	sum := sha1.Sum([]byte(cfg.Language + "\x00" + text))
+	return filepath.Join(cfg.TempDir, hex.EncodeToString(sum[:])+".mp3")
+}
+
+// fetchClip returns the path of the speech clip for the given text,
+// downloading it into the cache first if it is not already there.
+func fetchClip(ctx context.Context, cfg Config, text string) (string, error) {
+	path := clipPath(cfg, text)
+
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	if err := os.MkdirAll(cfg.TempDir, 0700); err != nil {
+		return "", errors.Wrap(err, "could not create TTS cache directory")
+	}
+
+	q := url.Values{
+		"ie":      {"UTF-8"},
+		"total":   {"1"},
+		"idx":     {"0"},
+		"textlen": {strconv.Itoa(len(text))},
+		"client":  {"tw-ob"},
+		"q":       {text},
+		"tl":      {cfg.Language},
+	}
+
+	req, err := http.NewRequest(http.MethodGet, ttsURL+"?"+q.Encode(), nil)
+	if err != nil {
+		return "", errors.Wrap(err, "could not create TTS request")
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", errors.Wrap(err, "TTS request failed")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("TTS request failed with HTTP %d", resp.StatusCode)
+	}
+
+	// Download to a temporary file first, so that an interrupted download
+	// does not leave a truncated clip in the cache.
+	tmp, err := ioutil.TempFile(cfg.TempDir, "download-")
+	if err != nil {
+		return "", errors.Wrap(err, "could not create TTS clip file")
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, resp.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "could not download TTS clip")
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	return path, errors.Wrap(err, "could not store TTS clip")
+}
+
+// playClip plays the speech clip at the given path using mplayer, blocking
+// until playback finishes. Playback is cut off if ctx is done first. The
+// volume is a percentage of full volume, applied by mplayer itself so that the
+// system mixer is left alone.
+func playClip(ctx context.Context, path string, volume uint8) error {
+	var stderr bytes.Buffer
+
+	mplayer := exec.CommandContext(ctx, "mplayer", "-cache", "8092",
+		"-softvol", "-softvol-max", "100", "-volume", strconv.Itoa(int(volume)),
+		"-", path)
+	mplayer.Stderr = &stderr
+
+	if err := mplayer.Run(); err != nil {
+		if ctx.Err() != nil {
+			return errors.Wrap(ctx.Err(), "playback cut off")
+		}
+
+		return errors.Wrapf(err, "mplayer failed: %s",
+			strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
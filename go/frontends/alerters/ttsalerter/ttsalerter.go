@@ -4,8 +4,8 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"sync"
 
-	htgotts "github.com/hegedustibor/htgo-tts"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
@@ -14,6 +14,10 @@ import (
 )
 
 var _ pipanel.Alerter = (*TTSAlerter)(nil)
+var _ pipanel.Reconfigurer = (*TTSAlerter)(nil)
+var _ pipanel.ConfigDescriber = (*TTSAlerter)(nil)
+var _ pipanel.Drainer = (*TTSAlerter)(nil)
+var _ pipanel.VolumeController = (*TTSAlerter)(nil)
 
 const (
 	tempDirDefault  string = "/tmp/pipanel-tts/"
@@ -45,15 +49,24 @@ func (cfg *Config) fillDefaults() {
 }
 
 // TTSAlerter is an implementation of pipanel.Alerter that reads alerts
-// out loud via text-to-speech.
+// out loud via text-to-speech, using Google Translate for speech synthesis and
+// mplayer for playback.
 type TTSAlerter struct {
 	log    *logrus.Entry
-	speech *htgotts.Speech
+	cfgMux sync.RWMutex
 	cfg    Config
+	// volume is the playback volume, as a percentage of full volume. It is
+	// guarded by cfgMux.
+	volume uint8
+	// active tracks messages that are being read out loud.
+	active sync.WaitGroup
+	// stopCtx is cancelled by Cleanup to cut off playback in progress.
+	stopCtx context.Context
+	stop    context.CancelFunc
 }
 
 // New creates a TTSAlerter instance.
-func New() *TTSAlerter { return &TTSAlerter{} }
+func New() *TTSAlerter { return &TTSAlerter{volume: 100} }
 
 // ShowAlert will handle pipanel alert events by reading the alert message
 // out loud to the user.
@@ -61,11 +74,18 @@ func (t *TTSAlerter) ShowAlert(ctx context.Context, e pipanel.AlertEvent) error
 	t.log.WithContext(ctx).
 		Println("Starting to read alert message out loud to user.")
 
-	// Since the Speak method blocks while reading to the user, it will be run
-	// asynchronously. Consequentially, all ShowAlert invocations upon a
-	// TTSAlerter will always return with success. Errors are logged only.
+	// Since reading to the user blocks, it will be run asynchronously.
+	// Consequentially, all ShowAlert invocations upon a TTSAlerter will always
+	// return with success. Errors are logged only.
+	t.cfgMux.RLock()
+	cfg, volume := t.cfg, t.volume
+	t.cfgMux.RUnlock()
+
+	t.active.Add(1)
 	go func() {
-		if err := t.speech.Speak(e.Message); err != nil {
+		defer t.active.Done()
+
+		if err := t.speak(cfg, volume, e.Message); err != nil {
 			err = errors.Wrap(err, "failed to read alert message out loud")
 			logfmt.WithError(t.log, err).WithContext(ctx).
 				Errorln("Problem when reading alert message out loud.")
@@ -78,30 +98,113 @@ func (t *TTSAlerter) ShowAlert(ctx context.Context, e pipanel.AlertEvent) error
 	return nil
 }
 
-// Init initializes this TTSAlerter, loading the configuration from the
-// provided JSON.
-func (t *TTSAlerter) Init(log *logrus.Entry, rawCfg json.RawMessage) error {
-	t.log = log
+// speak reads the text out loud at the given volume, unless the TTSAlerter is
+// cleaned up first.
+func (t *TTSAlerter) speak(cfg Config, volume uint8, text string) error {
+	path, err := fetchClip(t.stopCtx, cfg, text)
+	if err != nil {
+		return err
+	}
+
+	return playClip(t.stopCtx, path, volume)
+}
+
+// Volume returns the playback volume, as a percentage of full volume.
+func (t *TTSAlerter) Volume() uint8 {
+	t.cfgMux.RLock()
+	defer t.cfgMux.RUnlock()
+
+	return t.volume
+}
+
+// SetVolume sets the playback volume, as a percentage of full volume. Messages
+// that are already being read out loud are not affected.
+func (t *TTSAlerter) SetVolume(level uint8) error {
+	if level > 100 {
+		return errors.Errorf("volume %d is out of range [0,100]", level)
+	}
+
+	t.cfgMux.Lock()
+	t.volume = level
+	t.cfgMux.Unlock()
+
+	return nil
+}
+
+// decodeConfig decodes the raw JSON configuration, filling in defaults.
+func decodeConfig(rawCfg json.RawMessage) (Config, error) {
+	var cfg Config
 
-	// Decode config structure.
 	d := json.NewDecoder(bytes.NewReader(rawCfg))
 	d.DisallowUnknownFields()
 
-	if err := d.Decode(&t.cfg); err != nil {
-		return errors.Wrap(err, "malformed JSON for TTSAlerter configuration")
+	if err := d.Decode(&cfg); err != nil {
+		return cfg, errors.Wrap(err, "malformed JSON for TTSAlerter configuration")
 	}
 
 	// Replace zero values with defaults.
-	t.cfg.fillDefaults()
+	cfg.fillDefaults()
+
+	return cfg, nil
+}
 
-	// Create a HTGo-TTS instance to facilitate communication with Google TTS.
-	t.speech = &htgotts.Speech{
-		Folder:   t.cfg.TempDir,
-		Language: t.cfg.Language,
+// ConfigTemplate returns a pointer to a zero Config.
+func (t *TTSAlerter) ConfigTemplate() interface{} { return &Config{} }
+
+// ValidateConfig decodes the raw JSON configuration without applying it.
+func (t *TTSAlerter) ValidateConfig(rawCfg json.RawMessage) error {
+	_, err := decodeConfig(rawCfg)
+	return err
+}
+
+// Init initializes this TTSAlerter, loading the configuration from the
+// provided JSON.
+func (t *TTSAlerter) Init(log *logrus.Entry, rawCfg json.RawMessage) error {
+	t.log = log
+	t.stopCtx, t.stop = context.WithCancel(context.Background())
+
+	return t.Reconfigure(rawCfg)
+}
+
+// Reconfigure loads a new configuration from the provided JSON. Messages that
+// are already being read out loud are not affected.
+func (t *TTSAlerter) Reconfigure(rawCfg json.RawMessage) error {
+	cfg, err := decodeConfig(rawCfg)
+	if err != nil {
+		return err
 	}
 
+	t.cfgMux.Lock()
+	t.cfg = cfg
+	t.cfgMux.Unlock()
+
 	return nil
 }
 
-// Cleanup tears down this TTSAlerter.
-func (t *TTSAlerter) Cleanup() error { return nil }
+// Drain waits until all messages have been read out loud, or until ctx is
+// done.
+func (t *TTSAlerter) Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		t.active.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "messages are still being read out loud")
+	}
+}
+
+// Cleanup tears down this TTSAlerter, cutting off any message that is still
+// being read out loud.
+func (t *TTSAlerter) Cleanup() error {
+	if t.stop != nil {
+		t.stop()
+	}
+
+	t.active.Wait()
+	return nil
+}
@@ -3,6 +3,7 @@ package audiolog
 import (
 	"context"
 	"encoding/json"
+	"sync/atomic"
 
 	"github.com/sirupsen/logrus"
 
@@ -10,21 +11,74 @@ import (
 )
 
 var _ pipanel.AudioPlayer = (*AudioLog)(nil)
+var _ pipanel.VolumeController = (*AudioLog)(nil)
+var _ pipanel.PlaybackController = (*AudioLog)(nil)
 
 // AudioLog implements pipanel.AudioPlayer and handles sound events by writing
 // the details to the console. Useful for testing purposes.
 type AudioLog struct {
-	log *logrus.Entry
+	log    *logrus.Entry
+	volume uint32
 }
 
 // New creates a fresh AudioLog instance.
-func New() *AudioLog { return &AudioLog{} }
+func New() *AudioLog { return &AudioLog{volume: 100} }
 
 // PlaySound handles sound events by writing the details to the console.
 func (a *AudioLog) PlaySound(ctx context.Context, e pipanel.SoundEvent) error {
-	a.log.WithContext(ctx).WithFields(logrus.Fields{
-		"sound": e.Sound,
-	}).Println("Received sound event.")
+	fields := logrus.Fields{"sound": e.Sound}
+	if len(e.Tone) > 0 {
+		fields["tone"] = e.Tone
+	}
+	if e.Volume != nil {
+		fields["volume"] = *e.Volume
+	}
+	if len(e.Mode) > 0 {
+		fields["mode"] = e.Mode
+	}
+
+	a.log.WithContext(ctx).WithFields(fields).Println("Received sound event.")
+
+	return nil
+}
+
+// PlaySoundTracked handles sound events like PlaySound. Since nothing is
+// played, playback has always finished by the time it returns.
+func (a *AudioLog) PlaySoundTracked(ctx context.Context,
+	e pipanel.SoundEvent) (pipanel.Playback, error) {
+
+	if err := a.PlaySound(ctx, e); err != nil {
+		return nil, err
+	}
+
+	ended := make(finished, 1)
+	ended <- pipanel.PlaybackFinished
+
+	return ended, nil
+}
+
+// finished is a pipanel.Playback that has already finished.
+type finished chan pipanel.PlaybackEnd
+
+// Ended returns the channel, which holds pipanel.PlaybackFinished.
+func (f finished) Ended() <-chan pipanel.PlaybackEnd { return f }
+
+// Stop does nothing, since playback has already finished.
+func (f finished) Stop() {}
+
+// StopSounds handles requests to stop sounds by writing them to the console.
+func (a *AudioLog) StopSounds(ctx context.Context) error {
+	a.log.WithContext(ctx).Println("Received request to stop sounds.")
+	return nil
+}
+
+// Volume returns the volume most recently set.
+func (a *AudioLog) Volume() uint8 { return uint8(atomic.LoadUint32(&a.volume)) }
+
+// SetVolume handles volume changes by writing the level to the console.
+func (a *AudioLog) SetVolume(level uint8) error {
+	atomic.StoreUint32(&a.volume, uint32(level))
+	a.log.WithField("level", level).Println("Received volume change.")
 
 	return nil
 }
@@ -5,25 +5,55 @@ import (
 	"context"
 	"encoding/json"
 	"os"
-	"strings"
+	"sync"
 	"time"
 
 	pipanel "github.com/BenJetson/pipanel/go"
+	"github.com/BenJetson/pipanel/go/frontends/audio_players/tonegen"
 
 	"github.com/faiface/beep"
+	"github.com/faiface/beep/effects"
 	"github.com/faiface/beep/speaker"
-	"github.com/faiface/beep/wav"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
 var _ pipanel.AudioPlayer = (*Beeper)(nil)
+var _ pipanel.Reconfigurer = (*Beeper)(nil)
+var _ pipanel.ConfigDescriber = (*Beeper)(nil)
+var _ pipanel.VolumeController = (*Beeper)(nil)
+var _ pipanel.SoundLibrary = (*Beeper)(nil)
+var _ pipanel.PlaybackController = (*Beeper)(nil)
 
 // Config is the structure for Beeper configuration.
 type Config struct {
 	// LibraryPath is the absolute path to the directory storing the audio files
 	// to be played by Beeper.
 	LibraryPath string `json:"library_path"`
+	// Extensions is the order in which file extensions are tried when looking
+	// up a sound in the library. Supported extensions are wav, mp3, ogg (for
+	// OGG Vorbis) and flac. Defaults to all of them, in that order.
+	Extensions []string `json:"extensions,omitempty"`
+	// Volume is the global volume, as a percentage of full volume on the
+	// range [0,100]. It is applied on startup and whenever it is changed in
+	// the configuration; in between, it may be changed by volume events.
+	//
+	// Defaults to 100.
+	Volume *uint8 `json:"volume,omitempty"`
+	// UploadLimit is the largest sound file, in bytes, that may be added to
+	// the library while running.
+	//
+	// Defaults to 10 MiB.
+	UploadLimit int64 `json:"upload_limit,omitempty"`
+	// Mode decides how sounds are played alongside sounds that are already
+	// playing, unless a sound event gives its own mode.
+	//
+	// Defaults to "mix".
+	Mode pipanel.PlaybackMode `json:"mode,omitempty"`
+	// Tones are synthesized tones that may be played by name, in addition
+	// to the built-in beep, chime and siren, which they replace if they share
+	// a name.
+	Tones map[string]tonegen.Spec `json:"tones,omitempty"`
 }
 
 // SampleRate is the sample rate of the beep/speaker. Defaults to 16 kHz.
@@ -31,99 +61,280 @@ type Config struct {
 // resample audio clips appropriately.
 var SampleRate beep.SampleRate = 16000
 
-// Beeper implements pipanel.AudioPlayer and plays WAV audio clips from the
-// library directory specified. Sound events are expected to omit the .wav file
-// extension from the Sound field.
+// Beeper implements pipanel.AudioPlayer and plays WAV, MP3, OGG Vorbis and FLAC
+// audio clips from the library directory specified. Sound events are expected
+// to omit the file extension from the Sound field; the first file found with
+// an extension in the configured search order is played. Sound events that
+// give a Tone play a synthesized tone instead.
 type Beeper struct {
-	log *logrus.Entry
-	cfg Config
+	log    *logrus.Entry
+	cfgMux sync.RWMutex
+	cfg    Config
+	// player plays every sound, through output, which applies the global
+	// volume. Both are guarded by the speaker lock.
+	player player
+	output effects.Volume
+	// volume is the global volume. It is guarded by cfgMux.
+	volume uint8
+	// libMux serializes changes to the library.
+	libMux sync.Mutex
 }
 
 // New creates a Beeper instance.
 func New() *Beeper { return &Beeper{} }
 
-func validateAudioFilename(fileName string) error {
-	// Checks to make sure that only one period exists in the file name.
-	// Exists for secutiry purposes to ensure that files outside of the library
-	// path cannot be accessed (for example "../not_in_library.wav" is bad).
-	if strings.Count(fileName, ".") > 0 {
-		return errors.Errorf("illegal filename '%s' contains periods", fileName)
-	}
-
-	return nil
-}
-
 // PlaySound handles pipanel sound events.
 func (b *Beeper) PlaySound(ctx context.Context, e pipanel.SoundEvent) error {
-	if err := validateAudioFilename(e.Sound); err != nil {
-		return errors.Wrap(err, "bad filename")
-	}
+	_, err := b.PlaySoundTracked(ctx, e)
+	return err
+}
 
-	pathToFile := b.cfg.LibraryPath + e.Sound + ".wav"
+// PlaySoundTracked handles pipanel sound events, returning a Playback that
+// reports how playback ended once the sound has finished, been stopped or
+// been replaced.
+func (b *Beeper) PlaySoundTracked(ctx context.Context,
+	e pipanel.SoundEvent) (pipanel.Playback, error) {
 
-	f, err := os.Open(pathToFile)
+	b.cfgMux.RLock()
+	cfg := b.cfg
+	b.cfgMux.RUnlock()
 
-	if err != nil {
-		return errors.Wrapf(err, "file not found: %s", pathToFile)
-	}
+	var streamer beep.StreamSeekCloser
+	var format beep.Format
+	var source string
+	var err error
 
-	streamer, format, err := wav.Decode(f)
+	if len(e.Tone) > 0 {
+		streamer, err = openTone(cfg, e.Tone)
+		format = beep.Format{SampleRate: SampleRate}
+		source = "tone " + e.Tone
+	} else {
+		if err = validateAudioFilename(e.Sound); err != nil {
+			return nil, errors.Wrap(err, "bad filename")
+		}
+
+		var ext string
+		source, ext, err = findSound(cfg.LibraryPath, e.Sound, cfg.Extensions)
+		if err != nil {
+			return nil, err
+		}
+
+		streamer, format, err = openSound(source, ext)
+	}
 
 	if err != nil {
-		return errors.Wrap(err, "could not decode WAV audio")
+		return nil, err
 	}
 
 	var streamToPlay beep.Streamer = streamer
 
+	if e.Repeats() {
+		streamToPlay = newRepeater(streamer, e.Repeat,
+			format.SampleRate.N(e.Interval))
+	}
+
 	if format.SampleRate != SampleRate {
-		streamToPlay = beep.Resample(4, format.SampleRate, SampleRate, streamer)
+		streamToPlay = beep.Resample(4, format.SampleRate, SampleRate, streamToPlay)
+	}
+
+	if e.Volume != nil {
+		streamToPlay = withVolume(streamToPlay, *e.Volume)
 	}
 
-	speaker.Play(streamToPlay)
-	b.log.WithContext(ctx).Printf("Playing sound: %s", pathToFile)
+	mode := e.Mode
+	if len(mode) < 1 {
+		mode = cfg.Mode
+	}
+
+	pb := newPlayback(streamToPlay, streamer)
+
+	speaker.Lock()
+	b.player.add(pb, mode)
+	speaker.Unlock()
+
+	b.log.WithContext(ctx).WithField("mode", mode).
+		Printf("Playing sound: %s", source)
+
+	return pb, nil
+}
+
+// StopSounds stops every sound that is playing or queued.
+func (b *Beeper) StopSounds(ctx context.Context) error {
+	speaker.Lock()
+	b.player.stop()
+	speaker.Unlock()
+
+	b.log.WithContext(ctx).Println("Stopped all sounds.")
 
 	return nil
 }
 
-// Init initializes this Beeper instance. Configuration will be loaded from
-// the provided JSON blob.
-func (b *Beeper) Init(log *logrus.Entry, rawCfg json.RawMessage) error {
-	b.log = log
+// decodeConfig decodes and validates the raw JSON configuration.
+func decodeConfig(rawCfg json.RawMessage) (Config, error) {
+	var cfg Config
 
 	// Decode config structure.
 	d := json.NewDecoder(bytes.NewReader(rawCfg))
 	d.DisallowUnknownFields()
 
-	if err := d.Decode(&(b.cfg)); err != nil {
-		return errors.Wrap(err, "malformed JSON for Beeper configuration")
+	if err := d.Decode(&cfg); err != nil {
+		return cfg, errors.Wrap(err, "malformed JSON for Beeper configuration")
+	}
+
+	if len(cfg.Mode) < 1 {
+		cfg.Mode = pipanel.PlaybackMix
+	}
+
+	if cfg.UploadLimit == 0 {
+		cfg.UploadLimit = uploadLimitDefault
+	} else if cfg.UploadLimit < 0 {
+		return cfg, errors.New("upload limit cannot be negative")
+	}
+
+	if len(cfg.Extensions) < 1 {
+		cfg.Extensions = extensionsDefault
+	} else if err := validateExtensions(cfg.Extensions); err != nil {
+		return cfg, err
+	}
+
+	if cfg.Volume != nil && *cfg.Volume > 100 {
+		return cfg, errors.Errorf("volume %d is out of range [0,100]",
+			*cfg.Volume)
+	}
+
+	if err := tonegen.ValidateTones(cfg.Tones); err != nil {
+		return cfg, err
 	}
 
 	// Make sure library path is set.
-	if len(b.cfg.LibraryPath) < 1 {
-		return errors.Errorf("must define an audio library path in config")
+	if len(cfg.LibraryPath) < 1 {
+		return cfg, errors.Errorf("must define an audio library path in config")
 	}
 
 	// Enforce trailing slash, which makes concatenation with filenames easier.
-	if b.cfg.LibraryPath[len(b.cfg.LibraryPath)-1] != '/' {
-		b.cfg.LibraryPath += "/"
+	if cfg.LibraryPath[len(cfg.LibraryPath)-1] != '/' {
+		cfg.LibraryPath += "/"
 	}
 
-	// Check to make sure that the directory actually exists; panic otherwise.
-	dir, err := os.Open(b.cfg.LibraryPath)
+	// Check to make sure that the directory actually exists.
+	dir, err := os.Open(cfg.LibraryPath)
 
 	if os.IsNotExist(err) {
-		return errors.Errorf("no such directory: %s", b.cfg.LibraryPath)
+		return cfg, errors.Errorf("no such directory: %s", cfg.LibraryPath)
 	} else if err != nil {
-		return errors.Wrap(err, "could not open audio library directory")
+		return cfg, errors.Wrap(err, "could not open audio library directory")
 	}
 
 	dir.Close()
 
+	return cfg, nil
+}
+
+// ConfigTemplate returns a pointer to a zero Config.
+func (b *Beeper) ConfigTemplate() interface{} { return &Config{} }
+
+// ValidateConfig decodes and validates the raw JSON configuration without
+// applying it or initializing the speaker.
+func (b *Beeper) ValidateConfig(rawCfg json.RawMessage) error {
+	_, err := decodeConfig(rawCfg)
+	return err
+}
+
+// Init initializes this Beeper instance. Configuration will be loaded from
+// the provided JSON blob.
+func (b *Beeper) Init(log *logrus.Entry, rawCfg json.RawMessage) error {
+	b.log = log
+
+	cfg, err := decodeConfig(rawCfg)
+	if err != nil {
+		return err
+	}
+
+	b.cfg = cfg
+
 	err = speaker.Init(SampleRate, SampleRate.N(time.Second/10))
-	return errors.Wrap(err, "could not initialize speaker")
+	if err != nil {
+		return errors.Wrap(err, "could not initialize speaker")
+	}
+
+	// The player never drains, so it plays for as long as the speaker does.
+	b.output.Streamer = &b.player
+	b.setVolume(configVolume(cfg))
+	speaker.Play(&b.output)
+
+	return nil
+}
+
+// configVolume returns the volume given by the configuration.
+func configVolume(cfg Config) uint8 {
+	if cfg.Volume == nil {
+		return 100
+	}
+
+	return *cfg.Volume
 }
 
-// Cleanup tears down this Beeper.
+// setVolume sets the global volume, which takes effect immediately, even for
+// sounds that are already playing.
+func (b *Beeper) setVolume(level uint8) {
+	b.cfgMux.Lock()
+	b.volume = level
+	b.cfgMux.Unlock()
+
+	speaker.Lock()
+	applyVolume(&b.output, level)
+	speaker.Unlock()
+}
+
+// Volume returns the global volume, as a percentage of full volume.
+func (b *Beeper) Volume() uint8 {
+	b.cfgMux.RLock()
+	defer b.cfgMux.RUnlock()
+
+	return b.volume
+}
+
+// SetVolume sets the global volume, as a percentage of full volume.
+func (b *Beeper) SetVolume(level uint8) error {
+	if level > 100 {
+		return errors.Errorf("volume %d is out of range [0,100]", level)
+	}
+
+	b.setVolume(level)
+	return nil
+}
+
+// Reconfigure loads a new configuration from the provided JSON blob. The
+// speaker is not reinitialized, so sounds already playing are unaffected.
+func (b *Beeper) Reconfigure(rawCfg json.RawMessage) error {
+	cfg, err := decodeConfig(rawCfg)
+	if err != nil {
+		return err
+	}
+
+	b.cfgMux.Lock()
+	oldCfg := b.cfg
+	b.cfg = cfg
+	b.cfgMux.Unlock()
+
+	b.log.Printf("Audio library path is now %s.", cfg.LibraryPath)
+
+	// A volume set by a volume event is kept unless the configured volume
+	// has changed.
+	if volume := configVolume(cfg); volume != configVolume(oldCfg) {
+		b.setVolume(volume)
+		b.log.Printf("Volume is now %d.", volume)
+	}
+
+	return nil
+}
+
+// Cleanup tears down this Beeper, stopping any sound that is playing.
 func (b *Beeper) Cleanup() error {
+	speaker.Lock()
+	b.player.stop()
+	speaker.Unlock()
+
+	speaker.Clear()
 	return nil
 }
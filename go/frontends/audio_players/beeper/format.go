@@ -0,0 +1,158 @@
+package beeper
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/flac"
+	"github.com/faiface/beep/mp3"
+	"github.com/faiface/beep/vorbis"
+	"github.com/faiface/beep/wav"
+	"github.com/pkg/errors"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+)
+
+// decoder decodes an audio stream of a particular format. The stream takes
+// ownership of the file and closes it when the stream is closed.
+type decoder func(f *os.File) (beep.StreamSeekCloser, beep.Format, error)
+
+// decoders maps each supported file extension to its decoder.
+var decoders = map[string]decoder{
+	"wav": func(f *os.File) (beep.StreamSeekCloser, beep.Format, error) {
+		return wav.Decode(f)
+	},
+	"mp3": func(f *os.File) (beep.StreamSeekCloser, beep.Format, error) {
+		return mp3.Decode(f)
+	},
+	"ogg": func(f *os.File) (beep.StreamSeekCloser, beep.Format, error) {
+		return vorbis.Decode(f)
+	},
+	"flac": func(f *os.File) (beep.StreamSeekCloser, beep.Format, error) {
+		return flac.Decode(f)
+	},
+}
+
+// formatNames are the names of the audio formats, for error messages.
+var formatNames = map[string]string{
+	"wav":  "WAV",
+	"mp3":  "MP3",
+	"ogg":  "OGG Vorbis",
+	"flac": "FLAC",
+}
+
+// signatures are the bytes that files of each format begin with, where the
+// format has them. They are checked before decoding, since the OGG Vorbis
+// decoder never returns when given a file that is not OGG.
+var signatures = map[string][]byte{
+	"wav":  []byte("RIFF"),
+	"ogg":  []byte("OggS"),
+	"flac": []byte("fLaC"),
+}
+
+// extensionsDefault is the order in which extensions are tried if none is
+// configured.
+var extensionsDefault = []string{"wav", "mp3", "ogg", "flac"}
+
+// validateExtensions ensures that every extension in the search order has a
+// decoder, and that none is listed twice.
+func validateExtensions(exts []string) error {
+	seen := make(map[string]bool, len(exts))
+
+	for _, ext := range exts {
+		if _, ok := decoders[ext]; !ok {
+			return errors.Errorf("unsupported audio file extension '%s'; "+
+				"must be one of %s", ext, strings.Join(extensionsDefault, ", "))
+		} else if seen[ext] {
+			return errors.Errorf("audio file extension '%s' listed twice", ext)
+		}
+
+		seen[ext] = true
+	}
+
+	return nil
+}
+
+// findSound returns the path and extension of the first file in the library
+// named after the sound with an extension in the search order.
+func findSound(library, sound string, exts []string) (string, string, error) {
+	for _, ext := range exts {
+		path := library + sound + "." + ext
+
+		if _, err := os.Stat(path); err == nil {
+			return path, ext, nil
+		} else if !os.IsNotExist(err) {
+			return "", "", errors.Wrapf(err, "could not access %s", path)
+		}
+	}
+
+	// Tell apart a missing sound from one whose format is not supported.
+	files, err := ioutil.ReadDir(library)
+	if err != nil {
+		return "", "", errors.Wrap(err, "could not read audio library directory")
+	}
+
+	for _, fi := range files {
+		if !strings.HasPrefix(fi.Name(), sound+".") {
+			continue
+		}
+
+		ext := fi.Name()[len(sound)+1:]
+		if _, ok := decoders[ext]; ok {
+			return "", "", errors.Errorf("sound '%s' is in %s format, "+
+				"which is not enabled in the configuration", sound,
+				formatNames[ext])
+		}
+
+		return "", "", errors.Errorf("sound '%s' is in an unsupported "+
+			"format '.%s'; must be one of %s", sound, ext,
+			strings.Join(extensionsDefault, ", "))
+	}
+
+	return "", "", errors.Wrapf(pipanel.ErrNoSuchSound, "sound '%s' not found "+
+		"in %s with any of extensions %s", sound, library, strings.Join(exts, ", "))
+}
+
+// openSound opens and decodes the sound file at path, using the decoder for
+// its extension.
+func openSound(path, ext string) (beep.StreamSeekCloser, beep.Format, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, beep.Format{}, errors.Wrapf(err, "could not open %s", path)
+	}
+
+	if err = checkSignature(f, ext); err != nil {
+		f.Close()
+		return nil, beep.Format{}, err
+	}
+
+	streamer, format, err := decoders[ext](f)
+	if err != nil {
+		f.Close()
+		return nil, beep.Format{}, errors.Wrapf(err, "could not decode %s audio",
+			formatNames[ext])
+	}
+
+	return streamer, format, nil
+}
+
+// checkSignature ensures that the file begins with the signature of the format
+// given by the extension, if it has one, leaving the file at its start.
+func checkSignature(f *os.File, ext string) error {
+	sig, ok := signatures[ext]
+	if !ok {
+		return nil
+	}
+
+	head := make([]byte, len(sig))
+	if _, err := io.ReadFull(f, head); err != nil || !bytes.Equal(head, sig) {
+		return errors.Errorf("not a %s file", formatNames[ext])
+	}
+
+	_, err := f.Seek(0, io.SeekStart)
+	return errors.Wrap(err, "could not rewind sound file")
+}
@@ -0,0 +1,343 @@
+package beeper
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/wav"
+	"github.com/pkg/errors"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+	"github.com/BenJetson/pipanel/go/logfmt"
+)
+
+// uploadLimitDefault is the size limit for uploaded sounds if none is
+// configured.
+const uploadLimitDefault = 10 << 20
+
+// detectOrder is the order in which formats are tried when detecting the
+// format of an uploaded sound. MP3 comes last, since its decoder is the least
+// strict about what it accepts.
+var detectOrder = []string{"wav", "flac", "ogg", "mp3"}
+
+// validateAudioFilename ensures that a sound name cannot refer to a file
+// outside of the library path. Names may contain subfolders separated by
+// slashes, such as "doorbell/chime".
+func validateAudioFilename(fileName string) error {
+	if len(fileName) < 1 {
+		return pipanel.InvalidSound("filename is empty")
+	}
+
+	// Checks to make sure that no periods exist in the file name.
+	// Exists for secutiry purposes to ensure that files outside of the library
+	// path cannot be accessed (for example "../not_in_library" is bad).
+	if strings.Count(fileName, ".") > 0 {
+		return pipanel.InvalidSound("illegal filename '%s' contains periods",
+			fileName)
+	}
+
+	for _, r := range fileName {
+		if r == '\\' || unicode.IsControl(r) {
+			return pipanel.InvalidSound("illegal filename '%s' contains "+
+				"character %q", fileName, r)
+		}
+	}
+
+	for _, folder := range strings.Split(fileName, "/") {
+		if len(folder) < 1 {
+			return pipanel.InvalidSound("illegal filename '%s' contains an "+
+				"empty folder name", fileName)
+		}
+	}
+
+	return nil
+}
+
+// soundInfo describes the sound stored in the library under the given name
+// and extension.
+func soundInfo(library, name, ext string) (pipanel.SoundInfo, error) {
+	streamer, format, err := openSound(library+name+"."+ext, ext)
+	if err != nil {
+		return pipanel.SoundInfo{}, err
+	}
+	defer streamer.Close()
+
+	return pipanel.SoundInfo{
+		Name:       name,
+		Format:     ext,
+		Duration:   pipanel.Duration(format.SampleRate.D(streamer.Len())),
+		SampleRate: int(format.SampleRate),
+	}, nil
+}
+
+// Sounds lists the sounds in the library, sorted by name. When a sound exists
+// in several formats, the one that would be played is listed. Files that
+// cannot be decoded are left out.
+func (b *Beeper) Sounds(ctx context.Context) ([]pipanel.SoundInfo, error) {
+	b.cfgMux.RLock()
+	cfg := b.cfg
+	b.cfgMux.RUnlock()
+
+	rank := make(map[string]int, len(cfg.Extensions))
+	for i, ext := range cfg.Extensions {
+		rank[ext] = i
+	}
+
+	// Find the extension that would be played for each sound name.
+	found := make(map[string]string)
+	err := filepath.Walk(cfg.LibraryPath, func(path string, fi os.FileInfo,
+		err error) error {
+
+		if err != nil || fi.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(cfg.LibraryPath, path)
+		if err != nil {
+			return err
+		}
+
+		dot := strings.LastIndexByte(rel, '.')
+		if dot < 0 {
+			return nil
+		}
+
+		name, ext := filepath.ToSlash(rel[:dot]), rel[dot+1:]
+		r, ok := rank[ext]
+		if !ok || validateAudioFilename(name) != nil {
+			return nil
+		}
+
+		if prev, ok := found[name]; !ok || r < rank[prev] {
+			found[name] = ext
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read audio library directory")
+	}
+
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sounds := make([]pipanel.SoundInfo, 0, len(names))
+	for _, name := range names {
+		info, err := soundInfo(cfg.LibraryPath, name, found[name])
+		if err != nil {
+			logfmt.WithError(b.log, err).WithContext(ctx).
+				WithField("sound", name).
+				Warnln("Leaving out sound that could not be read.")
+			continue
+		}
+
+		sounds = append(sounds, info)
+	}
+
+	return sounds, nil
+}
+
+// detectFormat finds the format of the sound file at path by trying each of
+// the enabled decoders in turn.
+func detectFormat(path string, exts []string) (string, beep.Format, error) {
+	for _, ext := range detectOrder {
+		if !isEnabled(exts, ext) {
+			continue
+		}
+
+		streamer, format, err := openSound(path, ext)
+		if err == nil {
+			streamer.Close()
+			return ext, format, nil
+		}
+	}
+
+	return "", beep.Format{}, pipanel.InvalidSound("file is not in any "+
+		"enabled format: %s", strings.Join(exts, ", "))
+}
+
+// normalize converts the sound file at path to a WAV file at the sample rate
+// of the speaker, returning the path of the new file, which is created in the
+// same folder.
+func normalize(path, ext string) (string, error) {
+	streamer, format, err := openSound(path, ext)
+	if err != nil {
+		return "", err
+	}
+	defer streamer.Close()
+
+	out, err := ioutil.TempFile(filepath.Dir(path), ".normalize-")
+	if err != nil {
+		return "", errors.Wrap(err, "could not create normalized sound file")
+	}
+
+	err = wav.Encode(out, beep.Resample(4, format.SampleRate, SampleRate, streamer),
+		beep.Format{
+			SampleRate:  SampleRate,
+			NumChannels: format.NumChannels,
+			Precision:   2,
+		})
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return "", errors.Wrap(err, "could not write normalized sound file")
+	}
+
+	return out.Name(), nil
+}
+
+// AddSound adds the sound file read from r to the library under the given
+// name, replacing any sound of that name in any format. The format is detected
+// from the contents of the file. Sounds that are not at the sample rate of the
+// speaker are converted to WAV files at that rate, provided that WAV files are
+// enabled.
+func (b *Beeper) AddSound(ctx context.Context, name string,
+	r io.Reader) (pipanel.SoundInfo, error) {
+
+	if err := validateAudioFilename(name); err != nil {
+		return pipanel.SoundInfo{}, err
+	}
+
+	b.cfgMux.RLock()
+	cfg := b.cfg
+	b.cfgMux.RUnlock()
+
+	b.libMux.Lock()
+	defer b.libMux.Unlock()
+
+	dir := filepath.Dir(cfg.LibraryPath + name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return pipanel.SoundInfo{}, errors.Wrap(err, "could not create folder")
+	}
+
+	// Upload to a temporary file first, so that a failed upload does not
+	// replace the sound. Its name contains a period, so it is never mistaken
+	// for a sound.
+	tmp, err := ioutil.TempFile(dir, ".upload-")
+	if err != nil {
+		return pipanel.SoundInfo{}, errors.Wrap(err, "could not create sound file")
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, io.LimitReader(r, cfg.UploadLimit+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return pipanel.SoundInfo{}, errors.Wrap(err, "could not upload sound file")
+	} else if n > cfg.UploadLimit {
+		return pipanel.SoundInfo{}, errors.Wrapf(pipanel.ErrSoundTooLarge,
+			"limit is %d bytes", cfg.UploadLimit)
+	}
+
+	ext, format, err := detectFormat(tmp.Name(), cfg.Extensions)
+	if err != nil {
+		return pipanel.SoundInfo{}, err
+	}
+
+	src := tmp.Name()
+	if format.SampleRate != SampleRate {
+		if !isEnabled(cfg.Extensions, "wav") {
+			return pipanel.SoundInfo{}, pipanel.InvalidSound("sample rate "+
+				"%d Hz must be converted to WAV, which is not enabled",
+				format.SampleRate)
+		}
+
+		if src, err = normalize(src, ext); err != nil {
+			return pipanel.SoundInfo{}, err
+		}
+		defer os.Remove(src)
+
+		b.log.WithContext(ctx).WithField("sound", name).
+			Printf("Converted %s sound from %d Hz to %d Hz WAV.",
+				formatNames[ext], format.SampleRate, SampleRate)
+		ext = "wav"
+	}
+
+	if err = os.Rename(src, cfg.LibraryPath+name+"."+ext); err != nil {
+		return pipanel.SoundInfo{}, errors.Wrap(err, "could not store sound file")
+	}
+
+	// Remove the sound in other formats, which might otherwise be played
+	// instead.
+	for other := range decoders {
+		if other == ext {
+			continue
+		}
+
+		err := os.Remove(cfg.LibraryPath + name + "." + other)
+		if err != nil && !os.IsNotExist(err) {
+			return pipanel.SoundInfo{}, errors.Wrapf(err,
+				"could not remove %s version of sound", formatNames[other])
+		}
+	}
+
+	b.log.WithContext(ctx).WithField("sound", name).Println("Added sound.")
+
+	return soundInfo(cfg.LibraryPath, name, ext)
+}
+
+// DeleteSound removes the sound with the given name in every format, along
+// with any folders that it leaves empty.
+func (b *Beeper) DeleteSound(ctx context.Context, name string) error {
+	if err := validateAudioFilename(name); err != nil {
+		return err
+	}
+
+	b.cfgMux.RLock()
+	cfg := b.cfg
+	b.cfgMux.RUnlock()
+
+	b.libMux.Lock()
+	defer b.libMux.Unlock()
+
+	var removed bool
+	for ext := range decoders {
+		err := os.Remove(cfg.LibraryPath + name + "." + ext)
+		if err == nil {
+			removed = true
+		} else if !os.IsNotExist(err) {
+			return errors.Wrapf(err, "could not remove %s version of sound",
+				formatNames[ext])
+		}
+	}
+
+	if !removed {
+		return errors.Wrapf(pipanel.ErrNoSuchSound, "sound '%s'", name)
+	}
+
+	// Removing a folder fails unless it is empty.
+	root := filepath.Clean(cfg.LibraryPath)
+	for dir := filepath.Dir(cfg.LibraryPath + name); len(dir) > len(root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+
+	b.log.WithContext(ctx).WithField("sound", name).Println("Deleted sound.")
+
+	return nil
+}
+
+// isEnabled reports whether the extension is in the search order.
+func isEnabled(exts []string, ext string) bool {
+	for _, e := range exts {
+		if e == ext {
+			return true
+		}
+	}
+
+	return false
+}
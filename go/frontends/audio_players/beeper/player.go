@@ -0,0 +1,178 @@
+package beeper
+
+import (
+	"io"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/speaker"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+)
+
+// playback is a sound that is playing or waiting to be played.
+type playback struct {
+	beep.Streamer
+	// file is closed once playback ends.
+	file io.Closer
+	// ended receives how playback ended. It is buffered, so that ending
+	// playback never blocks the speaker.
+	ended chan pipanel.PlaybackEnd
+	// player is the player that the sound was added to.
+	player *player
+}
+
+var _ pipanel.Playback = (*playback)(nil)
+
+func newPlayback(s beep.Streamer, file io.Closer) *playback {
+	return &playback{
+		Streamer: s,
+		file:     file,
+		ended:    make(chan pipanel.PlaybackEnd, 1),
+	}
+}
+
+// Ended returns the channel that receives how playback ended.
+func (p *playback) Ended() <-chan pipanel.PlaybackEnd { return p.ended }
+
+// Stop stops the sound, unless it has already ended.
+func (p *playback) Stop() {
+	speaker.Lock()
+	p.player.remove(p)
+	speaker.Unlock()
+}
+
+// end releases the file of the sound and reports how its playback ended.
+func (p *playback) end(how pipanel.PlaybackEnd) {
+	p.file.Close()
+	p.ended <- how
+}
+
+// player is a beep.Streamer that mixes the sounds that are playing, and starts
+// queued sounds once all others have finished. Like beep.Mixer, it never
+// drains. It is guarded by the speaker lock, which is held while the speaker
+// streams from it.
+type player struct {
+	playing []*playback
+	queue   []*playback
+}
+
+// add starts playing the sound, or queues it, according to the mode.
+func (p *player) add(pb *playback, mode pipanel.PlaybackMode) {
+	pb.player = p
+
+	switch mode {
+	case pipanel.PlaybackReplace:
+		p.stop()
+	case pipanel.PlaybackQueue:
+		if len(p.playing) > 0 || len(p.queue) > 0 {
+			p.queue = append(p.queue, pb)
+			return
+		}
+	}
+
+	p.playing = append(p.playing, pb)
+}
+
+// stop stops every sound that is playing or queued.
+func (p *player) stop() {
+	for _, pb := range p.playing {
+		pb.end(pipanel.PlaybackStopped)
+	}
+
+	for _, pb := range p.queue {
+		pb.end(pipanel.PlaybackStopped)
+	}
+
+	p.playing, p.queue = nil, nil
+}
+
+// remove stops the sound if it is playing or queued.
+func (p *player) remove(pb *playback) {
+	for _, list := range []*[]*playback{&p.playing, &p.queue} {
+		for i, other := range *list {
+			if other == pb {
+				*list = append((*list)[:i], (*list)[i+1:]...)
+				pb.end(pipanel.PlaybackStopped)
+				return
+			}
+		}
+	}
+}
+
+// fill streams from the sound into samples until they are full or the sound
+// drains, so that a drained sound is noticed as soon as it runs out.
+func (pb *playback) fill(samples [][2]float64) (n int, ok bool) {
+	for n < len(samples) {
+		sn, sok := pb.Stream(samples[n:])
+		n += sn
+
+		if !sok {
+			return n, false
+		} else if sn < 1 {
+			break
+		}
+	}
+
+	return n, true
+}
+
+// Stream mixes the sounds that are playing into samples, ending those that
+// drain. Once none are left, the next queued sound starts right where they
+// ended.
+func (p *player) Stream(samples [][2]float64) (n int, ok bool) {
+	var tmp [512][2]float64
+
+	for len(samples) > 0 {
+		toStream := len(tmp)
+		if toStream > len(samples) {
+			toStream = len(samples)
+		}
+
+		for i := range samples[:toStream] {
+			samples[i] = [2]float64{}
+		}
+
+		// Sounds are mixed in from start, which only moves past the
+		// beginning of the chunk for queued sounds.
+		for start := 0; ; {
+			if len(p.playing) < 1 && len(p.queue) > 0 {
+				p.playing = append(p.playing, p.queue[0])
+				p.queue = p.queue[1:]
+			}
+
+			end := start
+			playing := p.playing[:0]
+			for _, pb := range p.playing {
+				sn, sok := pb.fill(tmp[:toStream-start])
+				for i := range tmp[:sn] {
+					samples[start+i][0] += tmp[i][0]
+					samples[start+i][1] += tmp[i][1]
+				}
+
+				if start+sn > end {
+					end = start + sn
+				}
+
+				if sok {
+					playing = append(playing, pb)
+				} else {
+					pb.end(pipanel.PlaybackFinished)
+				}
+			}
+			p.playing = playing
+
+			if len(p.playing) > 0 || len(p.queue) < 1 || end >= toStream {
+				break
+			}
+			start = end
+		}
+
+		samples = samples[toStream:]
+		n += toStream
+	}
+
+	return n, true
+}
+
+// Err always returns nil.
+func (p *player) Err() error { return nil }
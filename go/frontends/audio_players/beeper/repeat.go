@@ -0,0 +1,94 @@
+package beeper
+
+import (
+	"github.com/faiface/beep"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+)
+
+// repeater is a beep.Streamer that plays a sound a number of times, or until
+// it is stopped, with a gap of silence between each play.
+type repeater struct {
+	s beep.StreamSeeker
+	// left is the number of plays left after the current one, or -1 to
+	// repeat until stopped.
+	left int
+	// gap is the number of samples of silence between plays, and gapLeft
+	// the number of those left before the next play starts.
+	gap, gapLeft int
+	// played reports whether the current play has streamed any samples, so
+	// that a sound without any does not repeat forever.
+	played bool
+	err    error
+}
+
+func newRepeater(s beep.StreamSeeker, repeat pipanel.SoundRepeat,
+	gap int) *repeater {
+
+	left := int(repeat) - 1
+	if repeat == pipanel.RepeatUntilAcknowledged {
+		left = -1
+	}
+
+	return &repeater{s: s, left: left, gap: gap}
+}
+
+// Stream streams the sound into samples, rewinding it and inserting the gap
+// whenever it drains and plays are left.
+func (r *repeater) Stream(samples [][2]float64) (n int, ok bool) {
+	for n < len(samples) {
+		if r.gapLeft > 0 {
+			gn := r.gapLeft
+			if gn > len(samples)-n {
+				gn = len(samples) - n
+			}
+
+			for i := range samples[n : n+gn] {
+				samples[n+i] = [2]float64{}
+			}
+
+			r.gapLeft -= gn
+			n += gn
+			continue
+		}
+
+		sn, sok := r.s.Stream(samples[n:])
+		n += sn
+		if sn > 0 {
+			r.played = true
+		}
+
+		if sok && sn > 0 {
+			continue
+		} else if sok {
+			break
+		}
+
+		if r.left == 0 || !r.played {
+			return n, n > 0
+		}
+
+		if err := r.s.Seek(0); err != nil {
+			r.err = err
+			return n, n > 0
+		}
+
+		if r.left > 0 {
+			r.left--
+		}
+		r.gapLeft = r.gap
+		r.played = false
+	}
+
+	return n, true
+}
+
+// Err returns the error of the sound, or the error that stopped it from
+// being rewound.
+func (r *repeater) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+
+	return r.s.Err()
+}
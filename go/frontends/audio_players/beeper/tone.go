@@ -0,0 +1,25 @@
+package beeper
+
+import (
+	"github.com/faiface/beep"
+
+	"github.com/BenJetson/pipanel/go/frontends/audio_players/tonegen"
+)
+
+// toneStreamer is a synthesized tone, which has no file to close.
+type toneStreamer struct {
+	beep.StreamSeeker
+}
+
+func (toneStreamer) Close() error { return nil }
+
+// openTone synthesizes the tone, which is named in the configuration or is a
+// ringtone in RTTTL, at the sample rate of the speaker.
+func openTone(cfg Config, tone string) (beep.StreamSeekCloser, error) {
+	notes, err := tonegen.Notes(cfg.Tones, tone)
+	if err != nil {
+		return nil, err
+	}
+
+	return toneStreamer{tonegen.Synthesize(notes, SampleRate)}, nil
+}
@@ -0,0 +1,26 @@
+package beeper
+
+import (
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/effects"
+)
+
+// volumeRange is the range in decibels covered by volume levels from 100 down
+// to 1. Level 0 is silent.
+const volumeRange = 40
+
+// applyVolume adjusts the volume effect to a level given as a percentage of
+// full volume. Levels are spread evenly in decibels, so that equal steps sound
+// like equal changes in loudness.
+func applyVolume(v *effects.Volume, level uint8) {
+	v.Base = 10
+	v.Volume = (float64(level) - 100) / 100 * volumeRange / 20
+	v.Silent = level == 0
+}
+
+// withVolume wraps the streamer so that it plays at the given level.
+func withVolume(s beep.Streamer, level uint8) beep.Streamer {
+	v := &effects.Volume{Streamer: s}
+	applyVolume(v, level)
+	return v
+}
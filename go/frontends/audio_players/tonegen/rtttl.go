@@ -0,0 +1,194 @@
+package tonegen
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+)
+
+// semitones maps each note letter of RTTTL to its semitone above C. The letter
+// h is a German name for b, used by some ringtones.
+var semitones = map[byte]int{
+	'c': 0, 'd': 2, 'e': 4, 'f': 5, 'g': 7, 'a': 9, 'b': 11, 'h': 11,
+}
+
+// rtttlDefaults are the settings of a ringtone that does not give its own.
+type rtttlDefaults struct {
+	duration int
+	octave   int
+	bpm      int
+}
+
+// ParseRTTTL parses a ringtone in the Ring Tone Text Transfer Language, such
+// as "ding:d=4,o=5,b=120:e6,c6", into the notes it plays.
+func ParseRTTTL(s string) ([]Note, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return nil, errors.New("ringtone must have a name, defaults and " +
+			"notes separated by colons")
+	}
+
+	def, err := parseRTTTLDefaults(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	var notes []Note
+	for i, field := range strings.Split(parts[2], ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if len(field) < 1 {
+			continue
+		}
+
+		note, err := parseRTTTLNote(field, def)
+		if err != nil {
+			return nil, errors.Wrapf(err, "bad note %d '%s'", i+1, field)
+		}
+
+		notes = append(notes, note)
+	}
+
+	return notes, validateNotes(notes)
+}
+
+// parseRTTTLDefaults parses the default section of a ringtone, such as
+// "d=4,o=5,b=120". Settings that are left out take the defaults of the
+// specification.
+func parseRTTTLDefaults(s string) (rtttlDefaults, error) {
+	def := rtttlDefaults{duration: 4, octave: 6, bpm: 63}
+
+	for _, field := range strings.Split(s, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if len(field) < 1 {
+			continue
+		}
+
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return def, errors.Errorf("bad ringtone default '%s'", field)
+		}
+
+		v, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return def, errors.Errorf("bad ringtone default '%s'", field)
+		}
+
+		switch strings.TrimSpace(kv[0]) {
+		case "d":
+			if !validRTTTLDuration(v) {
+				return def, errors.Errorf("default duration %d must be "+
+					"one of 1, 2, 4, 8, 16 or 32", v)
+			}
+			def.duration = v
+		case "o":
+			if v < 1 || v > 8 {
+				return def, errors.Errorf("default octave %d is out of "+
+					"range [1,8]", v)
+			}
+			def.octave = v
+		case "b":
+			if v < 1 || v > 900 {
+				return def, errors.Errorf("tempo %d is out of range [1,900]", v)
+			}
+			def.bpm = v
+		default:
+			return def, errors.Errorf("unknown ringtone default '%s'", kv[0])
+		}
+	}
+
+	return def, nil
+}
+
+func validRTTTLDuration(d int) bool {
+	switch d {
+	case 1, 2, 4, 8, 16, 32:
+		return true
+	}
+	return false
+}
+
+// parseRTTTLNote parses a single note of a ringtone, made of an optional
+// duration, a letter or p for a rest, an optional sharp, and an optional
+// octave. A period, before or after the octave, makes the note half as long
+// again.
+func parseRTTTLNote(s string, def rtttlDefaults) (Note, error) {
+	i := 0
+	number := func() int {
+		start := i
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == start {
+			return 0
+		}
+		n, _ := strconv.Atoi(s[start:i])
+		return n
+	}
+
+	duration := number()
+	if duration == 0 {
+		duration = def.duration
+	} else if !validRTTTLDuration(duration) {
+		return Note{}, errors.Errorf("duration %d must be one of 1, 2, 4, 8, "+
+			"16 or 32", duration)
+	}
+
+	if i >= len(s) {
+		return Note{}, errors.New("missing note letter")
+	}
+
+	letter := s[i]
+	i++
+
+	semitone, ok := semitones[letter]
+	if !ok && letter != 'p' {
+		return Note{}, errors.Errorf("unknown note '%c'", letter)
+	}
+
+	if i < len(s) && s[i] == '#' {
+		semitone++
+		i++
+	}
+
+	dotted := false
+	if i < len(s) && s[i] == '.' {
+		dotted = true
+		i++
+	}
+
+	octave := number()
+	if octave == 0 {
+		octave = def.octave
+	} else if octave > 8 {
+		return Note{}, errors.Errorf("octave %d is out of range [1,8]", octave)
+	}
+
+	if i < len(s) && s[i] == '.' {
+		dotted = true
+		i++
+	}
+
+	if i < len(s) {
+		return Note{}, errors.Errorf("unexpected '%s'", s[i:])
+	}
+
+	// A whole note lasts four beats.
+	length := 4 * time.Minute / time.Duration(def.bpm*duration)
+	if dotted {
+		length += length / 2
+	}
+
+	note := Note{Duration: pipanel.Duration(length)}
+	if letter != 'p' {
+		// Frequencies are relative to A4 at 440 Hz.
+		steps := float64(semitone-9) + float64(12*(octave-4))
+		note.Frequency = 440 * math.Pow(2, steps/12)
+	}
+
+	return note, nil
+}
@@ -0,0 +1,111 @@
+package tonegen
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+)
+
+// closeTo reports whether the notes match, allowing for rounding in the
+// expected frequencies.
+func closeTo(got, want []Note) bool {
+	if len(got) != len(want) {
+		return false
+	}
+
+	for i := range got {
+		if math.Abs(got[i].Frequency-want[i].Frequency) > 0.01 ||
+			got[i].Duration != want[i].Duration {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestParseRTTTL(t *testing.T) {
+	tests := []struct {
+		name     string
+		ringtone string
+		want     []Note
+		wantErr  bool
+	}{
+		{
+			name:     "defaults given",
+			ringtone: "ding:d=4,o=5,b=120:e6,c6",
+			want: []Note{
+				{Frequency: 1318.51, Duration: ms(500)},
+				{Frequency: 1046.50, Duration: ms(500)},
+			},
+		},
+		{
+			name:     "defaults of the specification",
+			ringtone: "x::a",
+			want: []Note{{
+				Frequency: 1760,
+				Duration:  pipanel.Duration(4 * time.Minute / 252),
+			}},
+		},
+		{
+			name:     "durations, rests, sharps and octaves",
+			ringtone: "x:d=8,o=4,b=100:a,p,8c#5,2a.,4a4.",
+			want: []Note{
+				{Frequency: 440, Duration: ms(300)},
+				{Frequency: 0, Duration: ms(300)},
+				{Frequency: 554.37, Duration: ms(300)},
+				{Frequency: 440, Duration: ms(1800)},
+				{Frequency: 440, Duration: ms(900)},
+			},
+		},
+		{
+			name:     "h is b",
+			ringtone: "x:b=120:h5,b5",
+			want: []Note{
+				{Frequency: 987.77, Duration: ms(500)},
+				{Frequency: 987.77, Duration: ms(500)},
+			},
+		},
+		{
+			name:     "case and spaces are ignored",
+			ringtone: "X: D=4, O=5, B=120 : E6 , C6,",
+			want: []Note{
+				{Frequency: 1318.51, Duration: ms(500)},
+				{Frequency: 1046.50, Duration: ms(500)},
+			},
+		},
+		{name: "no sections", ringtone: "a,b,c", wantErr: true},
+		{name: "too many sections", ringtone: "x:d=4:a:b", wantErr: true},
+		{name: "malformed default", ringtone: "x:d:a", wantErr: true},
+		{name: "unknown default", ringtone: "x:q=1:a", wantErr: true},
+		{name: "bad default duration", ringtone: "x:d=3:a", wantErr: true},
+		{name: "bad default octave", ringtone: "x:o=9:a", wantErr: true},
+		{name: "bad tempo", ringtone: "x:b=0:a", wantErr: true},
+		{name: "bad duration", ringtone: "x:d=4:3a", wantErr: true},
+		{name: "missing letter", ringtone: "x:d=4:4", wantErr: true},
+		{name: "unknown letter", ringtone: "x:d=4:x", wantErr: true},
+		{name: "bad octave", ringtone: "x:d=4:a9", wantErr: true},
+		{name: "trailing characters", ringtone: "x:d=4:a5z", wantErr: true},
+		{name: "no notes", ringtone: "x:d=4:", wantErr: true},
+		{name: "too long", ringtone: "x:b=1:1a,1a", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRTTTL(tt.ringtone)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !closeTo(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
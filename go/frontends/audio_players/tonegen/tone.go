@@ -0,0 +1,240 @@
+// Package tonegen synthesizes tones, such as beeps, chimes and sirens, from
+// the frequencies and durations of their notes, and parses ringtones given in
+// RTTTL. No audio files are needed.
+package tonegen
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/pkg/errors"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+)
+
+// Note is a pitch held for a duration.
+type Note struct {
+	// Frequency is the pitch of the note in hertz. Zero is a rest.
+	Frequency float64 `json:"frequency"`
+	// Duration is how long the note is held.
+	Duration pipanel.Duration `json:"duration"`
+}
+
+// Spec describes a tone, either as a sequence of notes or as a ringtone in
+// RTTTL. Exactly one of them must be given.
+type Spec struct {
+	// Notes are played one after the other.
+	Notes []Note `json:"notes,omitempty"`
+	// RTTTL is a ringtone in the Ring Tone Text Transfer Language, such as
+	// "ding:d=4,o=5,b=120:e6,c6".
+	RTTTL string `json:"rtttl,omitempty"`
+}
+
+// Builtin are the tones that are always available, unless replaced by
+// configured tones of the same name.
+var Builtin = map[string]Spec{
+	"beep": {Notes: []Note{
+		{Frequency: 880, Duration: ms(200)},
+	}},
+	"chime": {Notes: []Note{
+		{Frequency: 659.25, Duration: ms(400)},
+		{Frequency: 523.25, Duration: ms(700)},
+	}},
+	"siren": {Notes: []Note{
+		{Frequency: 960, Duration: ms(450)},
+		{Frequency: 770, Duration: ms(450)},
+		{Frequency: 960, Duration: ms(450)},
+		{Frequency: 770, Duration: ms(450)},
+		{Frequency: 960, Duration: ms(450)},
+		{Frequency: 770, Duration: ms(450)},
+	}},
+}
+
+func ms(n int) pipanel.Duration { return pipanel.Duration(time.Duration(n) * time.Millisecond) }
+
+// Limits on notes, so that a tone can be played at a sample rate of 16 kHz and
+// does not play for an unreasonable time.
+const (
+	maxFrequency = 8000
+	maxNotes     = 1000
+	maxDuration  = 5 * time.Minute
+)
+
+// notes returns the notes of the spec, parsing its ringtone if it has one.
+func (s Spec) notes() ([]Note, error) {
+	if len(s.Notes) > 0 && len(s.RTTTL) > 0 {
+		return nil, errors.New("tone cannot have both notes and a ringtone")
+	} else if len(s.RTTTL) > 0 {
+		return ParseRTTTL(s.RTTTL)
+	}
+
+	return s.Notes, validateNotes(s.Notes)
+}
+
+// validateNotes ensures that the notes are in range and add up to a tone that
+// plays for a reasonable time.
+func validateNotes(notes []Note) error {
+	if len(notes) < 1 {
+		return errors.New("tone has no notes")
+	} else if len(notes) > maxNotes {
+		return errors.Errorf("tone has %d notes; at most %d are allowed",
+			len(notes), maxNotes)
+	}
+
+	var total time.Duration
+	for i, n := range notes {
+		if n.Frequency < 0 || n.Frequency > maxFrequency {
+			return errors.Errorf("frequency %g Hz of note %d is out of "+
+				"range [0,%d]", n.Frequency, i+1, maxFrequency)
+		} else if n.Duration <= 0 {
+			return errors.Errorf("duration of note %d must be positive", i+1)
+		}
+
+		total += time.Duration(n.Duration)
+	}
+
+	if total > maxDuration {
+		return errors.Errorf("tone plays for %s; at most %s is allowed",
+			total, maxDuration)
+	}
+
+	return nil
+}
+
+// ValidateTones ensures that every tone has a usable name and spec.
+func ValidateTones(tones map[string]Spec) error {
+	for name, spec := range tones {
+		if len(name) < 1 || strings.Contains(name, ":") {
+			return errors.Errorf("tone name '%s' must be non-empty and "+
+				"cannot contain a colon", name)
+		} else if _, err := spec.notes(); err != nil {
+			return errors.Wrapf(err, "bad tone '%s'", name)
+		}
+	}
+
+	return nil
+}
+
+// Notes returns the notes of the tone, which is either a ringtone in RTTTL or
+// the name of a tone in tones or Builtin.
+func Notes(tones map[string]Spec, tone string) ([]Note, error) {
+	if strings.Contains(tone, ":") {
+		notes, err := ParseRTTTL(tone)
+		return notes, errors.Wrap(err, "bad ringtone")
+	}
+
+	spec, ok := tones[tone]
+	if !ok {
+		spec, ok = Builtin[tone]
+	}
+
+	if !ok {
+		return nil, errors.Wrapf(pipanel.ErrNoSuchSound, "no tone named '%s'",
+			tone)
+	}
+
+	notes, err := spec.notes()
+	return notes, errors.Wrapf(err, "bad tone '%s'", tone)
+}
+
+// amplitude is the peak amplitude of synthesized notes, leaving headroom for
+// mixing them with other sounds.
+const amplitude = 0.4
+
+// fadeTime is how long each note fades in and out, so that notes start and
+// stop without clicking.
+const fadeTime = 5 * time.Millisecond
+
+// melody is a beep.StreamSeeker that synthesizes notes as sine waves.
+type melody struct {
+	sr    beep.SampleRate
+	notes []Note
+	// ends is the sample at which each note ends.
+	ends []int
+	pos  int
+}
+
+// Synthesize returns a streamer that plays the notes at the sample rate.
+func Synthesize(notes []Note, sr beep.SampleRate) beep.StreamSeeker {
+	m := &melody{sr: sr, notes: notes, ends: make([]int, len(notes))}
+
+	var end int
+	for i, n := range notes {
+		end += sr.N(time.Duration(n.Duration))
+		m.ends[i] = end
+	}
+
+	return m
+}
+
+// Stream synthesizes the notes into samples.
+func (m *melody) Stream(samples [][2]float64) (n int, ok bool) {
+	if m.pos >= m.Len() {
+		return 0, false
+	}
+
+	fade := m.sr.N(fadeTime)
+
+	i := sort.SearchInts(m.ends, m.pos+1)
+	for n < len(samples) && i < len(m.notes) {
+		start := 0
+		if i > 0 {
+			start = m.ends[i-1]
+		}
+		length := m.ends[i] - start
+
+		note := m.notes[i]
+		for ; n < len(samples) && m.pos < m.ends[i]; n, m.pos = n+1, m.pos+1 {
+			t := m.pos - start
+
+			// Fade over at most half of the note, so that short notes
+			// still reach a peak.
+			gain := 1.0
+			if f := fade; f > 0 {
+				if f > length/2 {
+					f = length / 2
+				}
+				if t < f {
+					gain = float64(t) / float64(f)
+				} else if length-t < f {
+					gain = float64(length-t) / float64(f)
+				}
+			}
+
+			v := amplitude * gain * math.Sin(2*math.Pi*note.Frequency*
+				float64(t)/float64(m.sr))
+			samples[n] = [2]float64{v, v}
+		}
+
+		i++
+	}
+
+	return n, true
+}
+
+// Err always returns nil.
+func (m *melody) Err() error { return nil }
+
+// Len returns the number of samples in the melody.
+func (m *melody) Len() int {
+	if len(m.ends) < 1 {
+		return 0
+	}
+	return m.ends[len(m.ends)-1]
+}
+
+// Position returns the current sample of the melody.
+func (m *melody) Position() int { return m.pos }
+
+// Seek moves to the sample p of the melody.
+func (m *melody) Seek(p int) error {
+	if p < 0 || p > m.Len() {
+		return errors.Errorf("seek position %d out of range [0,%d]", p, m.Len())
+	}
+
+	m.pos = p
+	return nil
+}
@@ -0,0 +1,160 @@
+package tonegen
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+)
+
+func TestNotes(t *testing.T) {
+	tones := map[string]Spec{
+		"beep":  {Notes: []Note{{Frequency: 1000, Duration: ms(100)}}},
+		"alarm": {RTTTL: "alarm:d=4,o=5,b=120:a"},
+	}
+
+	tests := []struct {
+		name      string
+		tone      string
+		want      []Note
+		wantSound bool
+		wantErr   bool
+	}{
+		{
+			name: "builtin",
+			tone: "chime",
+			want: Builtin["chime"].Notes,
+		},
+		{
+			name: "configured tone replaces builtin",
+			tone: "beep",
+			want: []Note{{Frequency: 1000, Duration: ms(100)}},
+		},
+		{
+			name: "configured ringtone",
+			tone: "alarm",
+			want: []Note{{Frequency: 880, Duration: ms(500)}},
+		},
+		{
+			name: "ringtone given directly",
+			tone: "x:d=4,o=4,b=120:a",
+			want: []Note{{Frequency: 440, Duration: ms(500)}},
+		},
+		{
+			name:      "unknown tone",
+			tone:      "doorbell",
+			wantErr:   true,
+			wantSound: true,
+		},
+		{
+			name:    "bad ringtone",
+			tone:    "x:d=4:q",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Notes(tones, tt.tone)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+
+				noSound := errors.Cause(err) == pipanel.ErrNoSuchSound
+				if noSound != tt.wantSound {
+					t.Errorf("error %v: no such sound is %t, want %t",
+						err, noSound, tt.wantSound)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !closeTo(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTones(t *testing.T) {
+	note := Note{Frequency: 440, Duration: ms(100)}
+
+	tests := []struct {
+		name    string
+		tones   map[string]Spec
+		wantErr bool
+	}{
+		{name: "none"},
+		{
+			name: "notes and ringtone",
+			tones: map[string]Spec{
+				"a": {Notes: []Note{note}},
+				"b": {RTTTL: "b:d=4:c"},
+			},
+		},
+		{
+			name:    "empty name",
+			tones:   map[string]Spec{"": {Notes: []Note{note}}},
+			wantErr: true,
+		},
+		{
+			name:    "name with a colon",
+			tones:   map[string]Spec{"a:b": {Notes: []Note{note}}},
+			wantErr: true,
+		},
+		{
+			name:    "both notes and a ringtone",
+			tones:   map[string]Spec{"a": {Notes: []Note{note}, RTTTL: "a:d=4:c"}},
+			wantErr: true,
+		},
+		{
+			name:    "no notes",
+			tones:   map[string]Spec{"a": {}},
+			wantErr: true,
+		},
+		{
+			name: "frequency out of range",
+			tones: map[string]Spec{"a": {Notes: []Note{
+				{Frequency: maxFrequency + 1, Duration: ms(100)},
+			}}},
+			wantErr: true,
+		},
+		{
+			name: "negative frequency",
+			tones: map[string]Spec{"a": {Notes: []Note{
+				{Frequency: -1, Duration: ms(100)},
+			}}},
+			wantErr: true,
+		},
+		{
+			name:    "zero duration",
+			tones:   map[string]Spec{"a": {Notes: []Note{{Frequency: 440}}}},
+			wantErr: true,
+		},
+		{
+			name:    "bad ringtone",
+			tones:   map[string]Spec{"a": {RTTTL: "a:d=3:c"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTones(tt.tones)
+			if tt.wantErr && err == nil {
+				t.Error("expected an error")
+			} else if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestBuiltinTonesAreValid(t *testing.T) {
+	if err := ValidateTones(Builtin); err != nil {
+		t.Error(err)
+	}
+}
@@ -32,6 +32,18 @@ func (d *DisplayLog) SetBrightness(ctx context.Context,
 	return nil
 }
 
+// SetDisplayPower handles display power events by writing the details to the
+// console.
+func (d *DisplayLog) SetDisplayPower(ctx context.Context,
+	e pipanel.DisplayPowerEvent) error {
+
+	d.log.WithContext(ctx).WithFields(logrus.Fields{
+		"state": e.State,
+	}).Println("Received display power event.")
+
+	return nil
+}
+
 // Init initializes this DisplayLog by setting the logger.
 func (d *DisplayLog) Init(log *logrus.Entry, _ json.RawMessage) error {
 	d.log = log
@@ -0,0 +1,66 @@
+// Package dpms changes the power state of X displays using DPMS, for use by
+// DisplayManager implementations.
+package dpms
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+)
+
+// displayDefault is the X display used if none is given and DISPLAY is unset,
+// which is the local display of the panel.
+const displayDefault = ":0"
+
+// modes maps each display power state to the matching xset DPMS mode.
+var modes = map[pipanel.DisplayPower]string{
+	pipanel.DisplayPowerOn:      "on",
+	pipanel.DisplayPowerStandby: "standby",
+	pipanel.DisplayPowerOff:     "off",
+}
+
+// Display returns the X display to use: the given one, or else the value of
+// the DISPLAY environment variable, or else ":0".
+func Display(display string) string {
+	if len(display) > 0 {
+		return display
+	} else if env := os.Getenv("DISPLAY"); len(env) > 0 {
+		return env
+	}
+
+	return displayDefault
+}
+
+// Set forces the given X display into the given power state using xset. See
+// Display for how an empty display is handled.
+func Set(ctx context.Context, display string, p pipanel.DisplayPower) error {
+	mode, ok := modes[p]
+	if !ok {
+		return errors.Errorf("unknown display power state '%s'", p)
+	}
+
+	args := []string{"-display", Display(display), "dpms", "force", mode}
+
+	if p == pipanel.DisplayPowerOn {
+		// Forcing DPMS on does not reset the screen saver, which would
+		// otherwise blank the display again shortly after.
+		args = append(args, "s", "reset")
+	}
+
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "xset", args...)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "xset failed: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
@@ -15,6 +15,7 @@ import (
 var _ pipanel.DisplayManager = (*TouchDisplayManager)(nil)
 
 const brightFile string = "/sys/class/backlight/rpi_backlight/brightness"
+const powerFile string = "/sys/class/backlight/rpi_backlight/bl_power"
 
 // TouchDisplayManager implements pipanel.DisplayManager for the Raspberry Pi
 // official 7" touchscreen device.
@@ -52,6 +53,35 @@ func (t *TouchDisplayManager) SetBrightness(ctx context.Context,
 	return errors.Wrap(err, "failed to close brightness register file")
 }
 
+// SetDisplayPower handles pipanel display power events. The touchscreen has no
+// standby state, so standby turns the display off.
+func (t *TouchDisplayManager) SetDisplayPower(ctx context.Context,
+	e pipanel.DisplayPowerEvent) error {
+
+	// The driver lights the display when bl_power is zero.
+	value := "1"
+	if e.State == pipanel.DisplayPowerOn {
+		value = "0"
+	}
+
+	f, err := os.OpenFile(powerFile, os.O_WRONLY, 0666)
+
+	if err != nil {
+		return errors.Wrap(err, "could not open power register file")
+	}
+
+	t.log.WithContext(ctx).
+		Printf("Setting RPi touchscreen display power %s.", e.State)
+
+	if _, err = f.WriteString(value); err != nil {
+		f.Close()
+		return errors.Wrap(err, "could not write to power register file")
+	}
+
+	err = f.Close()
+	return errors.Wrap(err, "failed to close power register file")
+}
+
 // Init initializes this TouchDisplayManager.
 func (t *TouchDisplayManager) Init(log *logrus.Entry, _ json.RawMessage) error {
 	// TODO might be a good idea to set a default brightness in here.
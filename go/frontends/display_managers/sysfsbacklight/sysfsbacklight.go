@@ -0,0 +1,304 @@
+package sysfsbacklight
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+	"github.com/BenJetson/pipanel/go/frontends/display_managers/dpms"
+)
+
+var _ pipanel.DisplayManager = (*Backlight)(nil)
+var _ pipanel.BrightnessReader = (*Backlight)(nil)
+var _ pipanel.ConfigDescriber = (*Backlight)(nil)
+
+const sysfsRootDefault = "/sys/class/backlight"
+
+// Config specifies the options that modify the behavior of Backlight.
+type Config struct {
+	// SysfsRoot is the directory containing the backlight devices.
+	//
+	// Defaults to "/sys/class/backlight" if not set.
+	SysfsRoot string `json:"sysfs_root"`
+	// Device is the name of the backlight device within SysfsRoot.
+	//
+	// If not set, a device is detected automatically, preferring firmware
+	// devices over platform devices over raw devices.
+	Device string `json:"device"`
+	// MinLevel is the lowest level, on the range [0,255], that the brightness
+	// will be set to. Lower levels are raised to this level, which prevents
+	// panels that blank at low levels from turning off.
+	//
+	// Defaults to zero if not set.
+	MinLevel uint8 `json:"min_level"`
+	// PowerMethod selects how the display is turned on and off: "bl_power"
+	// uses the bl_power file of the backlight device, while "dpms" uses DPMS
+	// on the X display.
+	//
+	// Defaults to "bl_power" if not set.
+	PowerMethod PowerMethod `json:"power_method"`
+	// XDisplay is the X display used by the "dpms" power method.
+	//
+	// Defaults to the DISPLAY environment variable, or else ":0".
+	XDisplay string `json:"x_display"`
+}
+
+// PowerMethod names a way of changing the power state of the display.
+type PowerMethod string
+
+const (
+	// PowerBLPower uses the bl_power file of the backlight device.
+	PowerBLPower PowerMethod = "bl_power"
+	// PowerDPMS uses DPMS on the X display.
+	PowerDPMS PowerMethod = "dpms"
+)
+
+// blPowerValues maps each display power state to the framebuffer blanking
+// level written to bl_power.
+var blPowerValues = map[pipanel.DisplayPower]string{
+	pipanel.DisplayPowerOn:      "0", // FB_BLANK_UNBLANK
+	pipanel.DisplayPowerStandby: "1", // FB_BLANK_NORMAL
+	pipanel.DisplayPowerOff:     "4", // FB_BLANK_POWERDOWN
+}
+
+// fillDefaults will overwrite zero values with the default configuration.
+func (cfg *Config) fillDefaults() {
+	if len(cfg.SysfsRoot) < 1 {
+		cfg.SysfsRoot = sysfsRootDefault
+	}
+	if len(cfg.PowerMethod) < 1 {
+		cfg.PowerMethod = PowerBLPower
+	}
+}
+
+// Backlight implements pipanel.DisplayManager for any display whose backlight
+// is controlled through the Linux sysfs backlight class, scaling brightness
+// levels to the range supported by the device.
+type Backlight struct {
+	log           *logrus.Entry
+	cfg           Config
+	devicePath    string
+	maxBrightness int
+}
+
+// New creates a Backlight instance.
+func New() *Backlight { return &Backlight{} }
+
+// typeRank orders backlight device types by preference, as the kernel
+// documentation recommends.
+var typeRank = map[string]int{"firmware": 0, "platform": 1, "raw": 2}
+
+// detectDevice finds the preferred backlight device within the sysfs root.
+func detectDevice(root string) (string, error) {
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return "", errors.Wrap(err, "could not list backlight devices")
+	}
+
+	var names []string
+	for _, e := range entries {
+		// Devices are usually symbolic links, so Stat through them.
+		if _, err := os.Stat(filepath.Join(root, e.Name(), "max_brightness")); err == nil {
+			names = append(names, e.Name())
+		}
+	}
+
+	if len(names) < 1 {
+		return "", errors.Errorf("no backlight devices found in %s", root)
+	}
+
+	rank := func(name string) int {
+		t, err := readString(filepath.Join(root, name, "type"))
+		if r, ok := typeRank[t]; err == nil && ok {
+			return r
+		}
+		return len(typeRank)
+	}
+
+	sort.SliceStable(names, func(i, j int) bool {
+		if ri, rj := rank(names[i]), rank(names[j]); ri != rj {
+			return ri < rj
+		}
+		return names[i] < names[j]
+	})
+
+	return names[0], nil
+}
+
+func readString(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	return strings.TrimSpace(string(b)), err
+}
+
+func readInt(path string) (int, error) {
+	s, err := readString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := strconv.Atoi(s)
+	return n, errors.Wrapf(err, "malformed value in %s", path)
+}
+
+// scale converts a value from the range [0,fromMax] to the range [0,toMax].
+func scale(v, fromMax, toMax int) int {
+	return int(math.Round(float64(v) * float64(toMax) / float64(fromMax)))
+}
+
+// SetBrightness handles pipanel brightness events, scaling the level to the
+// range of the device.
+func (b *Backlight) SetBrightness(ctx context.Context,
+	e pipanel.BrightnessEvent) error {
+
+	level := e.Level
+	if level < b.cfg.MinLevel {
+		level = b.cfg.MinLevel
+	}
+
+	raw := scale(int(level), math.MaxUint8, b.maxBrightness)
+
+	b.log.WithContext(ctx).WithFields(logrus.Fields{
+		"brightness": level,
+		"raw":        raw,
+		"max":        b.maxBrightness,
+	}).Println("Setting backlight brightness.")
+
+	return b.writeDeviceFile("brightness", strconv.Itoa(raw))
+}
+
+// writeDeviceFile writes a value to a file of the backlight device.
+func (b *Backlight) writeDeviceFile(name, value string) error {
+	f, err := os.OpenFile(filepath.Join(b.devicePath, name), os.O_WRONLY, 0)
+	if err != nil {
+		return errors.Wrapf(err, "could not open %s file", name)
+	}
+
+	if _, err = f.WriteString(value); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "could not write to %s file", name)
+	}
+
+	err = f.Close()
+	return errors.Wrapf(err, "failed to close %s file", name)
+}
+
+// SetDisplayPower handles pipanel display power events using the configured
+// power method.
+func (b *Backlight) SetDisplayPower(ctx context.Context,
+	e pipanel.DisplayPowerEvent) error {
+
+	b.log.WithContext(ctx).WithFields(logrus.Fields{
+		"state":  e.State,
+		"method": b.cfg.PowerMethod,
+	}).Println("Setting display power.")
+
+	if b.cfg.PowerMethod == PowerDPMS {
+		return dpms.Set(ctx, b.cfg.XDisplay, e.State)
+	}
+
+	value, ok := blPowerValues[e.State]
+	if !ok {
+		return errors.Errorf("unknown display power state '%s'", e.State)
+	}
+
+	return b.writeDeviceFile("bl_power", value)
+}
+
+// Brightness reads the actual brightness level back from the device, scaled
+// to the range [0,255].
+func (b *Backlight) Brightness(ctx context.Context) (uint8, error) {
+	raw, err := readInt(filepath.Join(b.devicePath, "actual_brightness"))
+	if os.IsNotExist(errors.Cause(err)) {
+		raw, err = readInt(filepath.Join(b.devicePath, "brightness"))
+	}
+
+	if err != nil {
+		return 0, errors.Wrap(err, "could not read brightness")
+	}
+
+	return uint8(scale(raw, b.maxBrightness, math.MaxUint8)), nil
+}
+
+// decodeConfig decodes the raw JSON configuration, filling in defaults.
+func decodeConfig(rawCfg json.RawMessage) (Config, error) {
+	var cfg Config
+
+	if len(rawCfg) > 0 {
+		d := json.NewDecoder(bytes.NewReader(rawCfg))
+		d.DisallowUnknownFields()
+
+		if err := d.Decode(&cfg); err != nil {
+			return cfg, errors.Wrap(err, "malformed JSON for Backlight configuration")
+		}
+	}
+
+	cfg.fillDefaults()
+
+	switch cfg.PowerMethod {
+	case PowerBLPower, PowerDPMS:
+	default:
+		return cfg, errors.Errorf("no such power method '%s'", cfg.PowerMethod)
+	}
+
+	return cfg, nil
+}
+
+// ConfigTemplate returns a pointer to a zero Config.
+func (b *Backlight) ConfigTemplate() interface{} { return &Config{} }
+
+// ValidateConfig decodes and validates the raw JSON configuration without
+// applying it.
+func (b *Backlight) ValidateConfig(rawCfg json.RawMessage) error {
+	_, err := decodeConfig(rawCfg)
+	return err
+}
+
+// Init initializes this Backlight, locating the device and reading its
+// maximum brightness.
+func (b *Backlight) Init(log *logrus.Entry, rawCfg json.RawMessage) error {
+	b.log = log
+
+	cfg, err := decodeConfig(rawCfg)
+	if err != nil {
+		return err
+	}
+
+	b.cfg = cfg
+
+	device := cfg.Device
+	if len(device) < 1 {
+		if device, err = detectDevice(cfg.SysfsRoot); err != nil {
+			return errors.Wrap(err, "could not detect backlight device")
+		}
+	}
+
+	b.devicePath = filepath.Join(cfg.SysfsRoot, device)
+
+	b.maxBrightness, err = readInt(filepath.Join(b.devicePath, "max_brightness"))
+	if err != nil {
+		return errors.Wrapf(err, "could not read maximum brightness of %s", device)
+	} else if b.maxBrightness < 1 {
+		return errors.Errorf("device %s has no brightness range", device)
+	}
+
+	b.log.WithFields(logrus.Fields{
+		"device": device,
+		"max":    b.maxBrightness,
+	}).Println("Using backlight device.")
+
+	return nil
+}
+
+// Cleanup tears down this Backlight.
+func (b *Backlight) Cleanup() error { return nil }
@@ -0,0 +1,238 @@
+package sysfsbacklight
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+)
+
+// device describes a fake backlight device within a sysfs root.
+type device struct {
+	name    string
+	typ     string
+	max     string
+	symlink bool
+}
+
+// makeRoot creates a fake sysfs backlight root containing the given devices.
+// The caller removes it.
+func makeRoot(t *testing.T, devices ...device) string {
+	t.Helper()
+
+	root, err := ioutil.TempDir("", "sysfsbacklight")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range devices {
+		dir := filepath.Join(root, d.name)
+		if d.symlink {
+			// The kernel links each device to its directory elsewhere.
+			dir = filepath.Join(root, ".devices", d.name)
+		}
+
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		files := map[string]string{"type": d.typ, "max_brightness": d.max,
+			"brightness": "0"}
+		for name, value := range files {
+			if len(value) < 1 {
+				continue
+			}
+
+			err := ioutil.WriteFile(filepath.Join(dir, name), []byte(value+"\n"), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if d.symlink {
+			if err := os.Symlink(dir, filepath.Join(root, d.name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	return root
+}
+
+func TestScale(t *testing.T) {
+	tests := []struct {
+		v, fromMax, toMax int
+		want              int
+	}{
+		{0, 255, 100, 0},
+		{255, 255, 100, 100},
+		{128, 255, 100, 50},
+		{1, 255, 7, 0},
+		{19, 255, 7, 1},
+		{255, 255, 4882, 4882},
+		{4882, 4882, 255, 255},
+		{2441, 4882, 255, 128},
+		{1, 1, 255, 255},
+	}
+
+	for _, tt := range tests {
+		if got := scale(tt.v, tt.fromMax, tt.toMax); got != tt.want {
+			t.Errorf("scale(%d, %d, %d) = %d, want %d",
+				tt.v, tt.fromMax, tt.toMax, got, tt.want)
+		}
+	}
+}
+
+func TestDetectDevice(t *testing.T) {
+	tests := []struct {
+		name    string
+		devices []device
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "no devices",
+			wantErr: true,
+		},
+		{
+			name:    "single device",
+			devices: []device{{name: "rpi_backlight", typ: "raw", max: "255"}},
+			want:    "rpi_backlight",
+		},
+		{
+			name: "firmware preferred over platform and raw",
+			devices: []device{
+				{name: "a_raw", typ: "raw", max: "255"},
+				{name: "b_platform", typ: "platform", max: "100"},
+				{name: "c_firmware", typ: "firmware", max: "7"},
+			},
+			want: "c_firmware",
+		},
+		{
+			name: "platform preferred over raw",
+			devices: []device{
+				{name: "a_raw", typ: "raw", max: "255"},
+				{name: "b_platform", typ: "platform", max: "100"},
+			},
+			want: "b_platform",
+		},
+		{
+			name: "unknown type ranks last",
+			devices: []device{
+				{name: "a_odd", typ: "odd", max: "255"},
+				{name: "b_raw", typ: "raw", max: "255"},
+			},
+			want: "b_raw",
+		},
+		{
+			name: "ties broken by name",
+			devices: []device{
+				{name: "intel_backlight", typ: "raw", max: "4882"},
+				{name: "acpi_video0", typ: "raw", max: "15"},
+			},
+			want: "acpi_video0",
+		},
+		{
+			name: "directories without max_brightness are skipped",
+			devices: []device{
+				{name: "a_broken", typ: "firmware"},
+				{name: "b_raw", typ: "raw", max: "255"},
+			},
+			want: "b_raw",
+		},
+		{
+			name: "symbolic links are followed",
+			devices: []device{
+				{name: "intel_backlight", typ: "raw", max: "4882", symlink: true},
+			},
+			want: "intel_backlight",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := makeRoot(t, tt.devices...)
+			defer os.RemoveAll(root)
+
+			got, err := detectDevice(root)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got device %s", got)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("got device %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectDeviceMissingRoot(t *testing.T) {
+	if _, err := detectDevice(filepath.Join(os.TempDir(), "no-such-root")); err == nil {
+		t.Error("expected an error for a missing sysfs root")
+	}
+}
+
+func TestSetBrightness(t *testing.T) {
+	tests := []struct {
+		name     string
+		max      string
+		minLevel uint8
+		level    uint8
+		wantRaw  string
+		wantRead uint8
+	}{
+		{name: "full", max: "4882", level: 255, wantRaw: "4882", wantRead: 255},
+		{name: "off", max: "4882", level: 0, wantRaw: "0", wantRead: 0},
+		{name: "half", max: "100", level: 128, wantRaw: "50", wantRead: 128},
+		{name: "coarse device", max: "7", level: 128, wantRaw: "4", wantRead: 146},
+		{name: "minimum level", max: "255", minLevel: 20, level: 5,
+			wantRaw: "20", wantRead: 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := makeRoot(t, device{name: "bl", typ: "raw", max: tt.max})
+			defer os.RemoveAll(root)
+
+			rawCfg, err := json.Marshal(Config{SysfsRoot: root, MinLevel: tt.minLevel})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b := New()
+			if err = b.Init(logrus.NewEntry(logrus.New()), rawCfg); err != nil {
+				t.Fatalf("failed to initialize: %v", err)
+			}
+
+			ctx := context.Background()
+			if err = b.SetBrightness(ctx, pipanel.BrightnessEvent{Level: tt.level}); err != nil {
+				t.Fatalf("failed to set brightness: %v", err)
+			}
+
+			raw, err := readString(filepath.Join(root, "bl", "brightness"))
+			if err != nil {
+				t.Fatal(err)
+			} else if raw != tt.wantRaw {
+				t.Errorf("wrote raw brightness %s, want %s", raw, tt.wantRaw)
+			}
+
+			level, err := b.Brightness(ctx)
+			if err != nil {
+				t.Fatalf("failed to read brightness: %v", err)
+			} else if level != tt.wantRead {
+				t.Errorf("read brightness %d, want %d", level, tt.wantRead)
+			}
+		})
+	}
+}
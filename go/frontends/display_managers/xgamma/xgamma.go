@@ -0,0 +1,346 @@
+// Package xgamma provides a DisplayManager that dims the display and shifts its
+// color temperature through X11 RandR gamma ramps, for displays such as HDMI
+// monitors that have no backlight control.
+package xgamma
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"sync"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/randr"
+	"github.com/jezek/xgb/xproto"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+	"github.com/BenJetson/pipanel/go/frontends/display_managers/dpms"
+)
+
+var _ pipanel.DisplayManager = (*Gamma)(nil)
+var _ pipanel.BrightnessReader = (*Gamma)(nil)
+var _ pipanel.ColorTemperatureSetter = (*Gamma)(nil)
+var _ pipanel.ConfigDescriber = (*Gamma)(nil)
+
+// The range of color temperatures that can be approximated.
+const (
+	kelvinMin = 1000
+	kelvinMax = 10000
+)
+
+// Config specifies the options that modify the behavior of Gamma.
+type Config struct {
+	// Display is the X display to control.
+	//
+	// Defaults to the DISPLAY environment variable, or else ":0".
+	Display string `json:"display"`
+	// Outputs are the names of the RandR outputs to control, such as
+	// "HDMI-1".
+	//
+	// Defaults to every connected output if not set.
+	Outputs []string `json:"outputs"`
+	// MinLevel is the lowest level, on the range [0,255], that the brightness
+	// will be set to. Lower levels are raised to this level, which keeps the
+	// display legible.
+	//
+	// Defaults to zero if not set.
+	MinLevel uint8 `json:"min_level"`
+}
+
+// crtc is a CRTC whose gamma ramps are controlled, along with the ramps it had
+// beforehand.
+type crtc struct {
+	id               randr.Crtc
+	size             uint16
+	red, green, blue []uint16
+}
+
+// Gamma implements pipanel.DisplayManager for X displays, dimming them by
+// scaling their gamma ramps. The gamma ramps in place beforehand are restored
+// upon Cleanup.
+type Gamma struct {
+	log   *logrus.Entry
+	cfg   Config
+	conn  *xgb.Conn
+	crtcs []crtc
+
+	// mux guards level and kelvin, and serializes changes to the ramps.
+	mux    sync.Mutex
+	level  uint8
+	kelvin uint16
+}
+
+// New creates a Gamma instance.
+func New() *Gamma { return &Gamma{level: math.MaxUint8} }
+
+// whitePoint approximates the color of a black body at the given temperature
+// as factors for the red, green and blue channels, on the range [0,1]. A zero
+// temperature is neutral.
+func whitePoint(kelvin uint16) (r, g, b float64) {
+	if kelvin == 0 {
+		return 1, 1, 1
+	}
+
+	// Fit by Tanner Helland to the CIE 1964 color matching functions.
+	t := float64(kelvin) / 100
+
+	clamp := func(v float64) float64 { return math.Max(0, math.Min(v, 255)) / 255 }
+
+	if t <= 66 {
+		r = 1
+		g = clamp(99.4708025861*math.Log(t) - 161.1195681661)
+	} else {
+		r = clamp(329.698727446 * math.Pow(t-60, -0.1332047592))
+		g = clamp(288.1221695283 * math.Pow(t-60, -0.0755148492))
+	}
+
+	switch {
+	case t >= 66:
+		b = 1
+	case t <= 19:
+		b = 0
+	default:
+		b = clamp(138.5177312231*math.Log(t-10) - 305.0447927307)
+	}
+
+	return r, g, b
+}
+
+// ramp builds a linear gamma ramp of the given size, scaled by factor.
+func ramp(size uint16, factor float64) []uint16 {
+	r := make([]uint16, size)
+
+	for i := range r {
+		v := float64(i) / float64(size-1) * factor * math.MaxUint16
+		r[i] = uint16(math.Round(v))
+	}
+
+	return r
+}
+
+// apply sets the gamma ramps of every CRTC for the current level and color
+// temperature. The caller must hold g.mux.
+func (g *Gamma) apply() error {
+	level := g.level
+	if level < g.cfg.MinLevel {
+		level = g.cfg.MinLevel
+	}
+
+	scale := float64(level) / math.MaxUint8
+	r, gr, b := whitePoint(g.kelvin)
+
+	for _, c := range g.crtcs {
+		err := randr.SetCrtcGammaChecked(g.conn, c.id, c.size,
+			ramp(c.size, scale*r), ramp(c.size, scale*gr), ramp(c.size, scale*b)).
+			Check()
+
+		if err != nil {
+			return errors.Wrapf(err, "could not set gamma of CRTC %d", c.id)
+		}
+	}
+
+	return nil
+}
+
+// SetBrightness handles pipanel brightness events by scaling the gamma ramps.
+func (g *Gamma) SetBrightness(ctx context.Context,
+	e pipanel.BrightnessEvent) error {
+
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	g.log.WithContext(ctx).WithField("brightness", e.Level).
+		Debugln("Setting gamma brightness.")
+
+	g.level = e.Level
+	return g.apply()
+}
+
+// Brightness returns the brightness level most recently set.
+func (g *Gamma) Brightness(ctx context.Context) (uint8, error) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	return g.level, nil
+}
+
+// SetColorTemperature handles pipanel color temperature events by tinting the
+// gamma ramps.
+func (g *Gamma) SetColorTemperature(ctx context.Context,
+	e pipanel.ColorTemperatureEvent) error {
+
+	if e.Kelvin != 0 && (e.Kelvin < kelvinMin || e.Kelvin > kelvinMax) {
+		return errors.Errorf("color temperature must be on the range [%d,%d]",
+			kelvinMin, kelvinMax)
+	}
+
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	g.log.WithContext(ctx).WithField("kelvin", e.Kelvin).
+		Println("Setting color temperature.")
+
+	g.kelvin = e.Kelvin
+	return g.apply()
+}
+
+// SetDisplayPower handles pipanel display power events using DPMS.
+func (g *Gamma) SetDisplayPower(ctx context.Context,
+	e pipanel.DisplayPowerEvent) error {
+
+	g.log.WithContext(ctx).WithField("state", e.State).
+		Println("Setting display power.")
+
+	return dpms.Set(ctx, g.cfg.Display, e.State)
+}
+
+// findCrtcs finds the CRTCs driving the configured outputs, saving their
+// current gamma ramps.
+func (g *Gamma) findCrtcs() error {
+	root := xproto.Setup(g.conn).DefaultScreen(g.conn).Root
+
+	res, err := randr.GetScreenResourcesCurrent(g.conn, root).Reply()
+	if err != nil {
+		return errors.Wrap(err, "could not get screen resources")
+	}
+
+	wanted := make(map[string]bool)
+	for _, name := range g.cfg.Outputs {
+		wanted[name] = true
+	}
+
+	seen := make(map[randr.Crtc]bool)
+
+	for _, output := range res.Outputs {
+		info, err := randr.GetOutputInfo(g.conn, output, res.ConfigTimestamp).Reply()
+		if err != nil {
+			return errors.Wrap(err, "could not get output info")
+		}
+
+		name := string(info.Name)
+
+		if info.Connection != randr.ConnectionConnected || info.Crtc == 0 ||
+			seen[info.Crtc] || (len(wanted) > 0 && !wanted[name]) {
+			continue
+		}
+
+		seen[info.Crtc] = true
+		delete(wanted, name)
+
+		gamma, err := randr.GetCrtcGamma(g.conn, info.Crtc).Reply()
+		if err != nil {
+			return errors.Wrapf(err, "could not get gamma of output %s", name)
+		} else if gamma.Size < 2 {
+			g.log.WithField("output", name).
+				Warnln("Output does not support gamma ramps; skipping.")
+			continue
+		}
+
+		g.log.WithField("output", name).Println("Controlling output.")
+
+		g.crtcs = append(g.crtcs, crtc{
+			id:    info.Crtc,
+			size:  gamma.Size,
+			red:   gamma.Red,
+			green: gamma.Green,
+			blue:  gamma.Blue,
+		})
+	}
+
+	for name := range wanted {
+		return errors.Errorf("output %s is not connected or does not exist", name)
+	}
+
+	if len(g.crtcs) < 1 {
+		return errors.New("no outputs support gamma ramps")
+	}
+
+	return nil
+}
+
+// decodeConfig decodes the raw JSON configuration.
+func decodeConfig(rawCfg json.RawMessage) (Config, error) {
+	var cfg Config
+
+	if len(rawCfg) > 0 {
+		d := json.NewDecoder(bytes.NewReader(rawCfg))
+		d.DisallowUnknownFields()
+
+		if err := d.Decode(&cfg); err != nil {
+			return cfg, errors.Wrap(err, "malformed JSON for Gamma configuration")
+		}
+	}
+
+	return cfg, nil
+}
+
+// ConfigTemplate returns a pointer to a zero Config.
+func (g *Gamma) ConfigTemplate() interface{} { return &Config{} }
+
+// ValidateConfig decodes the raw JSON configuration without applying it.
+func (g *Gamma) ValidateConfig(rawCfg json.RawMessage) error {
+	_, err := decodeConfig(rawCfg)
+	return err
+}
+
+// Init initializes this Gamma, connecting to the X display and finding the
+// outputs to control.
+func (g *Gamma) Init(log *logrus.Entry, rawCfg json.RawMessage) error {
+	g.log = log
+
+	cfg, err := decodeConfig(rawCfg)
+	if err != nil {
+		return err
+	}
+
+	g.cfg = cfg
+
+	display := dpms.Display(cfg.Display)
+
+	if g.conn, err = xgb.NewConnDisplay(display); err != nil {
+		return errors.Wrapf(err, "could not connect to X display %s", display)
+	}
+
+	if err = randr.Init(g.conn); err != nil {
+		g.conn.Close()
+		return errors.Wrap(err, "X display does not support RandR")
+	}
+
+	if err = g.findCrtcs(); err != nil {
+		g.conn.Close()
+		return err
+	}
+
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	return g.apply()
+}
+
+// Cleanup restores the gamma ramps in place before Init, and disconnects from
+// the X display.
+func (g *Gamma) Cleanup() error {
+	if g.conn == nil {
+		return nil
+	}
+
+	var errs pipanel.ErrorList
+
+	for _, c := range g.crtcs {
+		err := randr.SetCrtcGammaChecked(g.conn, c.id, c.size,
+			c.red, c.green, c.blue).Check()
+
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err,
+				"could not restore gamma of CRTC %d", c.id))
+		}
+	}
+
+	g.conn.Close()
+	g.conn, g.crtcs = nil, nil
+
+	return errs.Err()
+}
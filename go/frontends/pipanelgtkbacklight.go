@@ -0,0 +1,22 @@
+package frontends
+
+import (
+	pipanel "github.com/BenJetson/pipanel/go"
+
+	"github.com/BenJetson/pipanel/go/frontends/alerters/gtkttsalerter"
+	"github.com/BenJetson/pipanel/go/frontends/audio_players/beeper"
+	"github.com/BenJetson/pipanel/go/frontends/display_managers/sysfsbacklight"
+	"github.com/BenJetson/pipanel/go/frontends/power_managers/systemdpwr"
+)
+
+// NewPiPanelGTKBacklight creates a pipanel.Frontend like NewPiPanelGTK, but
+// that supports any display with a sysfs backlight device, such as newer DSI
+// panels and laptop screens.
+func NewPiPanelGTKBacklight() *pipanel.Frontend {
+	return &pipanel.Frontend{
+		Alerter:        gtkttsalerter.New(),
+		AudioPlayer:    beeper.New(),
+		DisplayManager: sysfsbacklight.New(),
+		PowerManager:   systemdpwr.New(),
+	}
+}
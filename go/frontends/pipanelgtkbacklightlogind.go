@@ -0,0 +1,22 @@
+package frontends
+
+import (
+	pipanel "github.com/BenJetson/pipanel/go"
+
+	"github.com/BenJetson/pipanel/go/frontends/alerters/gtkttsalerter"
+	"github.com/BenJetson/pipanel/go/frontends/audio_players/beeper"
+	"github.com/BenJetson/pipanel/go/frontends/display_managers/sysfsbacklight"
+	"github.com/BenJetson/pipanel/go/frontends/power_managers/logind"
+)
+
+// NewPiPanelGTKBacklightLogind creates a pipanel.Frontend like
+// NewPiPanelGTKBacklight, but with logind power management like
+// NewPiPanelGTKLogind.
+func NewPiPanelGTKBacklightLogind() *pipanel.Frontend {
+	return &pipanel.Frontend{
+		Alerter:        gtkttsalerter.New(),
+		AudioPlayer:    beeper.New(),
+		DisplayManager: sysfsbacklight.New(),
+		PowerManager:   logind.New(),
+	}
+}
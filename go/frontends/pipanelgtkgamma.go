@@ -0,0 +1,22 @@
+package frontends
+
+import (
+	pipanel "github.com/BenJetson/pipanel/go"
+
+	"github.com/BenJetson/pipanel/go/frontends/alerters/gtkttsalerter"
+	"github.com/BenJetson/pipanel/go/frontends/audio_players/beeper"
+	"github.com/BenJetson/pipanel/go/frontends/display_managers/xgamma"
+	"github.com/BenJetson/pipanel/go/frontends/power_managers/systemdpwr"
+)
+
+// NewPiPanelGTKGamma creates a pipanel.Frontend like NewPiPanelGTK, but that
+// dims the display through X gamma ramps, for displays without a backlight
+// control such as HDMI monitors.
+func NewPiPanelGTKGamma() *pipanel.Frontend {
+	return &pipanel.Frontend{
+		Alerter:        gtkttsalerter.New(),
+		AudioPlayer:    beeper.New(),
+		DisplayManager: xgamma.New(),
+		PowerManager:   systemdpwr.New(),
+	}
+}
@@ -0,0 +1,21 @@
+package frontends
+
+import (
+	pipanel "github.com/BenJetson/pipanel/go"
+
+	"github.com/BenJetson/pipanel/go/frontends/alerters/gtkttsalerter"
+	"github.com/BenJetson/pipanel/go/frontends/audio_players/beeper"
+	"github.com/BenJetson/pipanel/go/frontends/display_managers/xgamma"
+	"github.com/BenJetson/pipanel/go/frontends/power_managers/logind"
+)
+
+// NewPiPanelGTKGammaLogind creates a pipanel.Frontend like NewPiPanelGTKGamma,
+// but with logind power management like NewPiPanelGTKLogind.
+func NewPiPanelGTKGammaLogind() *pipanel.Frontend {
+	return &pipanel.Frontend{
+		Alerter:        gtkttsalerter.New(),
+		AudioPlayer:    beeper.New(),
+		DisplayManager: xgamma.New(),
+		PowerManager:   logind.New(),
+	}
+}
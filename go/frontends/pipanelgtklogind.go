@@ -0,0 +1,22 @@
+package frontends
+
+import (
+	pipanel "github.com/BenJetson/pipanel/go"
+
+	"github.com/BenJetson/pipanel/go/frontends/alerters/gtkttsalerter"
+	"github.com/BenJetson/pipanel/go/frontends/audio_players/beeper"
+	"github.com/BenJetson/pipanel/go/frontends/display_managers/pitouch"
+	"github.com/BenJetson/pipanel/go/frontends/power_managers/logind"
+)
+
+// NewPiPanelGTKLogind creates a pipanel.Frontend like NewPiPanelGTK, but that
+// asks systemd-logind to perform power actions over D-Bus, authorized by
+// polkit rather than sudo.
+func NewPiPanelGTKLogind() *pipanel.Frontend {
+	return &pipanel.Frontend{
+		Alerter:        gtkttsalerter.New(),
+		AudioPlayer:    beeper.New(),
+		DisplayManager: pitouch.New(),
+		PowerManager:   logind.New(),
+	}
+}
@@ -0,0 +1,22 @@
+package frontends
+
+import (
+	pipanel "github.com/BenJetson/pipanel/go"
+
+	"github.com/BenJetson/pipanel/go/frontends/alerters/gtkttsalerter"
+	"github.com/BenJetson/pipanel/go/frontends/audio_players/beeper"
+	"github.com/BenJetson/pipanel/go/frontends/display_managers/pitouch"
+	"github.com/BenJetson/pipanel/go/frontends/power_managers/powerd"
+)
+
+// NewPiPanelGTKPowerd creates a pipanel.Frontend that supports the RPi
+// official touch display, includes GTK/TTS alerts, and leaves power management
+// to pipanel-powerd so that the panel can run unprivileged.
+func NewPiPanelGTKPowerd() *pipanel.Frontend {
+	return &pipanel.Frontend{
+		Alerter:        gtkttsalerter.New(),
+		AudioPlayer:    beeper.New(),
+		DisplayManager: pitouch.New(),
+		PowerManager:   powerd.New(),
+	}
+}
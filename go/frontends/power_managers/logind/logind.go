@@ -0,0 +1,329 @@
+// Package logind provides a PowerManager that asks systemd-logind to power off,
+// reboot, suspend or hibernate the system over D-Bus, with authorization by
+// polkit rather than sudo. The PiPanel service is restarted or reloaded by
+// asking systemd the same way.
+package logind
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+	"github.com/BenJetson/pipanel/go/logfmt"
+)
+
+var _ pipanel.PowerManager = (*Logind)(nil)
+var _ pipanel.PowerCapabilityReporter = (*Logind)(nil)
+var _ pipanel.ConfigDescriber = (*Logind)(nil)
+
+const (
+	logindDest = "org.freedesktop.login1"
+	logindPath = "/org/freedesktop/login1"
+	managerIfc = "org.freedesktop.login1.Manager"
+
+	systemdDest = "org.freedesktop.systemd1"
+	systemdPath = "/org/freedesktop/systemd1"
+	systemdIfc  = "org.freedesktop.systemd1.Manager"
+	unitIfc     = "org.freedesktop.systemd1.Unit"
+
+	unitDefault = "pipanel.service"
+)
+
+// methods maps each power action to the name of the login1 Manager method that
+// performs it. Each has a counterpart prefixed with "Can" that reports whether
+// it is permitted.
+var methods = map[pipanel.PowerAction]string{
+	pipanel.PowerActionShutdown:  "PowerOff",
+	pipanel.PowerActionReboot:    "Reboot",
+	pipanel.PowerActionSuspend:   "Suspend",
+	pipanel.PowerActionHibernate: "Hibernate",
+}
+
+// unitMethods maps each service action to the name of the systemd Manager
+// method that performs it on the unit.
+var unitMethods = map[pipanel.PowerAction]string{
+	pipanel.PowerActionRestartService: "RestartUnit",
+	pipanel.PowerActionReloadConfig:   "ReloadUnit",
+}
+
+// actions lists the keys of methods in order.
+var actions = []pipanel.PowerAction{
+	pipanel.PowerActionShutdown,
+	pipanel.PowerActionReboot,
+	pipanel.PowerActionSuspend,
+	pipanel.PowerActionHibernate,
+}
+
+// Config specifies the options that modify the behavior of Logind.
+type Config struct {
+	// BusAddress is the address of the D-Bus bus on which logind is found,
+	// such as "unix:path=/tmp/test-bus".
+	//
+	// Defaults to the system bus if not set.
+	BusAddress string `json:"bus_address"`
+	// Interactive allows polkit to ask the user for authentication, should
+	// the action require it.
+	//
+	// Defaults to false if not set.
+	Interactive bool `json:"interactive"`
+	// Unit is the systemd unit that runs PiPanel, which is restarted or
+	// reloaded by the restartService and reloadConfig actions. Reloading
+	// requires the unit to have an ExecReload setting that sends SIGHUP, such
+	// as "/bin/kill -HUP $MAINPID".
+	//
+	// Defaults to "pipanel.service" if not set.
+	Unit string `json:"unit"`
+}
+
+// fillDefaults will overwrite zero values with the default configuration.
+func (cfg *Config) fillDefaults() {
+	if len(cfg.Unit) < 1 {
+		cfg.Unit = unitDefault
+	}
+}
+
+// Logind implements pipanel.PowerManager using the login1 D-Bus interface of
+// systemd-logind.
+type Logind struct {
+	log *logrus.Entry
+	cfg Config
+
+	// mux guards conn, which is connected upon first use so that the panel can
+	// start before the bus is available.
+	mux  sync.Mutex
+	conn *dbus.Conn
+}
+
+// New creates a Logind instance.
+func New() *Logind { return &Logind{} }
+
+// manager returns the login1 Manager object, connecting to the bus if needed.
+func (l *Logind) manager() (dbus.BusObject, error) {
+	return l.object(logindDest, logindPath)
+}
+
+// object returns an object on the bus, connecting to the bus if needed.
+func (l *Logind) object(dest string, path dbus.ObjectPath) (dbus.BusObject, error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if l.conn == nil || !l.conn.Connected() {
+		var conn *dbus.Conn
+		var err error
+
+		if len(l.cfg.BusAddress) > 0 {
+			conn, err = dbus.Connect(l.cfg.BusAddress)
+		} else {
+			conn, err = dbus.ConnectSystemBus()
+		}
+
+		if err != nil {
+			return nil, errors.Wrap(err, "could not connect to D-Bus")
+		}
+
+		l.conn = conn
+	}
+
+	return l.conn.Object(dest, path), nil
+}
+
+// DoPowerAction handles pipanel power events by calling logind, or systemd for
+// the service actions.
+func (l *Logind) DoPowerAction(ctx context.Context, e pipanel.PowerEvent) error {
+	if method, ok := unitMethods[e.Action]; ok {
+		return l.doUnitAction(ctx, e.Action, method)
+	}
+
+	method, ok := methods[e.Action]
+	if !ok {
+		return pipanel.Unsupported("power action '%s'", e.Action)
+	}
+
+	obj, err := l.manager()
+	if err != nil {
+		return err
+	}
+
+	// Ask first, so that a refusal is reported plainly rather than as an
+	// access denied error.
+	can, err := canCall(ctx, obj, method)
+	if err != nil {
+		return err
+	}
+
+	switch can {
+	case "na":
+		return pipanel.Unsupported("%s on this system", method)
+	case "no":
+		return errors.Errorf("%s is not permitted by polkit", method)
+	case "challenge":
+		if !l.cfg.Interactive {
+			return errors.Errorf("%s requires authentication, "+
+				"but interactive authentication is disabled", method)
+		}
+	}
+
+	l.log.WithContext(ctx).WithField("action", e.Action).
+		Printf("Asking logind to %s.", method)
+
+	err = obj.CallWithContext(ctx, managerIfc+"."+method, 0, l.cfg.Interactive).Err
+	return errors.Wrapf(err, "logind %s failed", method)
+}
+
+// doUnitAction asks systemd to restart or reload the PiPanel unit. Polkit
+// decides whether this is permitted, so it is not known beforehand.
+func (l *Logind) doUnitAction(ctx context.Context, action pipanel.PowerAction,
+	method string) error {
+
+	obj, err := l.object(systemdDest, systemdPath)
+	if err != nil {
+		return err
+	}
+
+	var flags dbus.Flags
+	if l.cfg.Interactive {
+		flags = dbus.FlagAllowInteractiveAuthorization
+	}
+
+	l.log.WithContext(ctx).WithFields(logrus.Fields{
+		"action": action,
+		"unit":   l.cfg.Unit,
+	}).Printf("Asking systemd to %s.", method)
+
+	// Restarting stops this process, so do not wait for the job; the call
+	// returns once it is queued.
+	err = obj.CallWithContext(ctx, systemdIfc+"."+method, flags,
+		l.cfg.Unit, "replace").Err
+	return errors.Wrapf(err, "systemd %s of %s failed", method, l.cfg.Unit)
+}
+
+// canReload asks systemd whether the PiPanel unit can be reloaded.
+func (l *Logind) canReload(ctx context.Context) (bool, error) {
+	obj, err := l.object(systemdDest, systemdPath)
+	if err != nil {
+		return false, err
+	}
+
+	var path dbus.ObjectPath
+	err = obj.CallWithContext(ctx, systemdIfc+".GetUnit", 0, l.cfg.Unit).Store(&path)
+	if err != nil {
+		return false, errors.Wrapf(err, "could not find unit %s", l.cfg.Unit)
+	}
+
+	unit, err := l.object(systemdDest, path)
+	if err != nil {
+		return false, err
+	}
+
+	var canReload bool
+	err = unit.CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0,
+		unitIfc, "CanReload").Store(&canReload)
+	return canReload, errors.Wrapf(err,
+		"could not ask systemd whether %s can be reloaded", l.cfg.Unit)
+}
+
+// PowerCapabilities returns the power actions that logind reports as
+// permitted. Actions that require authentication are only included if
+// interactive authentication is enabled. The service actions are included
+// too, though reloadConfig only if systemd reports that the unit can be
+// reloaded.
+func (l *Logind) PowerCapabilities(ctx context.Context) ([]pipanel.PowerAction, error) {
+	obj, err := l.manager()
+	if err != nil {
+		return nil, err
+	}
+
+	var supported []pipanel.PowerAction
+
+	for _, a := range actions {
+		can, err := canCall(ctx, obj, methods[a])
+		if err != nil {
+			return nil, err
+		}
+
+		if can == "yes" || (can == "challenge" && l.cfg.Interactive) {
+			supported = append(supported, a)
+		}
+	}
+
+	supported = append(supported, pipanel.PowerActionRestartService)
+
+	if canReload, err := l.canReload(ctx); err != nil {
+		logfmt.WithError(l.log, err).WithContext(ctx).WithField("unit", l.cfg.Unit).
+			Warnln("Could not ask systemd whether the unit can be reloaded.")
+	} else if canReload {
+		supported = append(supported, pipanel.PowerActionReloadConfig)
+	}
+
+	return supported, nil
+}
+
+// canCall asks logind whether the method is permitted, returning its answer:
+// "yes", "no", "challenge" or "na".
+func canCall(ctx context.Context, obj dbus.BusObject, method string) (string, error) {
+	var can string
+	err := obj.CallWithContext(ctx, managerIfc+".Can"+method, 0).Store(&can)
+	return can, errors.Wrapf(err, "could not ask logind whether %s is permitted", method)
+}
+
+// decodeConfig decodes the raw JSON configuration, filling in defaults.
+func decodeConfig(rawCfg json.RawMessage) (Config, error) {
+	var cfg Config
+
+	if len(rawCfg) > 0 {
+		d := json.NewDecoder(bytes.NewReader(rawCfg))
+		d.DisallowUnknownFields()
+
+		if err := d.Decode(&cfg); err != nil {
+			return cfg, errors.Wrap(err, "malformed JSON for Logind configuration")
+		}
+	}
+
+	cfg.fillDefaults()
+
+	return cfg, nil
+}
+
+// ConfigTemplate returns a pointer to a zero Config.
+func (l *Logind) ConfigTemplate() interface{} { return &Config{} }
+
+// ValidateConfig decodes the raw JSON configuration without applying it.
+func (l *Logind) ValidateConfig(rawCfg json.RawMessage) error {
+	_, err := decodeConfig(rawCfg)
+	return err
+}
+
+// Init initializes this Logind. The bus is not connected to until the first
+// power action.
+func (l *Logind) Init(log *logrus.Entry, rawCfg json.RawMessage) error {
+	l.log = log
+
+	cfg, err := decodeConfig(rawCfg)
+	if err != nil {
+		return err
+	}
+
+	l.cfg = cfg
+	return nil
+}
+
+// Cleanup tears down this Logind, disconnecting from the bus.
+func (l *Logind) Cleanup() error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if l.conn == nil {
+		return nil
+	}
+
+	err := l.conn.Close()
+	l.conn = nil
+
+	return errors.Wrap(err, "could not disconnect from D-Bus")
+}
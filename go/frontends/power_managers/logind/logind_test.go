@@ -0,0 +1,377 @@
+package logind
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/sirupsen/logrus"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+)
+
+// busConfig is the configuration of a private dbus-daemon that listens on a
+// socket in the given directory and allows anything.
+const busConfig = `<!DOCTYPE busconfig PUBLIC
+ "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:path=%s/bus</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*"/>
+    <allow receive_sender="*"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// startBus starts a private dbus-daemon, returning its address and a function
+// that stops it. The test is skipped if dbus-daemon is not installed.
+func startBus(t *testing.T) (string, func()) {
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon unavailable:", err)
+	}
+
+	dir, err := ioutil.TempDir("", "logind-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfgPath := filepath.Join(dir, "bus.conf")
+	err = ioutil.WriteFile(cfgPath, []byte(fmt.Sprintf(busConfig, dir)), 0600)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(daemon, "--config-file="+cfgPath, "--nofork",
+		"--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	if err = cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	stop := func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+		os.RemoveAll(dir)
+	}
+
+	addr, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		stop()
+		t.Fatal("could not read the bus address:", err)
+	}
+
+	return strings.TrimSpace(addr), stop
+}
+
+// unitPath is where the mock systemd serves the PiPanel unit.
+const unitPath = "/org/freedesktop/systemd1/unit/pipanel_2eservice"
+
+// mockLogind serves the parts of the login1 Manager and systemd1 Manager
+// interfaces used by Logind, answering each Can method from can and recording
+// the calls made. The PiPanel unit is loaded if it can be reloaded.
+type mockLogind struct {
+	mux       sync.Mutex
+	can       map[string]string
+	canReload bool
+	calls     []string
+}
+
+func (m *mockLogind) answer(method string) (string, *dbus.Error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if can, ok := m.can[method]; ok {
+		return can, nil
+	}
+	return "na", nil
+}
+
+func (m *mockLogind) record(method string, interactive bool) *dbus.Error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.calls = append(m.calls, fmt.Sprintf("%s(%t)", method, interactive))
+	return nil
+}
+
+func (m *mockLogind) CanPowerOff() (string, *dbus.Error)  { return m.answer("PowerOff") }
+func (m *mockLogind) CanReboot() (string, *dbus.Error)    { return m.answer("Reboot") }
+func (m *mockLogind) CanSuspend() (string, *dbus.Error)   { return m.answer("Suspend") }
+func (m *mockLogind) CanHibernate() (string, *dbus.Error) { return m.answer("Hibernate") }
+
+func (m *mockLogind) PowerOff(i bool) *dbus.Error  { return m.record("PowerOff", i) }
+func (m *mockLogind) Reboot(i bool) *dbus.Error    { return m.record("Reboot", i) }
+func (m *mockLogind) Suspend(i bool) *dbus.Error   { return m.record("Suspend", i) }
+func (m *mockLogind) Hibernate(i bool) *dbus.Error { return m.record("Hibernate", i) }
+
+// mockSystemd serves the systemd1 Manager interface of a mockLogind.
+type mockSystemd struct{ m *mockLogind }
+
+func (s mockSystemd) recordUnit(method, name, mode string) (dbus.ObjectPath, *dbus.Error) {
+	s.m.mux.Lock()
+	defer s.m.mux.Unlock()
+
+	s.m.calls = append(s.m.calls, fmt.Sprintf("%s(%s, %s)", method, name, mode))
+	return "/org/freedesktop/systemd1/job/1", nil
+}
+
+func (s mockSystemd) RestartUnit(name, mode string) (dbus.ObjectPath, *dbus.Error) {
+	return s.recordUnit("RestartUnit", name, mode)
+}
+
+func (s mockSystemd) ReloadUnit(name, mode string) (dbus.ObjectPath, *dbus.Error) {
+	return s.recordUnit("ReloadUnit", name, mode)
+}
+
+func (s mockSystemd) GetUnit(name string) (dbus.ObjectPath, *dbus.Error) {
+	s.m.mux.Lock()
+	defer s.m.mux.Unlock()
+
+	if name != unitDefault || !s.m.canReload {
+		return "", dbus.NewError("org.freedesktop.systemd1.NoSuchUnit",
+			[]interface{}{"Unit " + name + " not loaded."})
+	}
+	return unitPath, nil
+}
+
+// mockUnit serves the properties of the PiPanel unit.
+type mockUnit struct{}
+
+func (u mockUnit) Get(ifc, property string) (dbus.Variant, *dbus.Error) {
+	if ifc != unitIfc || property != "CanReload" {
+		return dbus.Variant{}, dbus.MakeFailedError(
+			fmt.Errorf("unknown property %s.%s", ifc, property))
+	}
+	return dbus.MakeVariant(true), nil
+}
+
+// serveMock connects to the bus at addr and exports m as login1 and systemd1.
+func serveMock(t *testing.T, addr string, m *mockLogind) *dbus.Conn {
+	conn, err := dbus.Connect(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exports := []struct {
+		v    interface{}
+		path dbus.ObjectPath
+		ifc  string
+	}{
+		{m, logindPath, managerIfc},
+		{mockSystemd{m}, systemdPath, systemdIfc},
+		{mockUnit{}, unitPath, "org.freedesktop.DBus.Properties"},
+	}
+
+	for _, e := range exports {
+		if err = conn.Export(e.v, e.path, e.ifc); err != nil {
+			conn.Close()
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{logindDest, systemdDest} {
+		reply, err := conn.RequestName(name, dbus.NameFlagDoNotQueue)
+		if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+			conn.Close()
+			t.Fatalf("could not own %s: %v (reply %d)", name, err, reply)
+		}
+	}
+
+	return conn
+}
+
+// newLogind creates an initialized Logind that uses the bus at addr.
+func newLogind(t *testing.T, addr string, interactive bool) *Logind {
+	l := New()
+
+	rawCfg := fmt.Sprintf(`{"bus_address": %q, "interactive": %t}`,
+		addr, interactive)
+	if err := l.Init(logrus.NewEntry(logrus.New()), []byte(rawCfg)); err != nil {
+		t.Fatal(err)
+	}
+
+	return l
+}
+
+func TestDoPowerAction(t *testing.T) {
+	addr, stop := startBus(t)
+	defer stop()
+
+	tests := []struct {
+		name            string
+		can             map[string]string
+		interactive     bool
+		action          pipanel.PowerAction
+		wantCalls       []string
+		wantErr         bool
+		wantUnsupported bool
+	}{
+		{
+			name:      "permitted",
+			can:       map[string]string{"PowerOff": "yes"},
+			action:    pipanel.PowerActionShutdown,
+			wantCalls: []string{"PowerOff(false)"},
+		},
+		{
+			name:        "permitted interactively",
+			can:         map[string]string{"Reboot": "challenge"},
+			interactive: true,
+			action:      pipanel.PowerActionReboot,
+			wantCalls:   []string{"Reboot(true)"},
+		},
+		{
+			name:    "requires authentication",
+			can:     map[string]string{"Suspend": "challenge"},
+			action:  pipanel.PowerActionSuspend,
+			wantErr: true,
+		},
+		{
+			name:    "not permitted",
+			can:     map[string]string{"Hibernate": "no"},
+			action:  pipanel.PowerActionHibernate,
+			wantErr: true,
+		},
+		{
+			name:            "not supported by the system",
+			can:             map[string]string{"PowerOff": "na"},
+			action:          pipanel.PowerActionShutdown,
+			wantErr:         true,
+			wantUnsupported: true,
+		},
+		{
+			name:            "unknown action",
+			can:             map[string]string{"PowerOff": "yes"},
+			action:          pipanel.PowerAction("explode"),
+			wantErr:         true,
+			wantUnsupported: true,
+		},
+		{
+			name:      "restart the service",
+			action:    pipanel.PowerActionRestartService,
+			wantCalls: []string{"RestartUnit(pipanel.service, replace)"},
+		},
+		{
+			name:      "reload the service",
+			action:    pipanel.PowerActionReloadConfig,
+			wantCalls: []string{"ReloadUnit(pipanel.service, replace)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockLogind{can: tt.can}
+			conn := serveMock(t, addr, m)
+			defer conn.Close()
+
+			l := newLogind(t, addr, tt.interactive)
+			defer l.Cleanup()
+
+			err := l.DoPowerAction(context.Background(),
+				pipanel.PowerEvent{Action: tt.action})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				if pipanel.IsUnsupported(err) != tt.wantUnsupported {
+					t.Errorf("IsUnsupported(%v) = %t, want %t",
+						err, !tt.wantUnsupported, tt.wantUnsupported)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			m.mux.Lock()
+			defer m.mux.Unlock()
+
+			if !reflect.DeepEqual(m.calls, tt.wantCalls) {
+				t.Errorf("got calls %v, want %v", m.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestPowerCapabilities(t *testing.T) {
+	addr, stop := startBus(t)
+	defer stop()
+
+	can := map[string]string{
+		"PowerOff":  "yes",
+		"Reboot":    "challenge",
+		"Suspend":   "no",
+		"Hibernate": "na",
+	}
+
+	tests := []struct {
+		name        string
+		interactive bool
+		canReload   bool
+		want        []pipanel.PowerAction
+	}{
+		{
+			name: "non-interactive",
+			want: []pipanel.PowerAction{
+				pipanel.PowerActionShutdown,
+				pipanel.PowerActionRestartService,
+			},
+		},
+		{
+			name:        "interactive",
+			interactive: true,
+			want: []pipanel.PowerAction{
+				pipanel.PowerActionShutdown,
+				pipanel.PowerActionReboot,
+				pipanel.PowerActionRestartService,
+			},
+		},
+		{
+			name:      "reloadable unit",
+			canReload: true,
+			want: []pipanel.PowerAction{
+				pipanel.PowerActionShutdown,
+				pipanel.PowerActionRestartService,
+				pipanel.PowerActionReloadConfig,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockLogind{can: can, canReload: tt.canReload}
+			conn := serveMock(t, addr, m)
+			defer conn.Close()
+
+			l := newLogind(t, addr, tt.interactive)
+			defer l.Cleanup()
+
+			got, err := l.PowerCapabilities(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
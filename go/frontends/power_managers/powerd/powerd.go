@@ -0,0 +1,170 @@
+// Package powerd provides a PowerManager that asks pipanel-powerd, a small
+// helper running as root, to perform power actions over a Unix socket. This
+// allows the panel itself to run without root privileges.
+package powerd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+)
+
+var _ pipanel.PowerManager = (*Powerd)(nil)
+var _ pipanel.PowerCapabilityReporter = (*Powerd)(nil)
+var _ pipanel.ConfigDescriber = (*Powerd)(nil)
+
+const timeoutDefault = pipanel.Duration(30 * time.Second)
+
+// Config specifies the options that modify the behavior of Powerd.
+type Config struct {
+	// Socket is the path of the Unix socket that pipanel-powerd listens on.
+	//
+	// Defaults to "/run/pipanel/powerd.sock" if not set.
+	Socket string `json:"socket"`
+	// Timeout is how long to wait for pipanel-powerd to answer a request that
+	// has no deadline of its own. The action may still be performed after
+	// the wait is given up on.
+	//
+	// Defaults to 30 seconds if not set.
+	Timeout pipanel.Duration `json:"timeout"`
+}
+
+// fillDefaults will overwrite zero values with the default configuration.
+func (cfg *Config) fillDefaults() {
+	if len(cfg.Socket) < 1 {
+		cfg.Socket = SocketDefault
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = timeoutDefault
+	}
+}
+
+// validate checks that the configuration values are sensible.
+func (cfg *Config) validate() error {
+	if cfg.Timeout < 0 {
+		return errors.New("timeout cannot be negative")
+	}
+
+	return nil
+}
+
+// Powerd implements pipanel.PowerManager by sending power actions to
+// pipanel-powerd.
+type Powerd struct {
+	log *logrus.Entry
+	cfg Config
+}
+
+// New creates a Powerd instance.
+func New() *Powerd { return &Powerd{} }
+
+// call sends the request to pipanel-powerd and returns its response. A
+// response that reports an error is returned as an error. Should ctx have no
+// deadline, the configured timeout applies, so that a stuck pipanel-powerd
+// cannot hang the caller.
+func (p *Powerd) call(ctx context.Context, req Request) (Response, error) {
+	var res Response
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(p.cfg.Timeout))
+		defer cancel()
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", p.cfg.Socket)
+	if err != nil {
+		return res, errors.Wrap(err, "could not connect to pipanel-powerd")
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	if err = conn.SetDeadline(deadline); err != nil {
+		return res, errors.Wrap(err, "could not set deadline for pipanel-powerd")
+	}
+
+	if err = json.NewEncoder(conn).Encode(req); err != nil {
+		return res, errors.Wrap(err, "could not send request to pipanel-powerd")
+	}
+
+	if err = json.NewDecoder(conn).Decode(&res); err != nil {
+		return res, errors.Wrap(err, "could not read response from pipanel-powerd")
+	}
+
+	if res.Unsupported {
+		return res, pipanel.Unsupported("power action '%s' via pipanel-powerd",
+			req.Action)
+	} else if len(res.Error) > 0 {
+		return res, errors.Errorf("pipanel-powerd: %s", res.Error)
+	}
+
+	return res, nil
+}
+
+// DoPowerAction handles pipanel power events by sending them to
+// pipanel-powerd.
+func (p *Powerd) DoPowerAction(ctx context.Context, e pipanel.PowerEvent) error {
+	p.log.WithContext(ctx).WithField("action", e.Action).
+		Println("Asking pipanel-powerd to perform power action.")
+
+	_, err := p.call(ctx, Request{Action: e.Action, Reason: e.Reason})
+	return err
+}
+
+// PowerCapabilities returns the power actions that pipanel-powerd allows.
+func (p *Powerd) PowerCapabilities(ctx context.Context) ([]pipanel.PowerAction, error) {
+	res, err := p.call(ctx, Request{Capabilities: true})
+	return res.Actions, err
+}
+
+// decodeConfig decodes the raw JSON configuration, filling in defaults.
+func decodeConfig(rawCfg json.RawMessage) (Config, error) {
+	var cfg Config
+
+	if len(rawCfg) > 0 {
+		d := json.NewDecoder(bytes.NewReader(rawCfg))
+		d.DisallowUnknownFields()
+
+		if err := d.Decode(&cfg); err != nil {
+			return cfg, errors.Wrap(err, "malformed JSON for Powerd configuration")
+		}
+	}
+
+	cfg.fillDefaults()
+
+	return cfg, cfg.validate()
+}
+
+// ConfigTemplate returns a pointer to a zero Config.
+func (p *Powerd) ConfigTemplate() interface{} { return &Config{} }
+
+// ValidateConfig decodes and validates the raw JSON configuration without
+// applying it.
+func (p *Powerd) ValidateConfig(rawCfg json.RawMessage) error {
+	_, err := decodeConfig(rawCfg)
+	return err
+}
+
+// Init initializes this Powerd. The socket is connected to for each power
+// action, so pipanel-powerd need not be running yet.
+func (p *Powerd) Init(log *logrus.Entry, rawCfg json.RawMessage) error {
+	p.log = log
+
+	cfg, err := decodeConfig(rawCfg)
+	if err != nil {
+		return err
+	}
+
+	p.cfg = cfg
+	return nil
+}
+
+// Cleanup tears down this Powerd.
+func (p *Powerd) Cleanup() error { return nil }
@@ -0,0 +1,127 @@
+package powerd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	pipanel "github.com/BenJetson/pipanel/go"
+)
+
+func TestCallTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "powerd-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Accept connections, but never answer them.
+	socket := filepath.Join(dir, "powerd.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	tests := []struct {
+		name     string
+		cfg      string
+		deadline time.Duration
+	}{
+		{
+			name: "configured timeout without a deadline",
+			cfg:  fmt.Sprintf(`{"socket": %q, "timeout": "50ms"}`, socket),
+		},
+		{
+			name:     "deadline of the context",
+			cfg:      fmt.Sprintf(`{"socket": %q, "timeout": "1h"}`, socket),
+			deadline: 50 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			if err := p.Init(logrus.NewEntry(logrus.New()), []byte(tt.cfg)); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := context.Background()
+			if tt.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.deadline)
+				defer cancel()
+			}
+
+			start := time.Now()
+			err := p.DoPowerAction(ctx, pipanel.PowerEvent{
+				Action: pipanel.PowerActionShutdown,
+			})
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("gave up after %s, want within a second", elapsed)
+			}
+		})
+	}
+}
+
+func TestDecodeConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		rawCfg  string
+		want    Config
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: Config{Socket: SocketDefault, Timeout: timeoutDefault},
+		},
+		{
+			name:   "timeout given",
+			rawCfg: `{"timeout": "5s"}`,
+			want: Config{
+				Socket:  SocketDefault,
+				Timeout: pipanel.Duration(5 * time.Second),
+			},
+		},
+		{name: "negative timeout", rawCfg: `{"timeout": "-5s"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeConfig([]byte(tt.rawCfg))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
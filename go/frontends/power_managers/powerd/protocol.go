@@ -0,0 +1,32 @@
+package powerd
+
+import (
+	pipanel "github.com/BenJetson/pipanel/go"
+)
+
+// SocketDefault is the path of the Unix socket that pipanel-powerd listens on
+// unless told otherwise.
+const SocketDefault = "/run/pipanel/powerd.sock"
+
+// A Request is sent to pipanel-powerd as a single JSON object, which it
+// answers with a single Response before closing the connection.
+type Request struct {
+	// Action is the power action to perform.
+	Action pipanel.PowerAction `json:"action,omitempty"`
+	// Reason explains why the action was requested, for the audit log.
+	Reason string `json:"reason,omitempty"`
+	// Capabilities asks for the actions that may be performed instead.
+	Capabilities bool `json:"capabilities,omitempty"`
+}
+
+// A Response is the answer of pipanel-powerd to a Request.
+type Response struct {
+	// Error describes why the request failed. Empty on success.
+	Error string `json:"error,omitempty"`
+	// Unsupported is true if the request failed because the action is not
+	// one that may be performed.
+	Unsupported bool `json:"unsupported,omitempty"`
+	// Actions are the power actions that may be performed, in answer to a
+	// capabilities request.
+	Actions []pipanel.PowerAction `json:"actions,omitempty"`
+}
@@ -10,6 +10,17 @@ import (
 )
 
 var _ pipanel.PowerManager = (*PowerLog)(nil)
+var _ pipanel.PowerCapabilityReporter = (*PowerLog)(nil)
+
+// actions are the power actions that PowerLog pretends to perform.
+var actions = []pipanel.PowerAction{
+	pipanel.PowerActionShutdown,
+	pipanel.PowerActionReboot,
+	pipanel.PowerActionSuspend,
+	pipanel.PowerActionHibernate,
+	pipanel.PowerActionRestartService,
+	pipanel.PowerActionReloadConfig,
+}
 
 // PowerLog implements pipanel.PowerManager and handles power events by writing
 // the details to the console. Useful for testing purposes.
@@ -21,19 +32,35 @@ type PowerLog struct {
 func New() *PowerLog { return &PowerLog{} }
 
 // DoPowerAction handles power events by writing the details to the console.
+// Unknown actions are rejected as unsupported.
 func (p *PowerLog) DoPowerAction(ctx context.Context, e pipanel.PowerEvent) error {
+	known := false
+	for _, a := range actions {
+		known = known || a == e.Action
+	}
+
+	if !known {
+		return pipanel.Unsupported("power action '%s'", e.Action)
+	}
+
 	p.log.WithContext(ctx).WithFields(logrus.Fields{
 		"action": e.Action,
+		"reason": e.Reason,
 	}).Println("Received power action event.")
 
 	return nil
 }
 
+// PowerCapabilities returns every power action known to the PowerManager.
+func (p *PowerLog) PowerCapabilities(context.Context) ([]pipanel.PowerAction, error) {
+	return append([]pipanel.PowerAction(nil), actions...), nil
+}
+
 // Init initializes this PowerLog by setting the logger.
 func (p *PowerLog) Init(log *logrus.Entry, _ json.RawMessage) error {
 	p.log = log
 	return nil
 }
 
-// Cleanup tears down this DisplayLog.
+// Cleanup tears down this PowerLog.
 func (p *PowerLog) Cleanup() error { return nil }
@@ -1,27 +1,63 @@
 package systemdpwr
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
 	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
 
 	pipanel "github.com/BenJetson/pipanel/go"
+	"github.com/BenJetson/pipanel/go/logfmt"
 
 	"github.com/sirupsen/logrus"
 )
 
 var _ pipanel.PowerManager = (*SystemdPowerManager)(nil)
+var _ pipanel.PowerCapabilityReporter = (*SystemdPowerManager)(nil)
+var _ pipanel.ConfigDescriber = (*SystemdPowerManager)(nil)
+
+const unitDefault = "pipanel.service"
+
+// Config specifies the options that modify the behavior of
+// SystemdPowerManager.
+type Config struct {
+	// Unit is the systemd unit that runs PiPanel, which is restarted or
+	// reloaded by the restartService and reloadConfig actions. Reloading
+	// requires the unit to have an ExecReload setting that sends SIGHUP, such
+	// as "/bin/kill -HUP $MAINPID".
+	//
+	// Defaults to "pipanel.service" if not set.
+	Unit string `json:"unit"`
+}
+
+// fillDefaults will overwrite zero values with the default configuration.
+func (cfg *Config) fillDefaults() {
+	if len(cfg.Unit) < 1 {
+		cfg.Unit = unitDefault
+	}
+}
 
 // SystemdPowerManager handles pipanel power events for systemd-based systems
-// with X display servers.
+// using sudo, which must be allowed without a password. Display power is
+// handled by the DisplayManager.
 type SystemdPowerManager struct {
 	log *logrus.Entry
+	cfg Config
 }
 
 // New creates a SystemdPowerManager instance.
 func New() *SystemdPowerManager { return &SystemdPowerManager{} }
 
+// run runs a command, returning an error that includes its output should it
+// fail.
+func run(ctx context.Context, name string, args ...string) error {
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	return errors.Wrapf(err, "%s failed: %s", name, strings.TrimSpace(string(out)))
+}
+
 // DoPowerAction handles pipanel power events.
 func (s *SystemdPowerManager) DoPowerAction(ctx context.Context,
 	e pipanel.PowerEvent) error {
@@ -29,21 +65,95 @@ func (s *SystemdPowerManager) DoPowerAction(ctx context.Context,
 	switch e.Action {
 	case pipanel.PowerActionShutdown:
 		s.log.WithContext(ctx).Println("Shutting down the system NOW.")
-		return exec.Command("sudo", "shutdown", "now").Run()
+		return run(ctx, "sudo", "shutdown", "now")
 	case pipanel.PowerActionReboot:
 		s.log.WithContext(ctx).Println("Rebooting the system NOW.")
-		return exec.Command("sudo", "reboot", "now").Run()
-	case pipanel.PowerActionDisplayOff:
-		s.log.WithContext(ctx).Println("Turning off the display.")
-		return exec.Command("xset", "dpms force off").Run()
+		return run(ctx, "sudo", "reboot", "now")
+	case pipanel.PowerActionSuspend:
+		s.log.WithContext(ctx).Println("Suspending the system NOW.")
+		return run(ctx, "sudo", "systemctl", "suspend")
+	case pipanel.PowerActionHibernate:
+		s.log.WithContext(ctx).Println("Hibernating the system NOW.")
+		return run(ctx, "sudo", "systemctl", "hibernate")
+	case pipanel.PowerActionRestartService:
+		s.log.WithContext(ctx).WithField("unit", s.cfg.Unit).
+			Println("Restarting the PiPanel service NOW.")
+		// Restarting stops this process, so do not wait for the job.
+		return run(ctx, "sudo", "systemctl", "restart", "--no-block", s.cfg.Unit)
+	case pipanel.PowerActionReloadConfig:
+		s.log.WithContext(ctx).WithField("unit", s.cfg.Unit).
+			Println("Reloading the PiPanel service.")
+		return run(ctx, "sudo", "systemctl", "reload", s.cfg.Unit)
 	}
 
-	return fmt.Errorf("command '%s' is not a known power action", e.Action)
+	return pipanel.Unsupported("power action '%s'", e.Action)
 }
 
-// Init initializes this SystemdPowerManager by setting the logger.
-func (s *SystemdPowerManager) Init(log *logrus.Entry, _ json.RawMessage) error {
+// PowerCapabilities returns the power actions that may be performed. The
+// reloadConfig action is only included if systemd reports that the unit can be
+// reloaded.
+func (s *SystemdPowerManager) PowerCapabilities(
+	ctx context.Context) ([]pipanel.PowerAction, error) {
+
+	actions := []pipanel.PowerAction{
+		pipanel.PowerActionShutdown,
+		pipanel.PowerActionReboot,
+		pipanel.PowerActionSuspend,
+		pipanel.PowerActionHibernate,
+		pipanel.PowerActionRestartService,
+	}
+
+	out, err := exec.CommandContext(ctx, "systemctl", "show",
+		"--property=CanReload", "--value", s.cfg.Unit).Output()
+	if err != nil {
+		logfmt.WithError(s.log, err).WithContext(ctx).WithField("unit", s.cfg.Unit).
+			Warnln("Could not ask systemd whether the unit can be reloaded.")
+	} else if strings.TrimSpace(string(out)) == "yes" {
+		actions = append(actions, pipanel.PowerActionReloadConfig)
+	}
+
+	return actions, nil
+}
+
+// decodeConfig decodes the raw JSON configuration, filling in defaults.
+func decodeConfig(rawCfg json.RawMessage) (Config, error) {
+	var cfg Config
+
+	if len(rawCfg) > 0 {
+		d := json.NewDecoder(bytes.NewReader(rawCfg))
+		d.DisallowUnknownFields()
+
+		if err := d.Decode(&cfg); err != nil {
+			return cfg, errors.Wrap(err,
+				"malformed JSON for SystemdPowerManager configuration")
+		}
+	}
+
+	cfg.fillDefaults()
+
+	return cfg, nil
+}
+
+// ConfigTemplate returns a pointer to a zero Config.
+func (s *SystemdPowerManager) ConfigTemplate() interface{} { return &Config{} }
+
+// ValidateConfig decodes the raw JSON configuration without applying it.
+func (s *SystemdPowerManager) ValidateConfig(rawCfg json.RawMessage) error {
+	_, err := decodeConfig(rawCfg)
+	return err
+}
+
+// Init initializes this SystemdPowerManager by setting the logger and
+// decoding the configuration.
+func (s *SystemdPowerManager) Init(log *logrus.Entry, rawCfg json.RawMessage) error {
 	s.log = log
+
+	cfg, err := decodeConfig(rawCfg)
+	if err != nil {
+		return err
+	}
+
+	s.cfg = cfg
 	return nil
 }
 
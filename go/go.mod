@@ -4,11 +4,14 @@ go 1.12
 
 require (
 	github.com/BenJetson/humantime v0.0.0-20200514023344-f59ec2835a87
+	github.com/BurntSushi/toml v0.3.1
 	github.com/faiface/beep v1.0.2
+	github.com/godbus/dbus/v5 v5.1.0
 	github.com/google/uuid v1.1.1
 	github.com/gotk3/gotk3 v0.0.0-20190620081259-6dcdf9e5c51e
-	github.com/hegedustibor/htgo-tts v0.0.0-20190202120930-874fa9dd16ff
+	github.com/jezek/xgb v1.1.1
 	github.com/pkg/errors v0.8.1
 	github.com/sirupsen/logrus v1.6.0
 	github.com/stretchr/testify v1.3.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
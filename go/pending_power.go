@@ -0,0 +1,295 @@
+package pipanel
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrPowerActionPending is returned when a delayed power action is requested
+// while another is pending and either the pending power policy is
+// PendingPowerReject or the pending action is protected.
+var ErrPowerActionPending = errors.New("another power action is already pending")
+
+// ErrNoPendingPowerAction is returned when cancelling a delayed power action
+// while none is pending.
+var ErrNoPendingPowerAction = errors.New("no power action is pending")
+
+// pendingCancelAction is the label of the alert action that cancels a delayed
+// power action.
+const pendingCancelAction = "Cancel"
+
+// pendingCancelledTimeout is how long the notice that a delayed power action
+// was cancelled stays on-screen.
+const pendingCancelledTimeout = 10 * time.Second
+
+// powerActionWords describes each power action to the user, both as a verb
+// ("the panel will reboot") and as a noun ("the scheduled reboot").
+var powerActionWords = map[PowerAction]struct{ verb, noun string }{
+	PowerActionShutdown:   {"shut down", "shutdown"},
+	PowerActionReboot:     {"reboot", "reboot"},
+	PowerActionSuspend:    {"suspend", "suspend"},
+	PowerActionHibernate:  {"hibernate", "hibernation"},
+	PowerActionDisplayOff: {"turn the display off", "display power off"},
+	PowerActionDisplayOn:  {"turn the display on", "display power on"},
+
+	PowerActionRestartService: {"restart its service", "service restart"},
+	PowerActionReloadConfig:   {"reload its configuration", "configuration reload"},
+}
+
+// A PendingPowerAction describes a delayed power action that has not yet been
+// performed.
+type PendingPowerAction struct {
+	// Action is the power action that will be performed.
+	Action PowerAction `json:"action"`
+	// Reason explains why the action was requested.
+	Reason string `json:"reason,omitempty"`
+	// At is when the action will be performed.
+	At time.Time `json:"at"`
+	// Protected is true if the action may not be replaced by another, whatever
+	// the pending power policy. It may still be cancelled.
+	Protected bool `json:"protected,omitempty"`
+}
+
+// pendingPower is a delayed power action waiting for its timer to fire.
+type pendingPower struct {
+	PendingPowerAction
+	ctx   context.Context
+	timer *time.Timer
+}
+
+// schedulePowerAction schedules a delayed power action and shows a countdown
+// alert for it. Only one action may be pending; the pending power policy
+// decides whether a new action replaces it or is rejected. A protected action
+// is never replaced, and itself replaces any action that is not protected.
+// Actions that the panel does not support are rejected up front, rather than
+// when they are due.
+func (f *Frontend) schedulePowerAction(ctx context.Context, e PowerEvent,
+	protected bool) error {
+
+	if _, ok := powerActionWords[e.Action]; !ok {
+		return Unsupported("delaying power action '%s'", e.Action)
+	}
+
+	log := f.log.WithField(componentLogKey, "PendingPower").WithContext(ctx)
+
+	// Should the capabilities be unknown, schedule the action anyway, since
+	// refusing it could be worse than failing later.
+	if actions, err := f.PowerCapabilities(ctx); err != nil {
+		log.WithError(err).WithField("action", e.Action).
+			Warnln("Could not check whether the power action is supported.")
+	} else if !hasPowerAction(actions, e.Action) {
+		return Unsupported("power action '%s'", e.Action)
+	}
+
+	f.mux.RLock()
+	policy := f.cfg.PendingPower
+	f.mux.RUnlock()
+
+	// The action outlives the request that scheduled it, so it must not
+	// inherit its cancellation; the request ID is kept for logging.
+	p := &pendingPower{
+		PendingPowerAction: PendingPowerAction{
+			Action:    e.Action,
+			Reason:    e.Reason,
+			At:        time.Now().Add(e.Delay),
+			Protected: protected,
+		},
+		ctx: context.WithValue(context.Background(), RequestIDKey,
+			ctx.Value(RequestIDKey)),
+	}
+
+	f.pendingMux.Lock()
+	replaced := f.pending
+	if replaced != nil {
+		if replaced.Protected || (policy == PendingPowerReject && !protected) {
+			f.pendingMux.Unlock()
+			return errors.WithStack(ErrPowerActionPending)
+		}
+
+		replaced.timer.Stop()
+	}
+
+	f.pending = p
+	p.timer = time.AfterFunc(e.Delay, func() { f.performPendingPower(p) })
+	f.pendingMux.Unlock()
+
+	if replaced != nil {
+		log.WithField("action", replaced.Action).
+			Println("Replaced pending power action.")
+	}
+
+	log.WithFields(logrus.Fields{
+		"action": e.Action,
+		"delay":  e.Delay,
+	}).Println("Scheduled power action.")
+
+	f.showPowerCountdown(p, e.Delay)
+
+	return nil
+}
+
+// showPowerCountdown shows an alert counting down to a delayed power action.
+// If the Alerter is an InteractiveAlerter, the alert offers to cancel it.
+func (f *Frontend) showPowerCountdown(p *pendingPower, delay time.Duration) {
+	log := f.log.WithField(componentLogKey, "PendingPower").WithContext(p.ctx)
+
+	msg := fmt.Sprintf("The panel will %s in %s.",
+		powerActionWords[p.Action].verb, spokenDuration(delay))
+	if len(p.Reason) > 0 {
+		msg = p.Reason + "\n\n" + msg
+	}
+
+	a := AlertEvent{
+		Message:  msg,
+		Icon:     "system-shutdown",
+		Timeout:  delay,
+		Priority: PriorityHigh,
+	}
+
+	f.mux.RLock()
+	_, interactive := f.Alerter.(InteractiveAlerter)
+	f.mux.RUnlock()
+
+	if !interactive {
+		if err := f.ShowAlert(p.ctx, a); err != nil {
+			log.WithError(err).Errorln("Problem when showing power countdown.")
+		}
+		return
+	}
+
+	a.Actions = []string{pendingCancelAction}
+
+	dismissed, err := f.ShowInteractiveAlert(p.ctx, a)
+	if err != nil {
+		log.WithError(err).Errorln("Problem when showing power countdown.")
+		return
+	}
+
+	go func() {
+		d := <-dismissed
+		if d.Reason != DismissAction || d.Action != pendingCancelAction {
+			return
+		}
+
+		if f.cancelPendingPower(p) {
+			log.WithField("action", p.Action).
+				Println("Pending power action cancelled by user.")
+		}
+	}()
+}
+
+// performPendingPower performs a delayed power action once its timer fires,
+// unless it has since been cancelled or replaced.
+func (f *Frontend) performPendingPower(p *pendingPower) {
+	f.pendingMux.Lock()
+	if f.pending != p {
+		f.pendingMux.Unlock()
+		return
+	}
+	f.pending = nil
+	f.pendingMux.Unlock()
+
+	log := f.log.WithField(componentLogKey, "PendingPower").WithContext(p.ctx)
+	log.WithField("action", p.Action).Println("Performing delayed power action.")
+
+	err := f.DoPowerAction(p.ctx, PowerEvent{Action: p.Action, Reason: p.Reason})
+	if err != nil {
+		log.WithError(err).Errorln("Problem when performing delayed power action.")
+	}
+}
+
+// cancelPendingPower cancels the given delayed power action, reporting whether
+// it was still pending.
+func (f *Frontend) cancelPendingPower(p *pendingPower) bool {
+	f.pendingMux.Lock()
+	defer f.pendingMux.Unlock()
+
+	if f.pending != p {
+		return false
+	}
+
+	p.timer.Stop()
+	f.pending = nil
+
+	return true
+}
+
+// PendingPowerAction returns the delayed power action that is pending, if any.
+func (f *Frontend) PendingPowerAction() (PendingPowerAction, bool) {
+	f.pendingMux.Lock()
+	defer f.pendingMux.Unlock()
+
+	if f.pending == nil {
+		return PendingPowerAction{}, false
+	}
+
+	return f.pending.PendingPowerAction, true
+}
+
+// CancelPowerAction cancels the delayed power action that is pending, letting
+// the user know with an alert, and returns it. Returns ErrNoPendingPowerAction
+// if none is pending.
+func (f *Frontend) CancelPowerAction(ctx context.Context) (PendingPowerAction, error) {
+	f.pendingMux.Lock()
+	p := f.pending
+	f.pendingMux.Unlock()
+
+	if p == nil || !f.cancelPendingPower(p) {
+		return PendingPowerAction{}, errors.WithStack(ErrNoPendingPowerAction)
+	}
+
+	log := f.log.WithField(componentLogKey, "PendingPower").WithContext(ctx)
+	log.WithField("action", p.Action).Println("Cancelled pending power action.")
+
+	err := f.ShowAlert(ctx, AlertEvent{
+		Message: fmt.Sprintf("The scheduled %s has been cancelled.",
+			powerActionWords[p.Action].noun),
+		Icon:    "system-shutdown",
+		Timeout: pendingCancelledTimeout,
+	})
+	if err != nil {
+		log.WithError(err).Errorln("Problem when showing cancellation notice.")
+	}
+
+	return p.PendingPowerAction, nil
+}
+
+// stopPendingPower cancels any pending power action without notice.
+func (f *Frontend) stopPendingPower() {
+	f.pendingMux.Lock()
+	defer f.pendingMux.Unlock()
+
+	if f.pending != nil {
+		f.pending.timer.Stop()
+		f.pending = nil
+	}
+}
+
+// spokenDuration describes a duration in words suitable for reading aloud,
+// such as "1 minute 30 seconds".
+func spokenDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	minutes, seconds := int(d/time.Minute), int(d%time.Minute/time.Second)
+
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("%d %s", n, unit)
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+
+	switch {
+	case minutes > 0 && seconds > 0:
+		return plural(minutes, "minute") + " " + plural(seconds, "second")
+	case minutes > 0:
+		return plural(minutes, "minute")
+	case seconds > 0:
+		return plural(seconds, "second")
+	}
+
+	return "a moment"
+}
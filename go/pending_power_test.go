@@ -0,0 +1,151 @@
+package pipanel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestSchedulePowerActionCapabilities(t *testing.T) {
+	tests := []struct {
+		name            string
+		action          PowerAction
+		wantUnsupported bool
+	}{
+		{name: "supported", action: PowerActionReboot},
+		{name: "handled by the Frontend", action: PowerActionDisplayOff},
+		{
+			name:            "unsupported by the PowerManager",
+			action:          PowerActionHibernate,
+			wantUnsupported: true,
+		},
+		{
+			name:            "unknown",
+			action:          PowerAction("explode"),
+			wantUnsupported: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pm := &recordingPowerManager{
+				capabilities: []PowerAction{PowerActionShutdown, PowerActionReboot},
+			}
+			f := &Frontend{Alerter: plainAlerter{}, PowerManager: pm}
+
+			if err := f.Init(quietLog(), &FrontendConfig{}); err != nil {
+				t.Fatal(err)
+			}
+			defer f.stopPendingPower()
+
+			err := f.DoPowerAction(context.Background(), PowerEvent{
+				Action: tt.action,
+				Delay:  time.Hour,
+			})
+
+			if tt.wantUnsupported {
+				if !IsUnsupported(err) {
+					t.Fatalf("got error %v, want an UnsupportedError", err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			_, pending := f.PendingPowerAction()
+			if pending == tt.wantUnsupported {
+				t.Errorf("action pending: %t, want %t", pending, !tt.wantUnsupported)
+			}
+		})
+	}
+}
+
+func TestProtectedPowerAction(t *testing.T) {
+	type request struct {
+		action    PowerAction
+		protected bool
+		wantErr   bool
+	}
+
+	tests := []struct {
+		name        string
+		policy      PendingPowerPolicy
+		requests    []request
+		wantPending PowerAction
+	}{
+		{
+			name:   "not replaced under the replace policy",
+			policy: PendingPowerReplace,
+			requests: []request{
+				{action: PowerActionShutdown, protected: true},
+				{action: PowerActionReboot, wantErr: true},
+			},
+			wantPending: PowerActionShutdown,
+		},
+		{
+			name:   "not replaced by another protected action",
+			policy: PendingPowerReplace,
+			requests: []request{
+				{action: PowerActionShutdown, protected: true},
+				{action: PowerActionReboot, protected: true, wantErr: true},
+			},
+			wantPending: PowerActionShutdown,
+		},
+		{
+			name:   "replaces under the reject policy",
+			policy: PendingPowerReject,
+			requests: []request{
+				{action: PowerActionReboot},
+				{action: PowerActionShutdown, protected: true},
+			},
+			wantPending: PowerActionShutdown,
+		},
+		{
+			name:   "unprotected actions still rejected",
+			policy: PendingPowerReject,
+			requests: []request{
+				{action: PowerActionShutdown},
+				{action: PowerActionReboot, wantErr: true},
+			},
+			wantPending: PowerActionShutdown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pm := &recordingPowerManager{
+				capabilities: []PowerAction{PowerActionShutdown, PowerActionReboot},
+			}
+			f := &Frontend{Alerter: plainAlerter{}, PowerManager: pm}
+
+			if err := f.Init(quietLog(), &FrontendConfig{PendingPower: tt.policy}); err != nil {
+				t.Fatal(err)
+			}
+			defer f.stopPendingPower()
+
+			for _, r := range tt.requests {
+				e := PowerEvent{Action: r.action, Delay: time.Hour}
+
+				var err error
+				if r.protected {
+					err = f.DoProtectedPowerAction(context.Background(), e)
+				} else {
+					err = f.DoPowerAction(context.Background(), e)
+				}
+
+				if r.wantErr && errors.Cause(err) != ErrPowerActionPending {
+					t.Errorf("requesting %s: got error %v, want %v",
+						r.action, err, ErrPowerActionPending)
+				} else if !r.wantErr && err != nil {
+					t.Errorf("requesting %s: unexpected error: %v", r.action, err)
+				}
+			}
+
+			p, ok := f.PendingPowerAction()
+			if !ok || p.Action != tt.wantPending {
+				t.Errorf("pending %v (%t), want %s", p.Action, ok, tt.wantPending)
+			}
+		})
+	}
+}
@@ -5,3 +5,6 @@ type ContextKey string
 
 // RequestIDKey is the key for request IDs set on the incoming context.
 const RequestIDKey ContextKey = "requestID"
+
+// RequestIDHeader is the HTTP response header carrying the request ID.
+const RequestIDHeader = "X-Request-ID"
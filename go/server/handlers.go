@@ -6,6 +6,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -43,42 +44,133 @@ func (s *Server) handleError(err error, message string, w http.ResponseWriter, s
 	return true
 }
 
+// handleFrontendError handles an error returned by the frontend like
+// handleError, responding with HTTP 501 if the request is not supported by the
+// panel, with HTTP 400, 404 or 413 if a sound is rejected, missing or too
+// large, or with HTTP 500 otherwise.
+func (s *Server) handleFrontendError(err error, message string, w http.ResponseWriter) bool {
+	if pipanel.IsUnsupported(err) {
+		feature := errors.Cause(err).(*pipanel.UnsupportedError).Feature
+		return s.handleError(err, "Not supported by this panel: "+feature+".",
+			w, http.StatusNotImplemented)
+	}
+
+	if pipanel.IsInvalidSound(err) {
+		reason := errors.Cause(err).(*pipanel.InvalidSoundError).Reason
+		return s.handleError(err, "Invalid sound: "+reason+".",
+			w, http.StatusBadRequest)
+	}
+
+	switch errors.Cause(err) {
+	case pipanel.ErrNoSuchSound:
+		return s.handleError(err, "No such sound.", w, http.StatusNotFound)
+	case pipanel.ErrSoundTooLarge:
+		return s.handleError(err, "Sound file is too large.",
+			w, http.StatusRequestEntityTooLarge)
+	}
+
+	return s.handleError(err, message, w, http.StatusInternalServerError)
+}
+
+// handleHealth answers the health checks that the server makes of itself. It
+// does not log, since it is requested often.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
 func (s *Server) handleAlertEvent(w http.ResponseWriter, r *http.Request) {
 	s.log.WithContext(r.Context()).Println("Handling alert event.")
 
 	var e pipanel.AlertEvent
 	err := parseAndDecodeBody(r.Body, &e)
 
-	// AlertEvent timeout is measured in milliseconds.
+	// AlertEvent timeout and sound interval are measured in milliseconds.
 	e.Timeout *= time.Millisecond
+	e.Interval *= time.Millisecond
 
 	if s.handleError(err, "JSON is invalid or violates schema.", w, http.StatusBadRequest) {
 		return
 	}
 
-	if !s.processSoundEvent(e.SoundEvent, r, w) {
+	// Repeating sounds are played by the frontend along with the alert, so
+	// that they stop once it is dismissed.
+	if e.Repeats() {
+		err = validateSoundEvent(e.SoundEvent)
+		if s.handleError(err, "JSON is invalid or violates schema.", w, http.StatusBadRequest) {
+			return
+		}
+	} else if !s.processSoundEvent(e.SoundEvent, r, w) {
+		return
+	}
+
+	if len(e.Actions) > 0 {
+		s.processInteractiveAlert(e, w, r)
 		return
 	}
 
 	err = s.frontend.ShowAlert(r.Context(), e)
 
-	if s.handleError(err, "Failed to present alert to user.", w, http.StatusInternalServerError) {
+	if s.handleFrontendError(err, "Failed to present alert to user.", w) {
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// processInteractiveAlert presents an alert with actions to the user, waits
+// for it to be dismissed and responds with the AlertDismissal as JSON.
+func (s *Server) processInteractiveAlert(e pipanel.AlertEvent,
+	w http.ResponseWriter, r *http.Request) {
+
+	dismissed, err := s.frontend.ShowInteractiveAlert(r.Context(), e)
+
+	if s.handleFrontendError(err, "Failed to present alert to user.", w) {
+		return
+	}
+
+	s.log.WithContext(r.Context()).Println("Waiting for alert to be dismissed.")
+
+	select {
+	case d := <-dismissed:
+		s.log.WithContext(r.Context()).WithFields(logrus.Fields{
+			"reason": d.Reason,
+			"action": d.Action,
+		}).Println("Alert dismissed.")
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(d)
+		if err != nil {
+			logfmt.WithError(s.log, err).WithContext(r.Context()).
+				Errorln("Problem when writing alert dismissal.")
+		}
+	case <-r.Context().Done():
+		s.log.WithContext(r.Context()).
+			Println("Client went away before alert was dismissed.")
+	}
+}
+
 func (s *Server) handleSoundEvent(w http.ResponseWriter, r *http.Request) {
 	s.log.WithContext(r.Context()).Println("Handling sound event.")
 
 	var e pipanel.SoundEvent
 	err := parseAndDecodeBody(r.Body, &e)
 
+	// SoundEvent interval is measured in milliseconds.
+	e.Interval *= time.Millisecond
+
+	if err == nil && e.Repeat == pipanel.RepeatUntilAcknowledged {
+		err = errors.New("sounds can only repeat until acknowledged in alerts")
+	}
+
 	if s.handleError(err, "JSON is invalid or violates schema.", w, http.StatusBadRequest) {
 		return
 	}
 
+	if e.Wait {
+		s.processSoundAndWait(e, r, w)
+		return
+	}
+
 	if !s.processSoundEvent(e, r, w) {
 		return
 	}
@@ -86,17 +178,89 @@ func (s *Server) handleSoundEvent(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// soundResult is the response body for sound events that wait for playback to
+// end.
+type soundResult struct {
+	End pipanel.PlaybackEnd `json:"end"`
+}
+
+// processSoundAndWait plays the sound, responding with how its playback ended
+// once it has.
+func (s *Server) processSoundAndWait(e pipanel.SoundEvent,
+	r *http.Request, w http.ResponseWriter) {
+
+	var err error
+	if e.Empty() {
+		err = errors.New("sound or tone is required to wait for it")
+	} else {
+		err = validateSoundEvent(e)
+	}
+
+	if s.handleError(err, "JSON is invalid or violates schema.", w, http.StatusBadRequest) {
+		return
+	}
+
+	end, err := s.frontend.PlaySoundAndWait(r.Context(), e)
+
+	if s.handleFrontendError(err, "Failed to play sound.", w) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(soundResult{End: end})
+	if err != nil {
+		logfmt.WithError(s.log, err).WithContext(r.Context()).
+			Errorln("Problem when writing sound result.")
+	}
+}
+
+func (s *Server) handleSoundStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.log.WithContext(r.Context()).Println("Handling sound stop.")
+
+	err := s.frontend.StopSounds(r.Context())
+
+	if s.handleFrontendError(err, "Failed to stop sounds.", w) {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
 func (s *Server) processSoundEvent(e pipanel.SoundEvent,
 	r *http.Request, w http.ResponseWriter) bool {
 
-	if len(e.Sound) < 1 {
+	if e.Empty() {
 		s.log.WithContext(r.Context()).Println("Ignoring empty sound event.")
 		return true
 	}
 
-	err := s.frontend.PlaySound(r.Context(), e)
+	err := validateSoundEvent(e)
+	if s.handleError(err, "JSON is invalid or violates schema.", w, http.StatusBadRequest) {
+		return false
+	}
+
+	err = s.frontend.PlaySound(r.Context(), e)
 
-	return !s.handleError(err, "Failed to play sound.", w, http.StatusInternalServerError)
+	return !s.handleFrontendError(err, "Failed to play sound.", w)
+}
+
+// validateSoundEvent ensures that the fields of the sound event are in range.
+func validateSoundEvent(e pipanel.SoundEvent) error {
+	if len(e.Sound) > 0 && len(e.Tone) > 0 {
+		return errors.New("sound and tone cannot both be given")
+	} else if e.Volume != nil && *e.Volume > 100 {
+		return errors.Errorf("volume %d is out of range [0,100]", *e.Volume)
+	} else if e.Interval < 0 {
+		return errors.New("interval cannot be negative")
+	}
+
+	return nil
 }
 
 func (s *Server) handlePowerEvent(w http.ResponseWriter, r *http.Request) {
@@ -105,32 +269,306 @@ func (s *Server) handlePowerEvent(w http.ResponseWriter, r *http.Request) {
 	var e pipanel.PowerEvent
 	err := parseAndDecodeBody(r.Body, &e)
 
+	// PowerEvent delay is measured in milliseconds.
+	e.Delay *= time.Millisecond
+
 	if s.handleError(err, "JSON is invalid or violates schema.", w, http.StatusBadRequest) {
 		return
 	}
 
 	err = s.frontend.DoPowerAction(r.Context(), e)
 
-	if s.handleError(err, "Failed to perform requested power action.", w, http.StatusInternalServerError) {
+	switch errors.Cause(err) {
+	case pipanel.ErrPowerActionPending:
+		s.handleError(err, "Another power action is already pending.", w, http.StatusConflict)
+		return
+	case pipanel.ErrNoPendingPowerAction:
+		s.handleError(err, "No power action is pending.", w, http.StatusNotFound)
+		return
+	}
+
+	if s.handleFrontendError(err, "Failed to perform requested power action.", w) {
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// powerCapabilities is the response body for power capability queries.
+type powerCapabilities struct {
+	Actions []pipanel.PowerAction `json:"actions"`
+}
+
+func (s *Server) handlePowerCapabilities(w http.ResponseWriter, r *http.Request) {
+	s.log.WithContext(r.Context()).Println("Handling power capability query.")
+
+	actions, err := s.frontend.PowerCapabilities(r.Context())
+
+	if s.handleFrontendError(err, "Failed to query power capabilities.", w) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(powerCapabilities{Actions: actions})
+	if err != nil {
+		logfmt.WithError(s.log, err).WithContext(r.Context()).
+			Errorln("Problem when writing power capabilities.")
+	}
+}
+
+// handlePendingPower reports the pending delayed power action on GET, and
+// cancels it on DELETE. Either responds with the action as JSON.
+func (s *Server) handlePendingPower(w http.ResponseWriter, r *http.Request) {
+	var p pipanel.PendingPowerAction
+
+	switch r.Method {
+	case http.MethodGet:
+		s.log.WithContext(r.Context()).Println("Handling pending power query.")
+
+		var ok bool
+		if p, ok = s.frontend.PendingPowerAction(); !ok {
+			http.Error(w, "No power action is pending.", http.StatusNotFound)
+			return
+		}
+	case http.MethodDelete:
+		s.log.WithContext(r.Context()).Println("Handling pending power cancellation.")
+
+		var err error
+		p, err = s.frontend.CancelPowerAction(r.Context())
+
+		if errors.Cause(err) == pipanel.ErrNoPendingPowerAction {
+			http.Error(w, "No power action is pending.", http.StatusNotFound)
+			return
+		}
+
+		if s.handleFrontendError(err, "Failed to cancel pending power action.", w) {
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		http.Error(w, "Method not allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(p)
+	if err != nil {
+		logfmt.WithError(s.log, err).WithContext(r.Context()).
+			Errorln("Problem when writing pending power action.")
+	}
+}
+
 func (s *Server) handleBrightnessEvent(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		s.handleBrightnessQuery(w, r)
+		return
+	}
+
 	s.log.WithContext(r.Context()).Println("Handling brightness event.")
 
 	var e pipanel.BrightnessEvent
 	err := parseAndDecodeBody(r.Body, &e)
 
+	// BrightnessEvent transition is measured in milliseconds.
+	e.Transition *= time.Millisecond
+
 	if s.handleError(err, "JSON is invalid or violates schema.", w, http.StatusBadRequest) {
 		return
 	}
 
 	err = s.frontend.SetBrightness(r.Context(), e)
 
-	if s.handleError(err, "Failed to perform requested brightness action.", w, http.StatusInternalServerError) {
+	if s.handleFrontendError(err, "Failed to perform requested brightness action.", w) {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// brightnessState is the response body for brightness queries.
+type brightnessState struct {
+	Level uint8 `json:"level"`
+}
+
+func (s *Server) handleBrightnessQuery(w http.ResponseWriter, r *http.Request) {
+	s.log.WithContext(r.Context()).Println("Handling brightness query.")
+
+	level, err := s.frontend.Brightness(r.Context())
+
+	if s.handleFrontendError(err, "Failed to read brightness.", w) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(brightnessState{Level: level})
+	if err != nil {
+		logfmt.WithError(s.log, err).WithContext(r.Context()).
+			Errorln("Problem when writing brightness.")
+	}
+}
+
+func (s *Server) handleSounds(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.log.WithContext(r.Context()).Println("Handling sound library query.")
+
+	sounds, err := s.frontend.Sounds(r.Context())
+
+	if s.handleFrontendError(err, "Failed to list sounds.", w) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(sounds)
+	if err != nil {
+		logfmt.WithError(s.log, err).WithContext(r.Context()).
+			Errorln("Problem when writing sound library.")
+	}
+}
+
+// handleSound handles the routes for a single sound in the library, which is
+// named by the rest of the path. Sounds may be in subfolders, so the name may
+// contain slashes.
+func (s *Server) handleSound(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, soundsRoute+"/")
+
+	switch r.Method {
+	case http.MethodPost:
+		if !strings.HasSuffix(name, soundPlaySuffix) {
+			http.NotFound(w, r)
+			return
+		}
+		name = strings.TrimSuffix(name, soundPlaySuffix)
+
+		s.log.WithContext(r.Context()).WithField("sound", name).
+			Println("Handling sound preview.")
+
+		err := s.frontend.PlaySound(r.Context(), pipanel.SoundEvent{Sound: name})
+
+		if s.handleFrontendError(err, "Failed to play sound.", w) {
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPut:
+		s.log.WithContext(r.Context()).WithField("sound", name).
+			Println("Handling sound upload.")
+
+		info, err := s.frontend.AddSound(r.Context(), name, r.Body)
+
+		if s.handleFrontendError(err, "Failed to add sound.", w) {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(info)
+		if err != nil {
+			logfmt.WithError(s.log, err).WithContext(r.Context()).
+				Errorln("Problem when writing sound.")
+		}
+	case http.MethodDelete:
+		s.log.WithContext(r.Context()).WithField("sound", name).
+			Println("Handling sound deletion.")
+
+		err := s.frontend.DeleteSound(r.Context(), name)
+
+		if s.handleFrontendError(err, "Failed to delete sound.", w) {
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.Header().Set("Allow", "PUT, DELETE, POST")
+		http.Error(w, "Method not allowed.", http.StatusMethodNotAllowed)
+	}
+}
+
+// volumeState is the response body for volume queries and changes.
+type volumeState struct {
+	Level uint8 `json:"level"`
+}
+
+func (s *Server) handleVolume(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.log.WithContext(r.Context()).Println("Handling volume query.")
+	case http.MethodPut:
+		s.log.WithContext(r.Context()).Println("Handling volume event.")
+
+		var e pipanel.VolumeEvent
+		err := parseAndDecodeBody(r.Body, &e)
+		if err == nil && e.Level > 100 {
+			err = errors.Errorf("volume %d is out of range [0,100]", e.Level)
+		}
+
+		if s.handleError(err, "JSON is invalid or violates schema.", w, http.StatusBadRequest) {
+			return
+		}
+
+		err = s.frontend.SetVolume(r.Context(), e)
+
+		if s.handleFrontendError(err, "Failed to set volume.", w) {
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "Method not allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	level, err := s.frontend.Volume(r.Context())
+
+	if s.handleFrontendError(err, "Failed to read volume.", w) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(volumeState{Level: level})
+	if err != nil {
+		logfmt.WithError(s.log, err).WithContext(r.Context()).
+			Errorln("Problem when writing volume.")
+	}
+}
+
+func (s *Server) handleDisplayEvent(w http.ResponseWriter, r *http.Request) {
+	s.log.WithContext(r.Context()).Println("Handling display power event.")
+
+	var e pipanel.DisplayPowerEvent
+	err := parseAndDecodeBody(r.Body, &e)
+	if err == nil && len(e.State) < 1 {
+		err = errors.New("display power state is required")
+	}
+
+	if s.handleError(err, "JSON is invalid or violates schema.", w, http.StatusBadRequest) {
+		return
+	}
+
+	err = s.frontend.SetDisplayPower(r.Context(), e)
+
+	if s.handleFrontendError(err, "Failed to change display power state.", w) {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleColorTemperatureEvent(w http.ResponseWriter, r *http.Request) {
+	s.log.WithContext(r.Context()).Println("Handling color temperature event.")
+
+	var e pipanel.ColorTemperatureEvent
+	err := parseAndDecodeBody(r.Body, &e)
+
+	if s.handleError(err, "JSON is invalid or violates schema.", w, http.StatusBadRequest) {
+		return
+	}
+
+	err = s.frontend.SetColorTemperature(r.Context(), e)
+
+	if s.handleFrontendError(err, "Failed to change color temperature.", w) {
 		return
 	}
 
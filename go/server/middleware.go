@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"crypto/subtle"
 	"net/http"
 	"runtime/debug"
 
@@ -17,12 +18,15 @@ import (
 func AttachRequestIDMiddlewareBuilder() Middleware {
 	return func(h http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
-			// Attach a new UUID as the request ID.
+			// Attach a new UUID as the request ID, and report it to the
+			// client so that it can be matched with the logs.
+			rID := uuid.New().String()
 			r = r.WithContext(context.WithValue(
 				r.Context(),
 				pipanel.RequestIDKey,
-				uuid.New().String(),
+				rID,
 			))
+			w.Header().Set(pipanel.RequestIDHeader, rID)
 
 			// Continue handling request.
 			h(w, r)
@@ -30,6 +34,35 @@ func AttachRequestIDMiddlewareBuilder() Middleware {
 	}
 }
 
+// AuthMiddlewareBuilder creates a new Middleware that rejects requests which do
+// not present the given bearer token in the Authorization header. If the token
+// is empty, all requests are allowed.
+func AuthMiddlewareBuilder(log *logrus.Entry, token string) Middleware {
+	expected := []byte("Bearer " + token)
+
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		if len(token) < 1 {
+			return h
+		}
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			given := []byte(r.Header.Get("Authorization"))
+
+			if subtle.ConstantTimeCompare(given, expected) != 1 {
+				log.WithContext(r.Context()).
+					Warnln("Rejecting request with missing or bad token.")
+
+				w.Header().Set("WWW-Authenticate", `Bearer realm="pipanel"`)
+				http.Error(w, "Missing or invalid bearer token.",
+					http.StatusUnauthorized)
+				return
+			}
+
+			h(w, r)
+		}
+	}
+}
+
 // PanicRecoverMiddlewareBuilder creates a new Middleware that recovers from
 // panics by logging the stack and cause, then returning HTTP 500 to the client.
 func PanicRecoverMiddlewareBuilder(log *logrus.Entry) Middleware {
@@ -5,11 +5,14 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 
 	pipanel "github.com/BenJetson/pipanel/go"
 	"github.com/BenJetson/pipanel/go/logfmt"
+	"github.com/BenJetson/pipanel/go/systemd"
 
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
@@ -19,10 +22,24 @@ type Server struct {
 	log      *logrus.Entry
 	frontend *pipanel.Frontend
 	httpd    *http.Server
+	mux      *MiddleMux
+	token    string
+	listener net.Listener
 }
 
-// New creates a new Server instance, binding to the given port and frontend.
-func New(l *logrus.Entry, port int, frontend *pipanel.Frontend) *Server {
+// healthRoute is requested by the server of itself to check its health.
+const healthRoute = "/health"
+
+// soundsRoute is the route of the sound library. Each sound has its own route
+// below it, and soundPlaySuffix is appended to that route to play the sound.
+const (
+	soundsRoute     = "/sounds"
+	soundPlaySuffix = "/play"
+)
+
+// New creates a new Server instance, binding to the configured port and the
+// given frontend.
+func New(l *logrus.Entry, cfg pipanel.ServerConfig, frontend *pipanel.Frontend) *Server {
 	// Create a multiplexer for routing requests.
 	mux := NewMiddleMux()
 
@@ -30,36 +47,94 @@ func New(l *logrus.Entry, port int, frontend *pipanel.Frontend) *Server {
 	s := Server{
 		log: l,
 		httpd: &http.Server{
-			Addr:     fmt.Sprintf(":%d", port),
+			Addr:     fmt.Sprintf(":%d", cfg.Port),
 			ErrorLog: log.New(l.WriterLevel(logrus.ErrorLevel), "", 0),
 			Handler:  mux,
 		},
 		frontend: frontend,
+		mux:      mux,
+		token:    cfg.AuthToken,
 	}
 
 	// Define routes.
 	mux.HandleFunc("/alert", s.handleAlertEvent)
 	mux.HandleFunc("/sound", s.handleSoundEvent)
+	mux.HandleFunc("/sound/stop", s.handleSoundStop)
+	mux.HandleFunc("/volume", s.handleVolume)
+	mux.HandleFunc(soundsRoute, s.handleSounds)
+	mux.HandleFunc(soundsRoute+"/", s.handleSound)
 	mux.HandleFunc("/power", s.handlePowerEvent)
+	mux.HandleFunc("/power/pending", s.handlePendingPower)
+	mux.HandleFunc("/power/capabilities", s.handlePowerCapabilities)
 	mux.HandleFunc("/brightness", s.handleBrightnessEvent)
+	mux.HandleFunc("/display", s.handleDisplayEvent)
+	mux.HandleFunc("/color_temperature", s.handleColorTemperatureEvent)
+	mux.HandleFunc(healthRoute, s.handleHealth)
 
 	// Register middleware.
+	mux.Use(AuthMiddlewareBuilder(l, cfg.AuthToken))
 	mux.Use(AttachRequestIDMiddlewareBuilder())
 	mux.Use(PanicRecoverMiddlewareBuilder(l))
 
 	return &s
 }
 
-// ListenAndServe instructs the server to bind to the configured port and
-// listen for requests to handle. Will block until the server terminates.
-// Upon termination, this function will close the channel given by the
-// parameter, allowing for this server to run in a separate goroutine.
+// Mount routes requests for the given pattern, as understood by
+// http.ServeMux, to the given handler. The handler is wrapped by the same
+// middleware as the built-in routes. Must be called before ListenAndServe.
+func (s *Server) Mount(pattern string, h http.Handler) {
+	s.mux.Handle(pattern, h)
+}
+
+// Listen binds the server to the socket passed by systemd, if the process was
+// socket-activated, or else to the configured port. Must be called before
+// Serve.
+func (s *Server) Listen() error {
+	listeners, err := systemd.Listeners()
+	if err != nil {
+		return errors.Wrap(err, "could not use sockets passed by systemd")
+	}
+
+	if len(listeners) > 0 {
+		s.log.WithField("address", listeners[0].Addr()).
+			Println("Using socket passed by systemd.")
+
+		for _, extra := range listeners[1:] {
+			s.log.WithField("address", extra.Addr()).
+				Warnln("Ignoring extra socket passed by systemd.")
+			extra.Close()
+		}
+
+		s.listener = listeners[0]
+		return nil
+	}
+
+	s.listener, err = net.Listen("tcp", s.httpd.Addr)
+	return errors.Wrapf(err, "could not listen on %s", s.httpd.Addr)
+}
+
+// ListenAndServe calls Listen and then Serve, closing the channel given by the
+// parameter should Listen fail.
 func (s *Server) ListenAndServe(closeOnReturn chan<- struct{}) {
+	if err := s.Listen(); err != nil {
+		logfmt.WithError(s.log, err).Errorln("Server could not listen.")
+		close(closeOnReturn)
+		return
+	}
+
+	s.Serve(closeOnReturn)
+}
+
+// Serve handles requests on the socket bound by Listen. Will block until the
+// server terminates. Upon termination, this function will close the channel
+// given by the parameter, allowing for this server to run in a separate
+// goroutine.
+func (s *Server) Serve(closeOnReturn chan<- struct{}) {
 	defer close(closeOnReturn)
 
 	s.log.Println("Server started.")
 
-	err := s.httpd.ListenAndServe()
+	err := s.httpd.Serve(s.listener)
 
 	if err != nil && err != http.ErrServerClosed {
 		logfmt.WithError(s.log, err).
@@ -69,13 +144,69 @@ func (s *Server) ListenAndServe(closeOnReturn chan<- struct{}) {
 	s.log.Println("Server has gracefully stopped.")
 }
 
-// Shutdown tears down this Server and releases its resources.
+// HealthCheck checks that the server is handling requests, by making a request
+// of itself through its socket.
+func (s *Server) HealthCheck(ctx context.Context) error {
+	if s.listener == nil {
+		return errors.New("server is not listening")
+	}
+
+	addr := s.listener.Addr()
+	client := http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, addr.Network(), addr.String())
+			},
+			DisableKeepAlives: true,
+		},
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://pipanel"+healthRoute, nil)
+	if err != nil {
+		return errors.Wrap(err, "could not create health check request")
+	}
+
+	if len(s.token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "server did not answer health check")
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("server answered health check with HTTP %d",
+			resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Shutdown tears down this Server and releases its resources. The server stops
+// accepting connections immediately, and requests already in flight are
+// allowed to finish until ctx is done, at which point any remaining
+// connections are closed forcibly.
 func (s *Server) Shutdown(ctx context.Context) error {
+	// Shut down the HTTP server, draining in-flight requests.
+	err := s.httpd.Shutdown(ctx)
+	if err == ctx.Err() && err != nil {
+		s.log.Warnln("Requests still in flight at deadline; closing connections.")
+
+		if closeErr := s.httpd.Close(); closeErr != nil {
+			logfmt.WithError(s.log, closeErr).
+				Errorln("Problem when closing connections.")
+		}
+
+		err = errors.Wrap(err, "in-flight requests did not finish in time")
+	}
+
 	// Attempt to close the io.PipeWriter passed to http.ErrorLog by Init.
 	if w, ok := s.httpd.ErrorLog.Writer().(*io.PipeWriter); ok {
 		w.Close()
 	}
 
-	// Shut down the HTTP server.
-	return s.httpd.Shutdown(ctx)
+	return err
 }
@@ -0,0 +1,101 @@
+package pipanel
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoSuchSound is returned when a sound is not in the sound library.
+var ErrNoSuchSound = errors.New("no such sound")
+
+// ErrSoundTooLarge is returned when an uploaded sound exceeds the size limit
+// of the sound library.
+var ErrSoundTooLarge = errors.New("sound is too large")
+
+// InvalidSoundError is returned by a SoundLibrary that rejects the name of a
+// sound, or a sound file that was uploaded.
+type InvalidSoundError struct {
+	// Reason describes why the sound was rejected.
+	Reason string
+}
+
+func (e *InvalidSoundError) Error() string {
+	return "invalid sound: " + e.Reason
+}
+
+// InvalidSound returns an InvalidSoundError for the reason, described by the
+// format and arguments as understood by fmt.Sprintf.
+func InvalidSound(format string, args ...interface{}) error {
+	return errors.WithStack(&InvalidSoundError{
+		Reason: fmt.Sprintf(format, args...),
+	})
+}
+
+// IsInvalidSound reports whether the cause of err is an InvalidSoundError.
+func IsInvalidSound(err error) bool {
+	_, ok := errors.Cause(err).(*InvalidSoundError)
+	return ok
+}
+
+// SoundInfo describes a sound in the sound library.
+type SoundInfo struct {
+	// Name is the name of the sound, as given in the Sound field of a
+	// SoundEvent. Sounds in subfolders of the library are named by their
+	// path, such as "doorbell/chime".
+	Name string `json:"name"`
+	// Format is the file format of the sound, such as "wav" or "mp3".
+	Format string `json:"format"`
+	// Duration is how long the sound plays for.
+	Duration Duration `json:"duration"`
+	// SampleRate is the number of samples per second of the sound.
+	SampleRate int `json:"sample_rate"`
+}
+
+// Sounds lists the sounds in the library of the AudioPlayer. Returns an
+// UnsupportedError if the AudioPlayer is not a SoundLibrary.
+func (f *Frontend) Sounds(ctx context.Context) ([]SoundInfo, error) {
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+
+	lib, ok := f.AudioPlayer.(SoundLibrary)
+	if !ok {
+		return nil, Unsupported("managing the sound library")
+	}
+
+	return lib.Sounds(ctx)
+}
+
+// AddSound adds the sound file read from r to the library of the AudioPlayer
+// under the given name, replacing any sound of that name. Returns an
+// UnsupportedError if the AudioPlayer is not a SoundLibrary.
+func (f *Frontend) AddSound(ctx context.Context, name string,
+	r io.Reader) (SoundInfo, error) {
+
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+
+	lib, ok := f.AudioPlayer.(SoundLibrary)
+	if !ok {
+		return SoundInfo{}, Unsupported("managing the sound library")
+	}
+
+	return lib.AddSound(ctx, name, r)
+}
+
+// DeleteSound removes the sound with the given name from the library of the
+// AudioPlayer. Returns an UnsupportedError if the AudioPlayer is not a
+// SoundLibrary.
+func (f *Frontend) DeleteSound(ctx context.Context, name string) error {
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+
+	lib, ok := f.AudioPlayer.(SoundLibrary)
+	if !ok {
+		return Unsupported("managing the sound library")
+	}
+
+	return lib.DeleteSound(ctx, name)
+}
@@ -0,0 +1,142 @@
+// Package systemd implements the parts of the systemd service protocol used by
+// PiPanel: readiness and status notifications, the watchdog and socket
+// activation. Each does nothing if the process was not started by systemd with
+// the corresponding feature enabled.
+package systemd
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Notifications understood by the service manager.
+const (
+	// Ready tells the service manager that startup has finished.
+	Ready = "READY=1"
+	// Reloading tells the service manager that the configuration is being
+	// reloaded. Ready must be sent once reloading has finished.
+	Reloading = "RELOADING=1"
+	// Stopping tells the service manager that the service is shutting down.
+	Stopping = "STOPPING=1"
+	// Watchdog resets the watchdog timer of the service manager.
+	Watchdog = "WATCHDOG=1"
+)
+
+// listenFDsStart is the first file descriptor passed by socket activation.
+const listenFDsStart = 3
+
+// Status returns a notification that sets the status text shown by
+// "systemctl status".
+func Status(text string) string { return "STATUS=" + text }
+
+// Notify sends the notifications to the service manager. It reports false if
+// the process was not given a notification socket.
+func Notify(notifications ...string) (bool, error) {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if len(path) < 1 {
+		return false, nil
+	}
+
+	// A leading "@" denotes a socket in the abstract namespace.
+	if path[0] == '@' {
+		path = "\x00" + path[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return true, errors.Wrap(err, "could not connect to notification socket")
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(strings.Join(notifications, "\n")))
+	return true, errors.Wrap(err, "could not send notification")
+}
+
+// forThisProcess reports whether the PID in the environment variable, if any,
+// is that of this process.
+func forThisProcess(variable string) (bool, error) {
+	v := os.Getenv(variable)
+	if len(v) < 1 {
+		return true, nil
+	}
+
+	pid, err := strconv.Atoi(v)
+	if err != nil {
+		return false, errors.Wrapf(err, "malformed %s", variable)
+	}
+
+	return pid == os.Getpid(), nil
+}
+
+// WatchdogInterval returns the interval within which the service manager
+// expects the Watchdog notification, which should be sent about twice as
+// often. It reports false if the watchdog is not enabled for this process.
+func WatchdogInterval() (time.Duration, bool, error) {
+	v := os.Getenv("WATCHDOG_USEC")
+	if len(v) < 1 {
+		return 0, false, nil
+	}
+
+	usec, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, false, errors.Errorf("malformed WATCHDOG_USEC '%s'", v)
+	}
+
+	if ok, err := forThisProcess("WATCHDOG_PID"); !ok || err != nil {
+		return 0, false, err
+	}
+
+	return time.Duration(usec) * time.Microsecond, true, nil
+}
+
+// Listeners returns the listening sockets passed by the service manager for
+// socket activation, in order, or nil if none were passed. The environment
+// variables describing them are unset, so that child processes do not
+// mistake the sockets for their own.
+func Listeners() ([]net.Listener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	v := os.Getenv("LISTEN_FDS")
+	if len(v) < 1 || len(os.Getenv("LISTEN_PID")) < 1 {
+		return nil, nil
+	}
+
+	if ok, err := forThisProcess("LISTEN_PID"); !ok || err != nil {
+		return nil, err
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return nil, errors.Errorf("malformed LISTEN_FDS '%s'", v)
+	}
+
+	listeners := make([]net.Listener, 0, n)
+	for fd := listenFDsStart; fd < listenFDsStart+n; fd++ {
+		syscall.CloseOnExec(fd)
+
+		f := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		l, err := net.FileListener(f)
+		f.Close()
+
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return nil, errors.Wrapf(err, "file descriptor %d is not a listening socket", fd)
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	return listeners, nil
+}